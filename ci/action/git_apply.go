@@ -0,0 +1,162 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ConflictError 应用变更时发生冲突
+type ConflictError struct {
+	// 冲突的文件列表
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict in files: %s", strings.Join(e.Files, ","))
+}
+
+// applyTreeChanges 把 from 树到 to 树的变更应用到工作区并暂存，from 为空表示根提交
+// 工作区中的文件内容既不是变更前的内容也不是变更后的内容时视为冲突，返回 ConflictError，此时不会修改任何文件
+func applyTreeChanges(w *git.Worktree, from, to *object.Tree) error {
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	for _, change := range changes {
+		fromFile, toFile, err := change.Files()
+		if err != nil {
+			return err
+		}
+		for _, name := range changePaths(change) {
+			current, exists, err := readWorktreeFile(w, name)
+			if err != nil {
+				return err
+			}
+			if !sameContent(current, exists, fileAtPath(change.From.Name, fromFile, name)) && !sameContent(current, exists, fileAtPath(change.To.Name, toFile, name)) {
+				conflicts = append(conflicts, name)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ConflictError{Files: conflicts}
+	}
+	for _, change := range changes {
+		_, toFile, err := change.Files()
+		if err != nil {
+			return err
+		}
+		// 删除或者重命名时删除旧文件
+		if change.From.Name != "" && change.From.Name != change.To.Name {
+			if _, err := w.Remove(change.From.Name); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return err
+			}
+		}
+		if toFile == nil {
+			continue
+		}
+		if err = writeWorktreeFile(w, change.To.Name, toFile); err != nil {
+			return err
+		}
+		if _, err = w.Add(change.To.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changePaths 返回变更涉及的文件路径
+func changePaths(change *object.Change) []string {
+	if change.From.Name == "" {
+		return []string{change.To.Name}
+	}
+	if change.To.Name == "" || change.To.Name == change.From.Name {
+		return []string{change.From.Name}
+	}
+	return []string{change.From.Name, change.To.Name}
+}
+
+// fileAtPath 文件路径一致时返回该文件，否则返回nil，表示该路径下没有文件
+// object.File 的 Name 只是文件名，不包含目录，所以需要使用变更中的完整路径进行比较
+func fileAtPath(filePath string, file *object.File, name string) *object.File {
+	if file != nil && filePath == name {
+		return file
+	}
+	return nil
+}
+
+// sameContent 判断工作区文件内容与文件对象是否一致，文件对象为空表示文件不存在
+func sameContent(current []byte, exists bool, file *object.File) bool {
+	if file == nil {
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return false
+	}
+	return contents == string(current)
+}
+
+// readWorktreeFile 读取工作区文件内容
+func readWorktreeFile(w *git.Worktree, name string) ([]byte, bool, error) {
+	f, err := w.Filesystem.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	return content, true, err
+}
+
+// writeWorktreeFile 把文件对象写入工作区的指定路径
+func writeWorktreeFile(w *git.Worktree, name string, file *object.File) error {
+	if err := w.Filesystem.MkdirAll(path.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	perm := os.FileMode(0644)
+	if file.Mode == filemode.Executable {
+		perm = 0755
+	}
+	f, err := w.Filesystem.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, reader)
+	return err
+}
@@ -0,0 +1,683 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitApplyPatchNode{})
+}
+
+// ErrPatchFailed 补丁无法应用
+var ErrPatchFailed = errors.New("patch does not apply")
+
+// GitApplyPatchNodeConfiguration 节点配置
+type GitApplyPatchNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 补丁文件路径，为空则使用 msg.Data 作为补丁内容
+	PatchFile string
+	// 去掉补丁中文件路径的前几级目录，与 patch -p 相同，默认1，即去掉 a/ b/ 前缀
+	Strip int
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// PatchFileResult 单个文件的补丁应用结果
+type PatchFileResult struct {
+	// 文件路径，相对工作目录
+	Path string `json:"path"`
+	// 重命名前的路径
+	OldPath string `json:"oldPath,omitempty"`
+	// 变更类型，可以是 add、delete、modify 或 rename
+	ChangeType string `json:"changeType"`
+	// 修改后的文件权限，例如：100755，没有修改权限则为空
+	Mode string `json:"mode,omitempty"`
+	// 补丁块数量
+	Hunks int `json:"hunks"`
+	// 成功的补丁块数量
+	Applied int `json:"applied"`
+	// 失败的补丁块序号，从1开始
+	Failed []int `json:"failed,omitempty"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+}
+
+// PatchResult 补丁应用结果
+type PatchResult struct {
+	// 是否已经写入工作目录
+	Applied bool `json:"applied"`
+	// 每个文件的结果
+	Files []PatchFileResult `json:"files"`
+}
+
+// GitApplyPatchNode 把统一格式(unified diff)的补丁应用到工作目录，补丁内容来自 msg.Data 或者补丁文件
+// 支持新增、删除、重命名文件以及修改文件权限，每个文件的结果以JSON的形式写入 msg.Data
+// 补丁先在内存中应用，所有补丁块都成功后再写入临时文件并重命名，任意补丁块失败则不会修改任何文件，并通过Failure链输出失败的文件和补丁块
+// 不支持二进制补丁
+type GitApplyPatchNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitApplyPatchNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitApplyPatchNode) Type() string {
+	return "ci/gitApplyPatch"
+}
+
+func (x *GitApplyPatchNode) New() types.Node {
+	return &GitApplyPatchNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitApplyPatchNodeConfiguration{
+			Strip:          1,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitApplyPatchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.Strip < 0 {
+		return fmt.Errorf("strip must not be negative: %d", x.Config.Strip)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.PatchFile) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitApplyPatchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	// 补丁写入本地工作目录，内存仓库不会被修改
+	if msg.Metadata.GetValue(x.metaKey(KeyRepoId)) != "" {
+		ctx.TellFailure(msg, errors.New("apply patch is not supported for in-memory repository"))
+		return
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, "", workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	content := msg.Data
+	if patchFile := x.getValue(x.Config.PatchFile, evn); patchFile != "" {
+		data, err := os.ReadFile(patchFile)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		content = string(data)
+	}
+	patches, err := parsePatch(content, x.Config.Strip)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result, applyErr := applyPatches(workDir, patches)
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		msg.DataType = types.JSON
+		msg.Data = string(data)
+	}
+	if applyErr != nil {
+		ctx.TellFailure(msg, applyErr)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitApplyPatchNode) Destroy() {
+}
+
+func (x *GitApplyPatchNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// filePatch 单个文件的补丁
+type filePatch struct {
+	OldPath  string
+	NewPath  string
+	OldMode  string
+	NewMode  string
+	IsNew    bool
+	IsDelete bool
+	IsRename bool
+	IsBinary bool
+	Hunks    []patchHunk
+}
+
+// path 补丁应用后的文件路径
+func (p *filePatch) path() string {
+	if p.IsDelete {
+		return p.OldPath
+	}
+	return p.NewPath
+}
+
+// patchHunk 补丁块
+type patchHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []patchLine
+}
+
+// patchLine 补丁块中的一行，Op 为 ' '、'-' 或 '+'
+type patchLine struct {
+	Op        byte
+	Text      string
+	NoNewline bool
+}
+
+// parsePatch 解析统一格式的补丁，支持 git diff 的扩展头
+func parsePatch(content string, strip int) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var patches []*filePatch
+	var current *filePatch
+	// 当前文件是否由 diff --git 开始，并且还没有读取到 ---/+++ 或者补丁块
+	var inGitHeader bool
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath := parseGitDiffHeader(strings.TrimPrefix(line, "diff --git "))
+			current = &filePatch{OldPath: stripPatchPath(oldPath, strip), NewPath: stripPatchPath(newPath, strip)}
+			patches = append(patches, current)
+			inGitHeader = true
+		case inGitHeader && strings.HasPrefix(line, "new file mode "):
+			current.IsNew = true
+			current.NewMode = strings.TrimPrefix(line, "new file mode ")
+		case inGitHeader && strings.HasPrefix(line, "deleted file mode "):
+			current.IsDelete = true
+			current.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+		case inGitHeader && strings.HasPrefix(line, "old mode "):
+			current.OldMode = strings.TrimPrefix(line, "old mode ")
+		case inGitHeader && strings.HasPrefix(line, "new mode "):
+			current.NewMode = strings.TrimPrefix(line, "new mode ")
+		case inGitHeader && strings.HasPrefix(line, "rename from "):
+			current.IsRename = true
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case inGitHeader && strings.HasPrefix(line, "rename to "):
+			current.IsRename = true
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case inGitHeader && (strings.HasPrefix(line, "GIT binary patch") || strings.HasPrefix(line, "Binary files ")):
+			current.IsBinary = true
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath := parsePatchFilePath(strings.TrimPrefix(line, "--- "))
+			newPath := parsePatchFilePath(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+			if current == nil || !inGitHeader {
+				current = &filePatch{}
+				patches = append(patches, current)
+			}
+			inGitHeader = false
+			if oldPath == "/dev/null" {
+				current.IsNew = true
+			} else if !current.IsRename {
+				current.OldPath = stripPatchPath(oldPath, strip)
+			}
+			if newPath == "/dev/null" {
+				current.IsDelete = true
+			} else if !current.IsRename {
+				current.NewPath = stripPatchPath(newPath, strip)
+			}
+			if current.IsNew {
+				current.OldPath = current.NewPath
+			}
+			if current.IsDelete {
+				current.NewPath = current.OldPath
+			}
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("hunk without file header at line %d", i+1)
+			}
+			inGitHeader = false
+			hunk, next, err := parsePatchHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			i = next - 1
+		}
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("no file patch found")
+	}
+	for _, p := range patches {
+		if p.path() == "" {
+			return nil, errors.New("file patch without path")
+		}
+	}
+	return patches, nil
+}
+
+// parseGitDiffHeader 解析 diff --git a/old b/new 中的路径
+func parseGitDiffHeader(value string) (string, string) {
+	if idx := strings.Index(value, " b/"); idx > 0 {
+		return value[:idx], value[idx+1:]
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 2 {
+		return fields[0], fields[1]
+	}
+	return value, value
+}
+
+// parsePatchFilePath 解析 ---/+++ 中的路径，去掉时间戳
+func parsePatchFilePath(value string) string {
+	if idx := strings.IndexByte(value, '\t'); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
+
+// stripPatchPath 去掉路径的前 strip 级目录
+func stripPatchPath(value string, strip int) string {
+	if value == "/dev/null" {
+		return ""
+	}
+	for i := 0; i < strip; i++ {
+		idx := strings.IndexByte(value, '/')
+		if idx < 0 {
+			break
+		}
+		value = value[idx+1:]
+	}
+	return value
+}
+
+// parsePatchHunk 解析从 start 行开始的补丁块，返回补丁块和补丁块之后的行号
+func parsePatchHunk(lines []string, start int) (patchHunk, int, error) {
+	var hunk patchHunk
+	header := lines[start]
+	end := strings.Index(header[3:], " @@")
+	if end < 0 {
+		return hunk, 0, fmt.Errorf("invalid hunk header at line %d: %s", start+1, header)
+	}
+	ranges := strings.Fields(header[3 : end+3])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return hunk, 0, fmt.Errorf("invalid hunk header at line %d: %s", start+1, header)
+	}
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseHunkRange(ranges[0][1:]); err != nil {
+		return hunk, 0, fmt.Errorf("invalid hunk header at line %d: %w", start+1, err)
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseHunkRange(ranges[1][1:]); err != nil {
+		return hunk, 0, fmt.Errorf("invalid hunk header at line %d: %w", start+1, err)
+	}
+	oldCount, newCount := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldCount < hunk.OldLines || newCount < hunk.NewLines); i++ {
+		line := lines[i]
+		if line == "" {
+			// 部分工具会去掉空白上下文行末尾的空格
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			oldCount++
+			newCount++
+		case '-':
+			oldCount++
+		case '+':
+			newCount++
+		case '\\':
+			if len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+			}
+			continue
+		default:
+			return hunk, 0, fmt.Errorf("unexpected line in hunk at line %d: %s", i+1, line)
+		}
+		hunk.Lines = append(hunk.Lines, patchLine{Op: line[0], Text: line[1:]})
+	}
+	if oldCount != hunk.OldLines || newCount != hunk.NewLines {
+		return hunk, 0, fmt.Errorf("truncated hunk at line %d", start+1)
+	}
+	// 最后一行之后的 \ No newline at end of file
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") && len(hunk.Lines) > 0 {
+		hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+		i++
+	}
+	return hunk, i, nil
+}
+
+// parseHunkRange 解析 start,count 或者 start
+func parseHunkRange(value string) (int, int, error) {
+	startValue, countValue, found := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if found {
+		if count, err = strconv.Atoi(countValue); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// patchedFile 在内存中应用补丁后的文件
+type patchedFile struct {
+	patch   *filePatch
+	target  string
+	source  string
+	content []byte
+	perm    os.FileMode
+}
+
+// applyPatches 把补丁应用到 workDir，任意补丁块失败则不修改任何文件
+func applyPatches(workDir string, patches []*filePatch) (*PatchResult, error) {
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return nil, err
+	}
+	result := &PatchResult{}
+	var files []*patchedFile
+	var failures []string
+	for _, p := range patches {
+		fileResult := PatchFileResult{Path: p.path(), Hunks: len(p.Hunks), Mode: p.NewMode}
+		switch {
+		case p.IsNew:
+			fileResult.ChangeType = ChangeTypeAdd
+		case p.IsDelete:
+			fileResult.ChangeType = ChangeTypeDelete
+			fileResult.Mode = ""
+		case p.IsRename:
+			fileResult.ChangeType = ChangeTypeRename
+			fileResult.OldPath = p.OldPath
+		default:
+			fileResult.ChangeType = ChangeTypeModify
+		}
+		file, err := applyFilePatch(root, p, &fileResult)
+		if err != nil {
+			fileResult.Error = err.Error()
+		}
+		if fileResult.Error != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", fileResult.Path, fileResult.Error))
+		}
+		if file != nil {
+			files = append(files, file)
+		}
+		result.Files = append(result.Files, fileResult)
+	}
+	if len(failures) > 0 {
+		return result, fmt.Errorf("%w: %s", ErrPatchFailed, strings.Join(failures, "; "))
+	}
+	if err = writePatchedFiles(files); err != nil {
+		return result, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// applyFilePatch 在内存中应用单个文件的补丁
+func applyFilePatch(root string, p *filePatch, fileResult *PatchFileResult) (*patchedFile, error) {
+	if p.IsBinary {
+		return nil, errors.New("binary patch is not supported")
+	}
+	target, err := patchTargetPath(root, p.path())
+	if err != nil {
+		return nil, err
+	}
+	source, err := patchTargetPath(root, p.OldPath)
+	if err != nil {
+		return nil, err
+	}
+	file := &patchedFile{patch: p, target: target, source: source, perm: 0644}
+	var lines []string
+	// 原文件末尾是否有换行
+	eofNewline := true
+	if p.IsNew {
+		if _, err := os.Lstat(target); err == nil {
+			return nil, errors.New("file already exists")
+		}
+	} else {
+		info, err := os.Lstat(source)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, errors.New("not a regular file")
+		}
+		file.perm = info.Mode().Perm()
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		lines, eofNewline = splitPatchLines(string(data))
+		if p.IsRename && !p.IsDelete && target != source {
+			if _, err := os.Lstat(target); err == nil {
+				return nil, errors.New("file already exists")
+			}
+		}
+	}
+	if p.NewMode != "" {
+		if mode, err := strconv.ParseUint(p.NewMode, 8, 32); err == nil {
+			file.perm = os.FileMode(mode).Perm()
+		}
+	}
+	// shift 为已应用的补丁块造成的行号偏移，minPos 为下一个补丁块可以匹配的最小位置
+	shift, minPos := 0, 0
+	for i, hunk := range p.Hunks {
+		var oldLines, newLines []string
+		var oldNoNewline, newNoNewline bool
+		for _, line := range hunk.Lines {
+			if line.Op != '+' {
+				oldLines = append(oldLines, line.Text)
+				oldNoNewline = oldNoNewline || line.NoNewline
+			}
+			if line.Op != '-' {
+				newLines = append(newLines, line.Text)
+				newNoNewline = newNoNewline || line.NoNewline
+			}
+		}
+		base := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			base = hunk.OldStart
+		}
+		pos := findHunkPosition(lines, oldLines, base+shift, minPos)
+		if pos < 0 {
+			fileResult.Failed = append(fileResult.Failed, i+1)
+			continue
+		}
+		replaced := make([]string, 0, len(lines)-len(oldLines)+len(newLines))
+		replaced = append(replaced, lines[:pos]...)
+		replaced = append(replaced, newLines...)
+		replaced = append(replaced, lines[pos+len(oldLines):]...)
+		atEnd := pos+len(oldLines) == len(lines)
+		lines = replaced
+		if atEnd {
+			if newNoNewline {
+				eofNewline = false
+			} else if oldNoNewline || len(newLines) > 0 {
+				eofNewline = true
+			}
+		}
+		shift = pos - base + len(newLines) - len(oldLines)
+		minPos = pos + len(newLines)
+		fileResult.Applied++
+	}
+	if len(fileResult.Failed) > 0 {
+		hunks := make([]string, 0, len(fileResult.Failed))
+		for _, n := range fileResult.Failed {
+			hunks = append(hunks, "#"+strconv.Itoa(n))
+		}
+		fileResult.Error = "hunk " + strings.Join(hunks, ",") + " context mismatch"
+		return nil, nil
+	}
+	if p.IsDelete {
+		if len(lines) > 0 {
+			return nil, errors.New("deleted file content does not match")
+		}
+		return file, nil
+	}
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 && eofNewline {
+		content += "\n"
+	}
+	file.content = []byte(content)
+	return file, nil
+}
+
+// findHunkPosition 从 expected 开始向两侧查找补丁块的上下文，找不到返回-1
+func findHunkPosition(lines, oldLines []string, expected, minPos int) int {
+	maxPos := len(lines) - len(oldLines)
+	if maxPos < minPos {
+		return -1
+	}
+	for offset := 0; ; offset++ {
+		before, after := expected-offset, expected+offset
+		if before < minPos && after > maxPos {
+			return -1
+		}
+		if after >= minPos && after <= maxPos && matchLines(lines[after:after+len(oldLines)], oldLines) {
+			return after
+		}
+		if offset > 0 && before >= minPos && before <= maxPos && matchLines(lines[before:before+len(oldLines)], oldLines) {
+			return before
+		}
+	}
+}
+
+func matchLines(a, b []string) bool {
+	for i := range b {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPatchLines 把文件内容拆分为行，返回末尾是否有换行
+func splitPatchLines(content string) ([]string, bool) {
+	if content == "" {
+		return nil, true
+	}
+	eofNewline := strings.HasSuffix(content, "\n")
+	content = strings.TrimSuffix(content, "\n")
+	return strings.Split(content, "\n"), eofNewline
+}
+
+// patchTargetPath 获取补丁中的文件在工作目录中的路径，不允许超出工作目录
+func patchTargetPath(root, name string) (string, error) {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("invalid path: %s", name)
+	}
+	target := filepath.Join(root, filepath.FromSlash(path.Clean(name)))
+	if target == root || !isWithinDir(root, target) {
+		return "", fmt.Errorf("path outside the work directory: %s", name)
+	}
+	// 不允许通过符号链接目录写入工作目录以外的位置
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(target)); err == nil && !isWithinDir(root, dir) {
+		return "", fmt.Errorf("path outside the work directory: %s", name)
+	}
+	return target, nil
+}
+
+// writePatchedFiles 先把所有文件写入临时文件，全部成功后再重命名，删除的文件最后删除
+func writePatchedFiles(files []*patchedFile) error {
+	temps := make(map[*patchedFile]string)
+	cleanup := func() {
+		for _, temp := range temps {
+			_ = os.Remove(temp)
+		}
+	}
+	for _, file := range files {
+		if file.patch.IsDelete {
+			continue
+		}
+		dir := filepath.Dir(file.target)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			cleanup()
+			return err
+		}
+		f, err := os.CreateTemp(dir, ".patch-*")
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[file] = f.Name()
+		_, err = f.Write(file.content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), file.perm)
+		}
+		if err != nil {
+			cleanup()
+			return err
+		}
+	}
+	for _, file := range files {
+		if temp, ok := temps[file]; ok {
+			if err := os.Rename(temp, file.target); err != nil {
+				cleanup()
+				return err
+			}
+			delete(temps, file)
+		}
+	}
+	for _, file := range files {
+		if file.patch.IsDelete || (file.patch.IsRename && file.source != file.target) {
+			if err := os.Remove(file.source); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
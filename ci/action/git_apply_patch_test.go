@@ -0,0 +1,258 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitApplyPatchNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitApplyPatchNode{})
+	var targetNodeType = "ci/gitApplyPatch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitApplyPatchNode{}, types.Configuration{
+			"strip":          1,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"strip": -1}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	apply := func(t *testing.T, dir string, config types.Configuration, patch string) (PatchResult, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.TEXT, types.NewMetadata(), patch))
+		var result PatchResult
+		if outMsg.DataType == types.JSON {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		}
+		return result, relationType, err
+	}
+	writeFile := func(t *testing.T, name, content string) {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), os.ModePerm))
+		assert.Nil(t, os.WriteFile(name, []byte(content), 0644))
+	}
+	readFile := func(name string) string {
+		data, _ := os.ReadFile(name)
+		return string(data)
+	}
+
+	t.Run("ModifyWithOffset", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "src", "a.txt"), "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+		// 补丁基于少了前两行的文件生成
+		patch := "--- a/src/a.txt\n+++ b/src/a.txt\n@@ -3,3 +3,3 @@\n 3\n-4\n+four\n 5\n"
+		result, relationType, err := apply(t, dir, types.Configuration{}, patch)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.True(t, result.Applied)
+		assert.Equal(t, 1, len(result.Files))
+		assert.Equal(t, "src/a.txt", result.Files[0].Path)
+		assert.Equal(t, ChangeTypeModify, result.Files[0].ChangeType)
+		assert.Equal(t, 1, result.Files[0].Applied)
+		assert.Equal(t, "0\n1\n2\n3\nfour\n5\n6\n7\n8\n9\n", readFile(filepath.Join(dir, "src", "a.txt")))
+	})
+
+	t.Run("GitExtendedHeaders", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "old.txt"), "gone\n")
+		writeFile(t, filepath.Join(dir, "from.txt"), "a\nb\n")
+		writeFile(t, filepath.Join(dir, "run.sh"), "echo hi\n")
+		patch := strings.Join([]string{
+			"diff --git a/new.txt b/new.txt",
+			"new file mode 100644",
+			"index 0000000..1111111",
+			"--- /dev/null",
+			"+++ b/new.txt",
+			"@@ -0,0 +1,2 @@",
+			"+hello",
+			"+world",
+			"diff --git a/old.txt b/old.txt",
+			"deleted file mode 100644",
+			"--- a/old.txt",
+			"+++ /dev/null",
+			"@@ -1 +0,0 @@",
+			"-gone",
+			"diff --git a/from.txt b/dir/to.txt",
+			"similarity index 50%",
+			"rename from from.txt",
+			"rename to dir/to.txt",
+			"--- a/from.txt",
+			"+++ b/dir/to.txt",
+			"@@ -1,2 +1,2 @@",
+			" a",
+			"-b",
+			"+c",
+			"diff --git a/run.sh b/run.sh",
+			"old mode 100644",
+			"new mode 100755",
+			"",
+		}, "\n")
+		result, relationType, err := apply(t, dir, types.Configuration{}, patch)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, 4, len(result.Files))
+		assert.Equal(t, ChangeTypeAdd, result.Files[0].ChangeType)
+		assert.Equal(t, ChangeTypeDelete, result.Files[1].ChangeType)
+		assert.Equal(t, ChangeTypeRename, result.Files[2].ChangeType)
+		assert.Equal(t, "from.txt", result.Files[2].OldPath)
+		assert.Equal(t, "100755", result.Files[3].Mode)
+
+		assert.Equal(t, "hello\nworld\n", readFile(filepath.Join(dir, "new.txt")))
+		_, err = os.Stat(filepath.Join(dir, "old.txt"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "from.txt"))
+		assert.True(t, os.IsNotExist(err))
+		assert.Equal(t, "a\nc\n", readFile(filepath.Join(dir, "dir", "to.txt")))
+		info, err := os.Stat(filepath.Join(dir, "run.sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		assert.Equal(t, "echo hi\n", readFile(filepath.Join(dir, "run.sh")))
+	})
+
+	t.Run("NoNewlineAtEnd", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.txt"), "a\nb")
+		patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n"
+		_, _, err := apply(t, dir, types.Configuration{}, patch)
+		assert.Nil(t, err)
+		assert.Equal(t, "a\nc\n", readFile(filepath.Join(dir, "a.txt")))
+
+		patch = "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n a\n-c\n+d\n\\ No newline at end of file\n"
+		_, _, err = apply(t, dir, types.Configuration{}, patch)
+		assert.Nil(t, err)
+		assert.Equal(t, "a\nd", readFile(filepath.Join(dir, "a.txt")))
+	})
+
+	t.Run("ContextMismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.txt"), "a\nb\n")
+		writeFile(t, filepath.Join(dir, "b.txt"), "1\n2\n3\n4\n5\n6\n7\n8\n")
+		patch := strings.Join([]string{
+			"--- a/a.txt",
+			"+++ b/a.txt",
+			"@@ -1,2 +1,2 @@",
+			" a",
+			"-b",
+			"+B",
+			"--- a/b.txt",
+			"+++ b/b.txt",
+			"@@ -1,2 +1,2 @@",
+			" 1",
+			"-2",
+			"+two",
+			"@@ -6,3 +6,3 @@",
+			" 6",
+			"-x",
+			"+y",
+			" 8",
+			"",
+		}, "\n")
+		result, relationType, err := apply(t, dir, types.Configuration{}, patch)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "b.txt"))
+		assert.Equal(t, types.Failure, relationType)
+		assert.False(t, result.Applied)
+		assert.Equal(t, 2, len(result.Files))
+		assert.Equal(t, "", result.Files[0].Error)
+		assert.Equal(t, 2, result.Files[1].Hunks)
+		assert.Equal(t, 1, result.Files[1].Applied)
+		assert.Equal(t, []int{2}, result.Files[1].Failed)
+		// 没有修改任何文件
+		assert.Equal(t, "a\nb\n", readFile(filepath.Join(dir, "a.txt")))
+		assert.Equal(t, "1\n2\n3\n4\n5\n6\n7\n8\n", readFile(filepath.Join(dir, "b.txt")))
+		entries, _ := os.ReadDir(dir)
+		assert.Equal(t, 2, len(entries))
+	})
+
+	t.Run("PatchFile", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.txt"), "a\n")
+		patchFile := filepath.Join(t.TempDir(), "fix.patch")
+		writeFile(t, patchFile, "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+b\n")
+		_, _, err := apply(t, dir, types.Configuration{"patchFile": patchFile, "strip": 0}, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "b\n", readFile(filepath.Join(dir, "a.txt")))
+	})
+
+	t.Run("OutsideWorkDir", func(t *testing.T) {
+		dir := t.TempDir()
+		_, relationType, err := apply(t, dir, types.Configuration{}, "--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		_, err = os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("InMemory", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "src", "a.txt"), "a\n")
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"directory": dir, "appendRepoName": false}, Registry)
+		assert.Nil(t, err)
+		metadata := types.NewMetadata()
+		metadata.PutValue(KeyRepoId, "memory")
+		patch := "--- a/src/a.txt\n+++ b/src/a.txt\n@@ -1 +1 @@\n-a\n+b\n"
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.TEXT, metadata, patch))
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, "apply patch is not supported for in-memory repository", err.Error())
+		// 本地文件没有被修改
+		assert.Equal(t, "a\n", readFile(filepath.Join(dir, "src", "a.txt")))
+	})
+
+	t.Run("InvalidPatch", func(t *testing.T) {
+		_, relationType, err := apply(t, t.TempDir(), types.Configuration{}, "not a patch")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("GitGeneratedPatch", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		base := commitTestFile(t, r, "src/main.go", "package main\n\nfunc main() {\n\tprintln(1)\n}\n", "add main")
+		commitTestFile(t, r, "src/main.go", "package main\n\nfunc main() {\n\tprintln(2)\n\tprintln(3)\n}\n", "update main")
+		head := commitTestFile(t, r, "docs/guide.md", "# guide\n", "add guide")
+		baseCommit, err := r.CommitObject(base)
+		assert.Nil(t, err)
+		headCommit, err := r.CommitObject(head)
+		assert.Nil(t, err)
+		patch, err := baseCommit.Patch(headCommit)
+		assert.Nil(t, err)
+
+		w, err := r.Worktree()
+		assert.Nil(t, err)
+		assert.Nil(t, w.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset}))
+		_, _, err = apply(t, dir, types.Configuration{}, patch.String())
+		assert.Nil(t, err)
+		assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(2)\n\tprintln(3)\n}\n", readFile(filepath.Join(dir, "src", "main.go")))
+		assert.Equal(t, "# guide\n", readFile(filepath.Join(dir, "docs", "guide.md")))
+	})
+}
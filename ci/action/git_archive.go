@@ -0,0 +1,305 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitArchiveNode{})
+}
+
+const (
+	// KeyArchiveFile 归档文件路径
+	KeyArchiveFile = "archiveFile"
+	// KeyArchiveSize 归档文件大小，单位字节
+	KeyArchiveSize = "archiveSize"
+	// KeyArchiveEntries 归档文件包含的文件数量
+	KeyArchiveEntries = "archiveEntries"
+)
+
+const (
+	// ArchiveFormatTarGz tar.gz 格式
+	ArchiveFormatTarGz = "tar.gz"
+	// ArchiveFormatZip zip 格式
+	ArchiveFormatZip = "zip"
+)
+
+// GitArchiveNodeConfiguration 节点配置
+type GitArchiveNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 需要归档的引用，可以是标签、分支或者提交hash，默认HEAD
+	Ref string
+	// 归档格式，可以是 tar.gz 或 zip，默认tar.gz
+	Format string
+	// 输出的归档文件路径，例如：/data/release/${metadata.tag}.tar.gz
+	TargetFile string
+	// 只归档这些路径前缀下的文件，多个与逗号隔开，例如：src/,README.md，为空则归档所有文件
+	PathPrefixes string
+	// 归档中每个文件路径前添加的前缀，与 git archive --prefix 一致，例如：rulego-v1.0.0/
+	Prefix string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitArchiveNode 把指定引用的提交树导出为 tar.gz 或 zip 归档文件，只包含已提交的内容，不包含 .git 目录和工作区中的未跟踪文件
+// 文件逐个从对象库流式写入归档，归档文件路径、大小和文件数量写入元数据 archiveFile、archiveSize 和 archiveEntries
+type GitArchiveNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitArchiveNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitArchiveNode) Type() string {
+	return "ci/gitArchive"
+}
+
+func (x *GitArchiveNode) New() types.Node {
+	return &GitArchiveNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitArchiveNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			Format:         ArchiveFormatTarGz,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitArchiveNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Format = strings.ToLower(strings.TrimSpace(x.Config.Format))
+	switch x.Config.Format {
+	case "", "tgz":
+		x.Config.Format = ArchiveFormatTarGz
+	case ArchiveFormatTarGz, ArchiveFormatZip:
+	default:
+		return fmt.Errorf("unsupported archive format: %s", x.Config.Format)
+	}
+	if strings.TrimSpace(x.Config.TargetFile) == "" {
+		return errors.New("targetFile can not be empty")
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.TargetFile) ||
+		str.CheckHasVar(x.Config.PathPrefixes) || str.CheckHasVar(x.Config.Prefix) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitArchiveNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	targetFile := filepath.Clean(x.getValue(x.Config.TargetFile, evn))
+	entries, err := x.archive(commit, targetFile, splitPathPrefixes(x.getValue(x.Config.PathPrefixes, evn)), x.getValue(x.Config.Prefix, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	info, err := os.Stat(targetFile)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyArchiveFile), targetFile)
+	msg.Metadata.PutValue(x.metaKey(KeyArchiveSize), strconv.FormatInt(info.Size(), 10))
+	msg.Metadata.PutValue(x.metaKey(KeyArchiveEntries), strconv.Itoa(entries))
+	msg.Metadata.PutValue(x.metaKey(KeyCommitHash), commit.Hash.String())
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitArchiveNode) Destroy() {
+}
+
+// archive 把提交树写入归档文件，先写入同目录下的临时文件，成功后再重命名，避免留下不完整的归档，返回文件数量
+func (x *GitArchiveNode) archive(commit *object.Commit, targetFile string, pathPrefixes []string, prefix string) (int, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(targetFile), os.ModePerm); err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(targetFile), "."+filepath.Base(targetFile)+".*")
+	if err != nil {
+		return 0, err
+	}
+	tmpFile := f.Name()
+	defer os.Remove(tmpFile)
+	var entries int
+	if x.Config.Format == ArchiveFormatZip {
+		entries, err = writeZipArchive(f, tree, commit.Committer.When, pathPrefixes, prefix)
+	} else {
+		entries, err = writeTarGzArchive(f, tree, commit.Committer.When, pathPrefixes, prefix)
+	}
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entries, os.Rename(tmpFile, targetFile)
+}
+
+func (x *GitArchiveNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// walkArchiveFiles 遍历提交树中匹配路径前缀的文件
+func walkArchiveFiles(tree *object.Tree, pathPrefixes []string, fn func(file *object.File) error) (int, error) {
+	var entries int
+	err := tree.Files().ForEach(func(file *object.File) error {
+		if !matchPathPrefixes(pathPrefixes, file.Name) {
+			return nil
+		}
+		entries++
+		return fn(file)
+	})
+	return entries, err
+}
+
+// archiveFileMode 获取文件在归档中的权限
+func archiveFileMode(file *object.File) os.FileMode {
+	switch file.Mode {
+	case filemode.Executable:
+		return 0755
+	case filemode.Symlink:
+		return os.ModeSymlink | 0777
+	default:
+		return 0644
+	}
+}
+
+// writeTarGzArchive 以 tar.gz 格式写入归档
+func writeTarGzArchive(w io.Writer, tree *object.Tree, modTime time.Time, pathPrefixes []string, prefix string) (int, error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	entries, err := walkArchiveFiles(tree, pathPrefixes, func(file *object.File) error {
+		header := &tar.Header{
+			Name:    path.Join(prefix, file.Name),
+			Mode:    int64(archiveFileMode(file).Perm()),
+			Size:    file.Size,
+			ModTime: modTime,
+		}
+		if file.Mode == filemode.Symlink {
+			target, err := file.Contents()
+			if err != nil {
+				return err
+			}
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = target
+			header.Size = 0
+			return tw.WriteHeader(header)
+		}
+		header.Typeflag = tar.TypeReg
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		return copyFileContent(tw, file)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = tw.Close(); err != nil {
+		return 0, err
+	}
+	return entries, gw.Close()
+}
+
+// writeZipArchive 以 zip 格式写入归档
+func writeZipArchive(w io.Writer, tree *object.Tree, modTime time.Time, pathPrefixes []string, prefix string) (int, error) {
+	zw := zip.NewWriter(w)
+	entries, err := walkArchiveFiles(tree, pathPrefixes, func(file *object.File) error {
+		header := &zip.FileHeader{
+			Name:     path.Join(prefix, file.Name),
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		header.SetMode(archiveFileMode(file))
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		return copyFileContent(writer, file)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return entries, zw.Close()
+}
+
+// copyFileContent 把文件内容流式写入归档
+func copyFileContent(w io.Writer, file *object.File) error {
+	reader, err := file.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(w, reader)
+	return err
+}
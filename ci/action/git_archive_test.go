@@ -0,0 +1,146 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestGitArchiveNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitArchiveNode{})
+	var targetNodeType = "ci/gitArchive"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitArchiveNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"format":         "tar.gz",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"targetFile": "/tmp/a.rar",
+			"format":     "rar",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	commitTestFile(t, r, "src/main.go", "package main", "add main")
+	tagHash := commitTestFile(t, r, "docs/guide.md", "guide", "add docs")
+	_, err := r.CreateTag("v1.0.0", tagHash, nil)
+	assert.Nil(t, err)
+	commitTestFile(t, r, "src/main.go", "package main\n// v2", "modify main")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "junk.txt"), []byte("junk"), 0644))
+
+	archive := func(t *testing.T, config types.Configuration) types.RuleMsg {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		metadata := types.NewMetadata()
+		metadata.PutValue("tag", "v1.0.0")
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		info, err := os.Stat(outMsg.Metadata.GetValue(KeyArchiveFile))
+		assert.Nil(t, err)
+		assert.Equal(t, strconv.FormatInt(info.Size(), 10), outMsg.Metadata.GetValue(KeyArchiveSize))
+		return outMsg
+	}
+
+	t.Run("TarGz", func(t *testing.T) {
+		targetFile := filepath.Join(t.TempDir(), "release", "${metadata.tag}.tar.gz")
+		outMsg := archive(t, types.Configuration{
+			"ref":        "${metadata.tag}",
+			"targetFile": targetFile,
+			"prefix":     "rulego-${metadata.tag}/",
+		})
+		assert.Equal(t, "3", outMsg.Metadata.GetValue(KeyArchiveEntries))
+		assert.Equal(t, tagHash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+
+		f, err := os.Open(outMsg.Metadata.GetValue(KeyArchiveFile))
+		assert.Nil(t, err)
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		assert.Nil(t, err)
+		tr := tar.NewReader(gr)
+		files := make(map[string]string)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			content, _ := io.ReadAll(tr)
+			files[header.Name] = string(content)
+		}
+		assert.Equal(t, map[string]string{
+			"rulego-v1.0.0/README.md":     "# test",
+			"rulego-v1.0.0/src/main.go":   "package main",
+			"rulego-v1.0.0/docs/guide.md": "guide",
+		}, files)
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		targetFile := filepath.Join(t.TempDir(), "src.zip")
+		outMsg := archive(t, types.Configuration{
+			"format":       "zip",
+			"targetFile":   targetFile,
+			"pathPrefixes": "src/",
+		})
+		assert.Equal(t, "1", outMsg.Metadata.GetValue(KeyArchiveEntries))
+		zr, err := zip.OpenReader(targetFile)
+		assert.Nil(t, err)
+		defer zr.Close()
+		assert.Equal(t, 1, len(zr.File))
+		assert.Equal(t, "src/main.go", zr.File[0].Name)
+		rc, err := zr.File[0].Open()
+		assert.Nil(t, err)
+		content, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		assert.Equal(t, "package main\n// v2", string(content))
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      dir,
+			"appendRepoName": false,
+			"ref":            "v9.9.9",
+			"targetFile":     filepath.Join(t.TempDir(), "a.tar.gz"),
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
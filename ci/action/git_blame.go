@@ -0,0 +1,271 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitBlameNode{})
+}
+
+// ErrBinaryFile 二进制文件不支持逐行追溯
+var ErrBinaryFile = errors.New("binary file can not be blamed")
+
+// GitBlameNodeConfiguration 节点配置
+type GitBlameNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 需要追溯的文件，相对仓库根目录，为空则使用 msg.Data
+	FilePath string
+	// 追溯的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 行范围，从1开始，包含两端，例如：10-40，为空则输出所有行
+	LineRange string
+}
+
+// BlameLine 每一行的追溯信息
+type BlameLine struct {
+	// 行号，从1开始
+	Line int `json:"line"`
+	// 作者名称
+	AuthorName string `json:"authorName"`
+	// 作者邮箱
+	AuthorEmail string `json:"authorEmail"`
+	// 最后修改该行的提交hash
+	CommitHash string `json:"commitHash"`
+	// 最后修改该行的时间
+	Time time.Time `json:"time"`
+	// 行内容
+	Text string `json:"text"`
+}
+
+// BlameAuthor 作者汇总
+type BlameAuthor struct {
+	// 作者名称
+	Name string `json:"name"`
+	// 作者邮箱
+	Email string `json:"email"`
+	// 行数
+	Lines int `json:"lines"`
+}
+
+// BlameOutput 追溯结果
+type BlameOutput struct {
+	// 文件路径
+	Path string `json:"path"`
+	// 追溯的提交hash
+	CommitHash string `json:"commitHash"`
+	// 每一行的追溯信息
+	Lines []BlameLine `json:"lines"`
+	// 按行数从多到少排列的作者
+	Authors []BlameAuthor `json:"authors"`
+}
+
+// GitBlameNode 追溯文件每一行的最后修改者，用于自动分配代码评审或者通知文件作者，结果以JSON的形式写入 msg.Data
+// 二进制文件或者文件在该引用下不存在时发送到Failure链
+type GitBlameNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitBlameNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitBlameNode) Type() string {
+	return "ci/gitBlame"
+}
+
+func (x *GitBlameNode) New() types.Node {
+	return &GitBlameNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitBlameNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitBlameNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.FilePath) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.LineRange) {
+		x.hasVar = true
+	} else if err == nil {
+		_, _, err = parseLineRange(x.Config.LineRange)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitBlameNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	filePath := x.getValue(x.Config.FilePath, evn)
+	if filePath == "" {
+		filePath = strings.TrimSpace(msg.Data)
+	}
+	filePath = strings.TrimPrefix(strings.ReplaceAll(filePath, "\\", "/"), "./")
+	if filePath == "" {
+		ctx.TellFailure(msg, errors.New("filePath can not be empty"))
+		return
+	}
+	start, end, err := parseLineRange(x.getValue(x.Config.LineRange, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	output, err := x.blame(commit, filePath, start, end)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitBlameNode) Destroy() {
+}
+
+// blame 追溯文件，start 和 end 为0表示不限制
+func (x *GitBlameNode) blame(commit *object.Commit, filePath string, start, end int) (BlameOutput, error) {
+	output := BlameOutput{Path: filePath, CommitHash: commit.Hash.String(), Lines: make([]BlameLine, 0), Authors: make([]BlameAuthor, 0)}
+	file, err := commit.File(filePath)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return output, fmt.Errorf("file %s does not exist at %s", filePath, commit.Hash)
+	} else if err != nil {
+		return output, err
+	}
+	if binary, err := file.IsBinary(); err != nil {
+		return output, err
+	} else if binary {
+		return output, fmt.Errorf("%w: %s", ErrBinaryFile, filePath)
+	}
+	result, err := git.Blame(commit, filePath)
+	if err != nil {
+		return output, err
+	}
+	if end == 0 || end > len(result.Lines) {
+		end = len(result.Lines)
+	}
+	if start == 0 {
+		start = 1
+	}
+	authors := make(map[string]*BlameAuthor)
+	for i := start; i <= end; i++ {
+		line := result.Lines[i-1]
+		output.Lines = append(output.Lines, BlameLine{
+			Line:        i,
+			AuthorName:  line.AuthorName,
+			AuthorEmail: line.Author,
+			CommitHash:  line.Hash.String(),
+			Time:        line.Date,
+			Text:        line.Text,
+		})
+		key := line.AuthorName + "<" + line.Author + ">"
+		author, ok := authors[key]
+		if !ok {
+			author = &BlameAuthor{Name: line.AuthorName, Email: line.Author}
+			authors[key] = author
+		}
+		author.Lines++
+	}
+	for _, author := range authors {
+		output.Authors = append(output.Authors, *author)
+	}
+	sort.Slice(output.Authors, func(i, j int) bool {
+		if output.Authors[i].Lines != output.Authors[j].Lines {
+			return output.Authors[i].Lines > output.Authors[j].Lines
+		}
+		return output.Authors[i].Email < output.Authors[j].Email
+	})
+	return output, nil
+}
+
+func (x *GitBlameNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// parseLineRange 解析行范围，例如：10-40、10、10-，为空返回0,0
+func parseLineRange(lineRange string) (int, int, error) {
+	lineRange = strings.TrimSpace(lineRange)
+	if lineRange == "" {
+		return 0, 0, nil
+	}
+	startValue, endValue, found := strings.Cut(lineRange, "-")
+	start, err := strconv.Atoi(strings.TrimSpace(startValue))
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid line range: %s", lineRange)
+	}
+	if !found {
+		return start, start, nil
+	}
+	if endValue = strings.TrimSpace(endValue); endValue == "" {
+		return start, 0, nil
+	}
+	end, err := strconv.Atoi(endValue)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid line range: %s", lineRange)
+	}
+	return start, end, nil
+}
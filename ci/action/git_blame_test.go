@@ -0,0 +1,122 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitBlameNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitBlameNode{})
+	var targetNodeType = "ci/gitBlame"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitBlameNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		for _, lineRange := range []string{"a-b", "0-3", "5-2"} {
+			_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+				"lineRange": lineRange,
+			}, Registry)
+			assert.NotNil(t, err)
+		}
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	first := commitTestFile(t, r, "src/main.go", "line1\nline2\nline3\n", "add main")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("line1\nline2 changed\nline3\nline4\n"), 0644))
+	w, _ := r.Worktree()
+	_, err := w.Add("src/main.go")
+	assert.Nil(t, err)
+	second, err := w.Commit("modify main", &git.CommitOptions{
+		Author: &object.Signature{Name: "alice", Email: "alice@rulego.cc", When: time.Now()},
+	})
+	assert.Nil(t, err)
+	commitTestFile(t, r, "logo.png", "\x89PNG\x00\x00\x01", "add logo")
+
+	blame := func(t *testing.T, config types.Configuration, data string) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.TEXT, types.NewMetadata(), data))
+	}
+
+	t.Run("Blame", func(t *testing.T) {
+		outMsg, relationType, err := blame(t, types.Configuration{}, "src/main.go")
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var output BlameOutput
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &output))
+		assert.Equal(t, "src/main.go", output.Path)
+		assert.Equal(t, 4, len(output.Lines))
+		assert.Equal(t, first.String(), output.Lines[0].CommitHash)
+		assert.Equal(t, "rulego@rulego.cc", output.Lines[0].AuthorEmail)
+		assert.Equal(t, second.String(), output.Lines[1].CommitHash)
+		assert.Equal(t, "alice", output.Lines[1].AuthorName)
+		assert.Equal(t, "line2 changed", output.Lines[1].Text)
+		assert.Equal(t, []BlameAuthor{
+			{Name: "alice", Email: "alice@rulego.cc", Lines: 2},
+			{Name: "rulego", Email: "rulego@rulego.cc", Lines: 2},
+		}, output.Authors)
+	})
+
+	t.Run("LineRange", func(t *testing.T) {
+		outMsg, _, err := blame(t, types.Configuration{
+			"filePath":  "src/main.go",
+			"ref":       first.String(),
+			"lineRange": "2-10",
+		}, "")
+		assert.Nil(t, err)
+		var output BlameOutput
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &output))
+		assert.Equal(t, 2, len(output.Lines))
+		assert.Equal(t, 2, output.Lines[0].Line)
+		assert.Equal(t, "line2", output.Lines[0].Text)
+		assert.Equal(t, []BlameAuthor{{Name: "rulego", Email: "rulego@rulego.cc", Lines: 2}}, output.Authors)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		_, relationType, err := blame(t, types.Configuration{"filePath": "logo.png"}, "")
+		assert.True(t, errors.Is(err, ErrBinaryFile))
+		assert.Equal(t, types.Failure, relationType)
+
+		_, relationType, err = blame(t, types.Configuration{"ref": first.String()}, "logo.png")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		_, relationType, err = blame(t, types.Configuration{}, "")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
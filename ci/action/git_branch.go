@@ -0,0 +1,293 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitBranchNode{})
+}
+
+const (
+	// BranchActionCreate 创建分支
+	BranchActionCreate = "create"
+	// BranchActionDelete 删除分支
+	BranchActionDelete = "delete"
+	// BranchActionList 列出所有本地分支
+	BranchActionList = "list"
+)
+
+var (
+	// ErrBranchExists 分支已经存在
+	ErrBranchExists = errors.New("branch already exists")
+	// ErrBranchNotFound 分支不存在
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrBranchNotMerged 分支没有合并到当前HEAD，需要使用 Force 删除
+	ErrBranchNotMerged = errors.New("branch is not fully merged")
+	// ErrBranchCheckedOut 不能删除当前检出的分支
+	ErrBranchCheckedOut = errors.New("cannot delete the currently checked out branch")
+)
+
+// GitBranchNodeConfiguration 节点配置
+type GitBranchNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 "create"、"delete" 或 "list"
+	Action string
+	// 分支名称，例如：release/1.0，支持${metadata.xx}变量
+	Branch string
+	// 创建分支的起点，可以是引用或者提交hash，默认HEAD
+	StartPoint string
+	// 删除没有合并到当前HEAD的分支
+	Force bool
+	// 列出分支时判断是否已合并的基准引用，默认HEAD
+	Base string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// BranchInfo 分支信息
+type BranchInfo struct {
+	// 分支名称
+	Name string `json:"name"`
+	// 分支最新提交
+	Hash string `json:"hash"`
+	// 是否已合并到基准引用
+	Merged bool `json:"merged"`
+	// 是否是当前检出的分支
+	Current bool `json:"current"`
+}
+
+// GitBranchNode 创建、删除或者列出本地分支
+type GitBranchNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitBranchNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitBranchNode) Type() string {
+	return "ci/gitBranch"
+}
+
+func (x *GitBranchNode) New() types.Node {
+	return &GitBranchNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitBranchNodeConfiguration{
+			Action:         BranchActionList,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitBranchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Branch) || str.CheckHasVar(x.Config.StartPoint) || str.CheckHasVar(x.Config.Base) {
+		x.hasVar = true
+	}
+	if err == nil {
+		switch x.Config.Action {
+		case BranchActionCreate, BranchActionDelete:
+			if strings.TrimSpace(x.Config.Branch) == "" {
+				err = errors.New("branch can not be empty")
+			}
+		case BranchActionList:
+		default:
+			err = errors.New("not action=" + x.Config.Action)
+		}
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitBranchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	switch x.Config.Action {
+	case BranchActionCreate:
+		err = x.create(r, msg, x.getValue(x.Config.Branch, evn), x.getValue(x.Config.StartPoint, evn))
+	case BranchActionDelete:
+		err = x.delete(r, msg, x.getValue(x.Config.Branch, evn))
+	default:
+		err = x.list(r, &msg, x.getValue(x.Config.Base, evn))
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// Destroy 销毁
+func (x *GitBranchNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// create 基于起点创建分支，分支最新提交写入元数据hash
+func (x *GitBranchNode) create(r *git.Repository, msg types.RuleMsg, branch, startPoint string) error {
+	name := branchReferenceName(branch)
+	if _, err := r.Reference(name, false); err == nil {
+		return fmt.Errorf("%w: %s", ErrBranchExists, name.Short())
+	}
+	if startPoint == "" {
+		startPoint = string(plumbing.HEAD)
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(startPoint))
+	if err != nil {
+		return fmt.Errorf("start point %s not found: %w", startPoint, err)
+	}
+	if err = r.Storer.SetReference(plumbing.NewHashReference(name, *hash)); err != nil {
+		return err
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), hash.String())
+	return nil
+}
+
+// delete 删除分支，分支没有合并到当前HEAD时需要 Force
+func (x *GitBranchNode) delete(r *git.Repository, msg types.RuleMsg, branch string) error {
+	name := branchReferenceName(branch)
+	ref, err := r.Reference(name, false)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBranchNotFound, name.Short())
+	}
+	head, err := r.Head()
+	if err == nil && head.Name() == name {
+		return fmt.Errorf("%w: %s", ErrBranchCheckedOut, name.Short())
+	}
+	if !x.Config.Force && err == nil {
+		if merged, err := isMerged(r, ref.Hash(), head.Hash()); err != nil {
+			return err
+		} else if !merged {
+			return fmt.Errorf("%w: %s", ErrBranchNotMerged, name.Short())
+		}
+	}
+	if err = r.Storer.RemoveReference(name); err != nil {
+		return err
+	}
+	// 同时删除分支的跟踪配置
+	if err = r.DeleteBranch(name.Short()); err != nil && !errors.Is(err, git.ErrBranchNotFound) {
+		return err
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), ref.Hash().String())
+	return nil
+}
+
+// list 列出所有本地分支，以及是否已合并到基准引用
+func (x *GitBranchNode) list(r *git.Repository, msg *types.RuleMsg, baseRef string) error {
+	if baseRef == "" {
+		baseRef = string(plumbing.HEAD)
+	}
+	baseHash, err := r.ResolveRevision(plumbing.Revision(baseRef))
+	if err != nil {
+		return fmt.Errorf("base %s not found: %w", baseRef, err)
+	}
+	var current plumbing.ReferenceName
+	if head, err := r.Head(); err == nil {
+		current = head.Name()
+	}
+	branches, err := r.Branches()
+	if err != nil {
+		return err
+	}
+	items := make([]BranchInfo, 0)
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		merged, err := isMerged(r, ref.Hash(), *baseHash)
+		if err != nil {
+			return err
+		}
+		items = append(items, BranchInfo{
+			Name:    ref.Name().Short(),
+			Hash:    ref.Hash().String(),
+			Merged:  merged,
+			Current: ref.Name() == current,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	return nil
+}
+
+func (x *GitBranchNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// branchReferenceName 把分支名称转换成完整的分支引用名
+func branchReferenceName(branch string) plumbing.ReferenceName {
+	if name := plumbing.ReferenceName(branch); name.IsBranch() {
+		return name
+	}
+	return plumbing.NewBranchReferenceName(branch)
+}
+
+// isMerged 判断提交是否已经合并到基准提交，即是否是基准提交的祖先
+func isMerged(r *git.Repository, hash, base plumbing.Hash) (bool, error) {
+	if hash == base {
+		return true, nil
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return false, err
+	}
+	baseCommit, err := r.CommitObject(base)
+	if err != nil {
+		return false, err
+	}
+	return commit.IsAncestor(baseCommit)
+}
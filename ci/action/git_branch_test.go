@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestGitBranchNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitBranchNode{})
+	var targetNodeType = "ci/gitBranch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitBranchNode{}, types.Configuration{
+			"action":         "list",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"action": "unknown",
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"action": "create",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	initHead, _ := r.Head()
+	run := func(t *testing.T, configuration types.Configuration, metaData types.Metadata) (types.RuleMsg, string, error) {
+		configuration["directory"] = dir
+		configuration["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("version", "1.0")
+		outMsg, relationType, err := run(t, types.Configuration{"action": "create", "branch": "release/${metadata.version}"}, metaData)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, initHead.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+		ref, err := r.Reference(plumbing.NewBranchReferenceName("release/1.0"), false)
+		assert.Nil(t, err)
+		assert.Equal(t, initHead.Hash(), ref.Hash())
+
+		_, relationType, err = run(t, types.Configuration{"action": "create", "branch": "release/${metadata.version}"}, metaData)
+		assert.True(t, errors.Is(err, ErrBranchExists))
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	//main上新增提交，feature分支没有合并到main
+	featureHash := commitTestFile(t, r, "a.txt", "a", "add a")
+	w, _ := r.Worktree()
+	assert.Nil(t, w.Reset(&git.ResetOptions{Commit: initHead.Hash(), Mode: git.HardReset}))
+	_, _, err := run(t, types.Configuration{"action": "create", "branch": "feature", "startPoint": featureHash.String()}, types.NewMetadata())
+	assert.Nil(t, err)
+
+	t.Run("List", func(t *testing.T) {
+		outMsg, relationType, err := run(t, types.Configuration{"action": "list"}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var branches []BranchInfo
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &branches))
+		assert.Equal(t, []BranchInfo{
+			{Name: "feature", Hash: featureHash.String(), Merged: false},
+			{Name: "main", Hash: initHead.Hash().String(), Merged: true, Current: true},
+			{Name: "release/1.0", Hash: initHead.Hash().String(), Merged: true},
+		}, branches)
+
+		outMsg, _, err = run(t, types.Configuration{"action": "list", "base": "feature"}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &branches))
+		assert.True(t, branches[0].Merged)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, relationType, err := run(t, types.Configuration{"action": "delete", "branch": "notExist"}, types.NewMetadata())
+		assert.True(t, errors.Is(err, ErrBranchNotFound))
+		assert.Equal(t, types.Failure, relationType)
+
+		_, _, err = run(t, types.Configuration{"action": "delete", "branch": "main"}, types.NewMetadata())
+		assert.True(t, errors.Is(err, ErrBranchCheckedOut))
+
+		_, _, err = run(t, types.Configuration{"action": "delete", "branch": "feature"}, types.NewMetadata())
+		assert.True(t, errors.Is(err, ErrBranchNotMerged))
+
+		_, relationType, err = run(t, types.Configuration{"action": "delete", "branch": "feature", "force": true}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		_, relationType, err = run(t, types.Configuration{"action": "delete", "branch": "refs/heads/release/1.0"}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+
+		branches, _ := r.Branches()
+		count := 0
+		_ = branches.ForEach(func(ref *plumbing.Reference) error {
+			count++
+			return nil
+		})
+		assert.Equal(t, 1, count)
+	})
+}
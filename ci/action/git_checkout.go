@@ -0,0 +1,285 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitCheckoutNode{})
+}
+
+// KeyDetached 检出后是否处于分离HEAD状态
+const KeyDetached = "detached"
+
+// ErrDirtyWorktree 工作区有未提交的修改
+var ErrDirtyWorktree = errors.New("worktree contains uncommitted changes")
+
+// GitCheckoutNodeConfiguration 节点配置
+type GitCheckoutNodeConfiguration struct {
+	// Git 仓库 URL，为空则使用仓库配置的 origin 地址
+	Repository string
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 检出的引用，可以是分支名、完整引用名、标签或者提交hash，例如：main、refs/heads/main、v1.0.0、a1b2c3d
+	// 为空则使用元数据ref
+	Reference string
+	// 是否基于当前HEAD创建新的本地分支
+	Create bool
+	// 是否丢弃本地修改
+	Force bool
+	// 检出前是否先拉取远程分支和标签
+	FetchBeforeCheckout bool
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
+	ProxyUrl string
+	// 代理用户名
+	ProxyUsername string
+	// 代理密码
+	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitCheckoutNode 把已存在的本地仓库切换到分支、标签或者指定提交
+type GitCheckoutNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitCheckoutNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitCheckoutNode) Type() string {
+	return "ci/gitCheckout"
+}
+
+func (x *GitCheckoutNode) New() types.Node {
+	return &GitCheckoutNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCheckoutNodeConfiguration{
+			Reference:      "main",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitCheckoutNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Reference) || x.authHasVar() {
+		x.hasVar = true
+	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitCheckoutNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
+	ref := x.getReferenceName(msg, evn)
+	if ref == "" {
+		x.tellFailure(ctx, msg, errors.New("reference can not be empty"))
+		return
+	}
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if _, err = r.Head(); err != nil {
+		x.tellFailure(ctx, msg, fmt.Errorf("repository is empty: %w", err))
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if !x.Config.Force {
+		if dirty, err := isDirtyWorktree(w); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		} else if dirty {
+			x.tellFailure(ctx, msg, ErrDirtyWorktree)
+			return
+		}
+	}
+	if x.Config.FetchBeforeCheckout {
+		if err = x.fetch(ctx, msg, r, x.getRepository(msg, evn), evn); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+	}
+	checkoutOptions, err := x.getCheckoutOptions(r, ref)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if err = w.Checkout(checkoutOptions); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if _, err = x.putHeadMetadata(r, msg); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	head, err := r.Head()
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyDetached), strconv.FormatBool(head.Name() == plumbing.HEAD))
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitCheckoutNode) Destroy() {
+	x.destroyBase()
+}
+
+// fetch 拉取远程分支和标签
+func (x *GitCheckoutNode) fetch(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, repository string, evn map[string]interface{}) error {
+	fetchOptions := &git.FetchOptions{
+		RemoteURL:       repository,
+		Tags:            git.AllTags,
+		Force:           true,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		return err
+	} else if auth != nil {
+		fetchOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		fetchOptions.ProxyOptions = proxy
+	}
+	if err := x.execute(ctx, msg, "fetch", repository, func(opCtx context.Context) error {
+		return r.FetchContext(opCtx, fetchOptions)
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// getCheckoutOptions 解析引用，获取检出选项
+// 本地分支直接检出；只存在远程分支时基于远程分支创建本地分支；标签和提交hash以分离HEAD的方式检出
+func (x *GitCheckoutNode) getCheckoutOptions(r *git.Repository, ref string) (*git.CheckoutOptions, error) {
+	if x.Config.Create {
+		branch := plumbing.ReferenceName(ref)
+		if !branch.IsBranch() {
+			branch = plumbing.NewBranchReferenceName(ref)
+		}
+		return &git.CheckoutOptions{Branch: branch, Create: true, Force: x.Config.Force}, nil
+	}
+	name := strings.TrimPrefix(ref, "refs/heads/")
+	// 本地分支
+	branch := plumbing.NewBranchReferenceName(name)
+	if _, err := r.Reference(branch, false); err == nil {
+		return &git.CheckoutOptions{Branch: branch, Force: x.Config.Force}, nil
+	}
+	// 远程分支
+	if remoteRef, err := r.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, name), true); err == nil {
+		return &git.CheckoutOptions{Branch: branch, Hash: remoteRef.Hash(), Create: true, Force: x.Config.Force}, nil
+	}
+	if plumbing.ReferenceName(ref).IsBranch() {
+		return nil, fmt.Errorf("reference %s not found", ref)
+	}
+	// 标签、其他完整引用或者提交hash
+	var hash *plumbing.Hash
+	var err error
+	if strings.HasPrefix(ref, "refs/") {
+		hash, err = r.ResolveRevision(plumbing.Revision(ref))
+	} else if hash, err = r.ResolveRevision(plumbing.Revision(plumbing.NewTagReferenceName(ref))); err != nil {
+		hash, err = r.ResolveRevision(plumbing.Revision(ref))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reference %s not found: %w", ref, err)
+	}
+	return &git.CheckoutOptions{Hash: *hash, Force: x.Config.Force}, nil
+}
+
+// isDirtyWorktree 判断工作区是否有未提交的修改，未跟踪的文件不算修改
+func isDirtyWorktree(w *git.Worktree) (bool, error) {
+	status, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+	for _, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked && fileStatus.Worktree == git.Untracked {
+			continue
+		}
+		if fileStatus.Staging != git.Unmodified || fileStatus.Worktree != git.Unmodified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
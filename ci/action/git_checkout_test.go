@@ -0,0 +1,142 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitCheckoutNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCheckoutNode{})
+	var targetNodeType = "ci/gitCheckout"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitCheckoutNode{}, types.Configuration{
+			"reference":      "main",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	initHead, _ := remote.Head()
+	tagHash := commitTestFile(t, remote, "a.txt", "a", "add a")
+	_, err := remote.CreateTag("v1.0.0", tagHash, &git.CreateTagOptions{Tagger: &testSignature, Message: "v1.0.0"})
+	assert.Nil(t, err)
+	localDir := filepath.Join(tmp, "local")
+	local, err := git.PlainClone(localDir, false, &git.CloneOptions{URL: remoteDir})
+	assert.Nil(t, err)
+	//克隆后远程新增的分支
+	featureHash := commitTestFile(t, remote, "b.txt", "b", "add b")
+	assert.Nil(t, remote.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature"), featureHash)))
+
+	checkout := func(t *testing.T, configuration types.Configuration) (types.RuleMsg, string, error) {
+		configuration["directory"] = localDir
+		configuration["appendRepoName"] = false
+		configuration["authType"] = ""
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+
+	t.Run("Tag", func(t *testing.T) {
+		outMsg, relationType, err := checkout(t, types.Configuration{"reference": "v1.0.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, tagHash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyDetached))
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		outMsg, relationType, err := checkout(t, types.Configuration{"reference": initHead.Hash().String()})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, initHead.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyDetached))
+	})
+
+	t.Run("Branch", func(t *testing.T) {
+		outMsg, relationType, err := checkout(t, types.Configuration{"reference": "refs/heads/main"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "main", outMsg.Metadata.GetValue(KeyBranch))
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyDetached))
+	})
+
+	t.Run("FetchBeforeCheckout", func(t *testing.T) {
+		_, relationType, _ := checkout(t, types.Configuration{"reference": "feature"})
+		assert.Equal(t, types.Failure, relationType)
+
+		outMsg, relationType, err := checkout(t, types.Configuration{"reference": "feature", "fetchBeforeCheckout": true})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "feature", outMsg.Metadata.GetValue(KeyBranch))
+		assert.Equal(t, featureHash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		head, _ := local.Head()
+		outMsg, relationType, err := checkout(t, types.Configuration{"reference": "release", "create": true})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "release", outMsg.Metadata.GetValue(KeyBranch))
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		_, relationType, err := checkout(t, types.Configuration{"reference": "notExist"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("DirtyWorktree", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(filepath.Join(localDir, "README.md"), []byte("dirty"), 0644))
+		_, relationType, err := checkout(t, types.Configuration{"reference": "main"})
+		assert.Equal(t, ErrDirtyWorktree, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		_, relationType, err = checkout(t, types.Configuration{"reference": "main", "force": true})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		content, _ := os.ReadFile(filepath.Join(localDir, "README.md"))
+		assert.NotEqual(t, "dirty", string(content))
+	})
+
+	t.Run("EmptyRepository", func(t *testing.T) {
+		emptyDir := filepath.Join(t.TempDir(), "empty")
+		_, err := git.PlainInit(emptyDir, false)
+		assert.Nil(t, err)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      emptyDir,
+			"appendRepoName": false,
+			"reference":      "main",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
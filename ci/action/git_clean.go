@@ -0,0 +1,280 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitCleanNode{})
+}
+
+// GitCleanNodeConfiguration 节点配置
+type GitCleanNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 是否同时删除被 .gitignore 忽略的文件
+	IncludeIgnored bool
+	// 是否只列出需要删除的文件，不实际删除
+	DryRun bool
+	// 只删除匹配的文件，多个与逗号隔开，支持通配符，匹配相对仓库根目录的路径或者文件名，例如：dist/*,*.log，为空则不过滤
+	Paths string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// CleanResult 清理结果
+type CleanResult struct {
+	// 删除(DryRun时为需要删除)的文件，相对仓库根目录
+	Files []string `json:"files"`
+	// 释放的字节数
+	Bytes int64 `json:"bytes"`
+	// 是否只列出文件
+	DryRun bool `json:"dryRun"`
+}
+
+// GitCleanNode 删除工作区中未跟踪的文件，可选删除被忽略的文件，删除的文件列表和释放的字节数以JSON的形式写入 msg.Data
+// 不会跟随符号链接，符号链接本身作为文件删除，不会删除仓库根目录以外的任何文件
+type GitCleanNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitCleanNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitCleanNode) Type() string {
+	return "ci/gitClean"
+}
+
+func (x *GitCleanNode) New() types.Node {
+	return &GitCleanNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCleanNodeConfiguration{
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitCleanNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Paths) {
+		x.hasVar = true
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitCleanNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	// 内存仓库的工作区根目录是 "/"，按本地文件系统遍历会删除主机上的文件
+	if msg.Metadata.GetValue(x.metaKey(KeyRepoId)) != "" {
+		ctx.TellFailure(msg, errors.New("clean is not supported for in-memory repository"))
+		return
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, "", workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	paths := x.Config.Paths
+	if evn != nil {
+		paths = str.ExecuteTemplate(paths, evn)
+	}
+	result, err := x.clean(r, splitPathPrefixes(paths))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitCleanNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// clean 遍历工作区，删除没有被跟踪的文件
+// 没有使用 worktree.Status，因为它不返回被忽略的文件(IncludeIgnored)，并且会计算所有跟踪文件的hash，大仓库中很慢
+// 子模块和嵌套的仓库整个跳过，与 git clean 不加 -ff 时的行为一致
+func (x *GitCleanNode) clean(r *git.Repository, patterns []string) (CleanResult, error) {
+	result := CleanResult{Files: make([]string, 0), DryRun: x.Config.DryRun}
+	w, err := r.Worktree()
+	if err != nil {
+		return result, err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return result, err
+	}
+	tracked := make(map[string]bool, len(idx.Entries))
+	submodules := make(map[string]bool)
+	for _, entry := range idx.Entries {
+		tracked[entry.Name] = true
+		if entry.Mode == filemode.Submodule {
+			submodules[entry.Name] = true
+		}
+	}
+	ignorePatterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return result, err
+	}
+	matcher := gitignore.NewMatcher(append(ignorePatterns, w.Excludes...))
+	root, err := filepath.EvalSymlinks(w.Filesystem.Root())
+	if err != nil {
+		return result, err
+	}
+	dirs := make(map[string]bool)
+	err = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if name == git.GitDirName || submodules[name] {
+				return filepath.SkipDir
+			}
+			// 包含 .git 文件或者目录的子目录是嵌套的仓库或者子模块
+			if _, err := os.Lstat(filepath.Join(file, git.GitDirName)); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if tracked[name] {
+			return nil
+		}
+		if !x.Config.IncludeIgnored && matcher.Match(strings.Split(name, "/"), false) {
+			return nil
+		}
+		if !matchCleanPatterns(patterns, name) {
+			return nil
+		}
+		// WalkDir 不会跟随符号链接，这里再次确认没有越过仓库根目录
+		if !isWithinDir(root, file) {
+			return fmt.Errorf("refusing to clean %s outside of %s", file, root)
+		}
+		info, err := os.Lstat(file)
+		if err != nil {
+			return err
+		}
+		if !x.Config.DryRun {
+			if err = os.Remove(file); err != nil {
+				return err
+			}
+			for dir := filepath.Dir(file); dir != root && isWithinDir(root, dir); dir = filepath.Dir(dir) {
+				dirs[dir] = true
+			}
+		}
+		result.Files = append(result.Files, name)
+		if info.Mode().IsRegular() {
+			result.Bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if !x.Config.DryRun {
+		// 删除文件后变为空的目录，从最深的目录开始，非空目录会删除失败，忽略即可
+		emptyDirs := make([]string, 0, len(dirs))
+		for dir := range dirs {
+			emptyDirs = append(emptyDirs, dir)
+		}
+		sort.Slice(emptyDirs, func(i, j int) bool {
+			return len(emptyDirs[i]) > len(emptyDirs[j])
+		})
+		for _, dir := range emptyDirs {
+			_ = os.Remove(dir)
+		}
+	}
+	return result, nil
+}
+
+// matchCleanPatterns 判断文件路径或者文件名是否匹配任意通配符，没有通配符则都匹配
+func matchCleanPatterns(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+		// 以/结尾表示目录下的所有文件
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithinDir 判断文件是否在目录内
+func isWithinDir(dir, file string) bool {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitCleanNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCleanNode{})
+	var targetNodeType = "ci/gitClean"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitCleanNode{}, types.Configuration{
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	// prepare 创建包含未跟踪和被忽略文件的仓库
+	prepare := func(t *testing.T) string {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		commitTestFile(t, r, ".gitignore", "*.log\n", "add gitignore")
+		commitTestFile(t, r, "src/main.go", "package main", "add main")
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "dist", "js"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "dist", "js", "app.js"), []byte("app"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "src", "tmp.txt"), []byte("tmp"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "build.log"), []byte("log"), 0644))
+		return dir
+	}
+	clean := func(t *testing.T, dir string, config types.Configuration) CleanResult {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result CleanResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+	exists := func(name string) bool {
+		_, err := os.Lstat(name)
+		return err == nil
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		dir := prepare(t)
+		result := clean(t, dir, types.Configuration{"dryRun": true, "includeIgnored": true})
+		assert.True(t, result.DryRun)
+		assert.Equal(t, []string{"build.log", "dist/js/app.js", "src/tmp.txt"}, result.Files)
+		assert.Equal(t, int64(9), result.Bytes)
+		assert.True(t, exists(filepath.Join(dir, "build.log")))
+		assert.True(t, exists(filepath.Join(dir, "dist", "js", "app.js")))
+	})
+
+	t.Run("Untracked", func(t *testing.T) {
+		dir := prepare(t)
+		result := clean(t, dir, types.Configuration{})
+		assert.Equal(t, []string{"dist/js/app.js", "src/tmp.txt"}, result.Files)
+		assert.Equal(t, int64(6), result.Bytes)
+		assert.False(t, exists(filepath.Join(dir, "dist")))
+		assert.True(t, exists(filepath.Join(dir, "src", "main.go")))
+		assert.True(t, exists(filepath.Join(dir, "build.log")))
+		assert.True(t, exists(filepath.Join(dir, "README.md")))
+	})
+
+	t.Run("IncludeIgnored", func(t *testing.T) {
+		dir := prepare(t)
+		result := clean(t, dir, types.Configuration{"includeIgnored": true, "paths": "*.log"})
+		assert.Equal(t, []string{"build.log"}, result.Files)
+		assert.False(t, exists(filepath.Join(dir, "build.log")))
+		assert.True(t, exists(filepath.Join(dir, "src", "tmp.txt")))
+
+		result = clean(t, dir, types.Configuration{"paths": "dist/"})
+		assert.Equal(t, []string{"dist/js/app.js"}, result.Files)
+		assert.True(t, exists(filepath.Join(dir, "src", "tmp.txt")))
+	})
+
+	t.Run("NestedRepository", func(t *testing.T) {
+		dir := prepare(t)
+		// 嵌套的仓库
+		nested := initTestRepo(t, filepath.Join(dir, "vendor", "lib"))
+		head, _ := nested.Head()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "vendor", "lib", "local.txt"), []byte("local"), 0644))
+		// 子模块在索引中只有一个 gitlink 条目，工作目录中的文件不在索引中
+		r, err := git.PlainOpen(dir)
+		assert.Nil(t, err)
+		idx, err := r.Storer.Index()
+		assert.Nil(t, err)
+		idx.Entries = append(idx.Entries, &index.Entry{Name: "sub", Mode: filemode.Submodule, Hash: head.Hash()})
+		assert.Nil(t, r.Storer.SetIndex(idx))
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "sub", "module.go"), []byte("package sub"), 0644))
+
+		result := clean(t, dir, types.Configuration{"includeIgnored": true})
+		assert.Equal(t, []string{"build.log", "dist/js/app.js", "src/tmp.txt"}, result.Files)
+		assert.True(t, exists(filepath.Join(dir, "vendor", "lib", ".git")))
+		assert.True(t, exists(filepath.Join(dir, "vendor", "lib", "README.md")))
+		assert.True(t, exists(filepath.Join(dir, "vendor", "lib", "local.txt")))
+		assert.True(t, exists(filepath.Join(dir, "sub", "module.go")))
+	})
+
+	t.Run("InMemory", func(t *testing.T) {
+		Registry.Add(&GitCloneNode{})
+		remoteDir := prepare(t)
+		cloneNode, err := test.CreateAndInitNode("ci/gitClone", types.Configuration{
+			"repository": remoteDir,
+			"authType":   "",
+			"inMemory":   true,
+		}, Registry)
+		assert.Nil(t, err)
+		msg, relationType, err := onMsgSync(cloneNode, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		// 内存仓库不能按本地文件系统清理，使用 dryRun 避免误删
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      "/x",
+			"appendRepoName": false,
+			"dryRun":         true,
+			"paths":          "etc/*",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err = onMsgSync(node, msg)
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, "clean is not supported for in-memory repository", err.Error())
+	})
+
+	t.Run("SymlinkEscape", func(t *testing.T) {
+		dir := prepare(t)
+		outside := t.TempDir()
+		secret := filepath.Join(outside, "secret.txt")
+		assert.Nil(t, os.WriteFile(secret, []byte("secret"), 0644))
+		if err := os.Symlink(outside, filepath.Join(dir, "outside")); err != nil {
+			t.Skip("symlink not supported:", err)
+		}
+		assert.Nil(t, os.Symlink(secret, filepath.Join(dir, "secret.txt")))
+
+		result := clean(t, dir, types.Configuration{"includeIgnored": true})
+		assert.Equal(t, []string{"build.log", "dist/js/app.js", "outside", "secret.txt", "src/tmp.txt"}, result.Files)
+		assert.False(t, exists(filepath.Join(dir, "outside")))
+		assert.False(t, exists(filepath.Join(dir, "secret.txt")))
+		assert.True(t, exists(outside))
+		assert.True(t, exists(secret))
+	})
+}
@@ -1,30 +1,32 @@
 package action
 
 import (
-	"crypto/tls"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/client"
-	httptransport "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/rulego/rulego"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
-	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
-	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 func init() {
 	_ = rulego.Registry.Register(&GitCloneNode{})
-
-	//不验证https
-	var c = httptransport.NewClient(&http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	})
-	client.InstallProtocol("https", c)
 }
 
 // KeyWorkDir 工作目录
@@ -39,28 +41,115 @@ const KeyGitSshUrl = "gitSshUrl"
 // KeyGitHttpUrl 仓库Http地址
 const KeyGitHttpUrl = "gitHttpUrl"
 
+// KeyCleaned 克隆前是否清空了工作目录
+const KeyCleaned = "cleaned"
+
+// KeyCommitHash 当前HEAD的提交hash
+const KeyCommitHash = "commitHash"
+
+// KeyShortHash 当前HEAD的提交短hash
+const KeyShortHash = "shortHash"
+
+// KeyBranch 当前检出的分支，分离HEAD时为空
+const KeyBranch = "branch"
+
+// KeyCommitMessage 当前HEAD的提交信息
+const KeyCommitMessage = "commitMessage"
+
+// KeyUpToDate 拉取后是否已经是最新，没有任何变化
+const KeyUpToDate = "upToDate"
+
+// KeyPullStrategy 使用的拉取策略
+const KeyPullStrategy = "pullStrategy"
+
+const (
+	// PullStrategyMerge 拉取并合并远程分支
+	PullStrategyMerge = "merge"
+	// PullStrategyRebase 拉取后把本地提交变基到远程分支之上
+	PullStrategyRebase = "rebase"
+	// PullStrategyReset 拉取后强制重置到远程分支
+	PullStrategyReset = "reset"
+)
+
 // GitCloneNodeConfiguration 节点配置
 type GitCloneNodeConfiguration struct {
 	// Git 仓库 URL
 	Repository string
 	// 克隆到的本地目录
 	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
 	// 分支或标签的完整引用名
 	Reference string
-	// 认证类型，可以是 "ssh", "password", 或 "token"
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
 	AuthType string
-	// 用户名
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
 	AuthUser string
-	// 密码或 token
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
 	AuthPassword string
-	// SSH 秘钥文件路径
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
 	AuthPemFile string
-	// 代理地址
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
 	ProxyUrl string
 	// 代理用户名
 	ProxyUsername string
 	// 代理密码
 	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+	// 是否克隆到内存中，适用于只读取仓库内容的临时操作，不会写入磁盘
+	// 仓库ID写入元数据 repoId，同一规则链中的下游git节点通过该ID操作该仓库
+	InMemory bool
+	// 工作目录已存在时的拉取策略，可以是：
+	//  - merge: 默认，拉取并合并远程分支
+	//  - rebase: 拉取后把本地提交变基到远程分支之上，发生冲突时放弃变基。工作区有未提交的修改时失败
+	//    不是真正的三方合并变基，而是按文件重新应用每个本地提交：本地提交修改的文件在远程分支上有不同的修改时认为冲突
+	//  - reset: 拉取后强制重置到远程分支，丢弃本地的提交和修改
+	PullStrategy string
+	// 克隆前是否删除已存在的工作目录，重新克隆
+	// 工作目录为空、"/"、"." 或者不包含 .git 目录时，拒绝删除
+	CleanBeforeClone bool
+	// msg.Data 为仓库列表时并行克隆的仓库数量，小于等于1表示逐个克隆
+	Parallelism int
+	// msg.Data 为仓库列表时，任意仓库失败是否立即停止并发送到Failure链
+	// false 则继续处理剩余仓库，每个仓库的错误写入对应结果的error字段
+	FailFast bool
+}
+
+// CloneItem 批量克隆时 msg.Data 中的仓库，例如：[{"repository":"https://github.com/rulego/rulego.git","reference":"refs/heads/main","directory":"/data/ci"}]
+// reference 为空则使用节点配置的引用，directory 为空则使用节点配置的本地目录
+type CloneItem struct {
+	Repository string `json:"repository"`
+	Reference  string `json:"reference"`
+	Directory  string `json:"directory"`
+}
+
+// CloneResult 批量克隆时每个仓库的结果
+type CloneResult struct {
+	Repository string `json:"repository"`
+	WorkDir    string `json:"workDir"`
+	CommitHash string `json:"commitHash"`
+	Error      string `json:"error,omitempty"`
 }
 
 // GitCloneNode 实现 Git 仓库克隆
@@ -69,6 +158,8 @@ type GitCloneNode struct {
 	// 节点配置
 	Config GitCloneNodeConfiguration
 	hasVar bool
+	// 节点克隆到内存中的仓库ID，节点销毁时释放
+	memoryRepos *repositoryKeys
 }
 
 // Type 组件类型
@@ -77,22 +168,37 @@ func (x *GitCloneNode) Type() string {
 }
 
 func (x *GitCloneNode) New() types.Node {
-	return &GitCloneNode{Config: GitCloneNodeConfiguration{
-		Repository:   "",
-		Directory:    "",
-		AuthType:     "token",
-		AuthPassword: "${vars.token}",
-		Reference:    "refs/heads/main",
-	}}
+	return &GitCloneNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCloneNodeConfiguration{
+			Repository:     "",
+			Directory:      "",
+			AuthType:       "token",
+			AuthPassword:   "${vars.token}",
+			Reference:      "refs/heads/main",
+			PullStrategy:   PullStrategyMerge,
+			AppendRepoName: true,
+		},
+	}
 }
 
 // Init 初始化
 func (x *GitCloneNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
-	err := maps.Map2Struct(configuration, &x.Config)
-	err = maps.Map2Struct(configuration, &x.baseGitNode.Config)
-	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Reference) {
+	x.memoryRepos = &repositoryKeys{items: make(map[string]struct{})}
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Reference) || x.authHasVar() {
 		x.hasVar = true
 	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
+	if err == nil {
+		switch x.Config.PullStrategy {
+		case "", PullStrategyMerge, PullStrategyRebase, PullStrategyReset:
+		default:
+			err = errors.New("not pullStrategy=" + x.Config.PullStrategy)
+		}
+	}
 	return err
 }
 
@@ -103,79 +209,497 @@ func (x *GitCloneNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
 	}
 	ref := x.getReferenceName(msg, evn)
-	workDir := x.getWorkDir(msg, evn)
-	msg.Metadata.PutValue(KeyWorkDir, workDir)
 	repository := x.getRepository(msg, evn)
+	if x.Config.InMemory {
+		x.cloneInMemory(ctx, msg, repository, ref, evn)
+		return
+	}
+	// msg.Data 是仓库列表，批量克隆
+	if items, ok := x.getCloneItems(msg); ok {
+		x.cloneMultiple(ctx, msg, items, ref, evn)
+		return
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	if r, oldHash, err := x.cloneOrPull(ctx, msg, repository, ref, workDir, evn); err != nil {
+		x.tellFailure(ctx, msg, err)
+	} else {
+		x.tellSuccess(ctx, msg, r, oldHash)
+	}
+}
+
+// cloneOrPull 目录不存在则克隆仓库，否则拉取更新，返回仓库以及拉取前的HEAD
+func (x *GitCloneNode) cloneOrPull(ctx types.RuleContext, msg types.RuleMsg, repository, ref, workDir string, evn map[string]interface{}) (*git.Repository, plumbing.Hash, error) {
+	unlock, err := x.lockWorkDir(ctx, "", workDir)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	defer unlock()
+	if x.Config.CleanBeforeClone {
+		x.invalidateRepository(workDir)
+		cleaned, err := x.cleanWorkDir(workDir)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyCleaned), strconv.FormatBool(cleaned))
+	}
 	// 检查目录是否存在
 	if _, err := os.Stat(workDir); os.IsNotExist(err) {
-		// 设置克隆选项
-		cloneOptions := &git.CloneOptions{
-			URL:      repository,
-			Progress: os.Stdout,
+		cloneOptions, err := x.getCloneOptions(repository, ref, evn)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+		// 执行克隆操作
+		var r *git.Repository
+		if err := x.execute(ctx, msg, "clone", repository, func(opCtx context.Context) error {
+			var err error
+			r, err = git.PlainCloneContext(opCtx, workDir, false, cloneOptions)
+			return err
+		}); err != nil {
+			// 删除克隆失败残留的目录，避免下次执行时进入拉取流程
+			_ = os.RemoveAll(workDir)
+			return nil, plumbing.ZeroHash, err
 		}
-		if proxy := x.getProxy(); proxy.URL != "" {
-			cloneOptions.ProxyOptions = proxy
+		x.cacheRepository(workDir, r)
+		return r, plumbing.ZeroHash, nil
+	}
+	// 目录存在，执行拉取操作
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	// 拉取的对象写入对象库，后续节点重新打开仓库
+	defer x.invalidateRepository(workDir)
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	// 记录拉取前的HEAD，用于判断是否有更新
+	var oldHash plumbing.Hash
+	if head, err := r.Head(); err == nil {
+		oldHash = head.Hash()
+	}
+	// 根据 AuthType 字段的值选择认证方式
+	auth, err := x.getAuthMethod(evn)
+	if err != nil {
+		return nil, oldHash, err
+	}
+	if ref != "" {
+		// 当前检出的引用与请求的引用不一致，先切换到请求的引用
+		if needPull, err := x.checkoutReference(ctx, msg, r, w, plumbing.ReferenceName(ref), repository, auth); err != nil {
+			return nil, oldHash, err
+		} else if !needPull {
+			return r, oldHash, nil
 		}
-		// 如果指定了分支或标签，则设置为克隆特定的引用
-		if ref != "" {
-			cloneOptions.ReferenceName = plumbing.ReferenceName(ref)
+	}
+	if x.Config.PullStrategy == PullStrategyRebase || x.Config.PullStrategy == PullStrategyReset {
+		msg.Metadata.PutValue(x.metaKey(KeyPullStrategy), x.Config.PullStrategy)
+		if err = x.updateByStrategy(ctx, msg, r, w, plumbing.ReferenceName(ref), repository, auth); err != nil {
+			return nil, oldHash, err
 		}
+		return r, oldHash, nil
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyPullStrategy), PullStrategyMerge)
+	pullOptions := &git.PullOptions{
+		//RemoteName: "origin",
+		RemoteURL:       repository,
+		Force:           true,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if auth != nil {
+		pullOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		pullOptions.ProxyOptions = proxy
+	}
+	if ref != "" {
+		pullOptions.ReferenceName = plumbing.ReferenceName(ref)
+	}
+	if err = x.execute(ctx, msg, "pull", repository, func(opCtx context.Context) error {
+		return w.PullContext(opCtx, pullOptions)
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, oldHash, err
+	}
+	return r, oldHash, nil
+}
+
+// getCloneItems 解析 msg.Data 中的仓库列表，不是JSON数组则返回false，使用单仓库模式
+func (x *GitCloneNode) getCloneItems(msg types.RuleMsg) ([]CloneItem, bool) {
+	data := strings.TrimSpace(msg.Data)
+	if !strings.HasPrefix(data, "[") {
+		return nil, false
+	}
+	var items []CloneItem
+	if err := json.Unmarshal([]byte(data), &items); err != nil || len(items) == 0 {
+		return nil, false
+	}
+	return items, true
+}
 
-		// 根据 AuthType 字段的值选择认证方式
-		if auth, err := x.getAuthMethod(); err != nil {
-			ctx.TellFailure(msg, err)
-			return
-		} else {
-			cloneOptions.Auth = auth
+// cloneMultiple 批量克隆或者拉取仓库，结果以数组的形式写入 msg.Data
+// FailFast=true 时任意仓库失败则不再处理剩余仓库并发送到Failure链，否则每个仓库的错误写入对应的结果
+func (x *GitCloneNode) cloneMultiple(ctx types.RuleContext, msg types.RuleMsg, items []CloneItem, ref string, evn map[string]interface{}) {
+	results := make([]CloneResult, len(items))
+	parallelism := x.Config.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	var failed atomic.Bool
+	var firstErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i, item := range items {
+		results[i].Repository = item.Repository
+		if x.Config.FailFast && failed.Load() {
+			results[i].Error = "skipped due to previous failure"
+			continue
 		}
-		// 执行克隆操作
-		if _, err := git.PlainClone(workDir, false, cloneOptions); err != nil {
-			ctx.TellFailure(msg, err)
-		} else {
-			ctx.TellSuccess(msg)
+		sem <- struct{}{}
+		// 可能在等待期间失败
+		if x.Config.FailFast && failed.Load() {
+			<-sem
+			results[i].Error = "skipped due to previous failure"
+			continue
 		}
+		wg.Add(1)
+		go func(i int, item CloneItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result := &results[i]
+			// 每个仓库使用独立的消息副本，避免并发写元数据
+			itemMsg := msg.Copy()
+			itemRef := item.Reference
+			if itemRef == "" {
+				itemRef = ref
+			}
+			baseWorkDir := x.getBaseWorkDir(itemMsg, evn)
+			result.WorkDir = item.Directory
+			if result.WorkDir == "" {
+				result.WorkDir = baseWorkDir
+			}
+			result.WorkDir = x.composeWorkDir(result.WorkDir, item.Repository)
+			var err error
+			if item.Repository == "" {
+				err = errors.New("repository can not be empty")
+			} else {
+				err = x.checkItemDirectory(item.Directory, baseWorkDir)
+			}
+			if err == nil {
+				if r, _, cloneErr := x.cloneOrPull(ctx, itemMsg, item.Repository, itemRef, result.WorkDir, evn); cloneErr != nil {
+					err = cloneErr
+				} else if head, headErr := r.Head(); headErr != nil {
+					err = headErr
+				} else {
+					result.CommitHash = head.Hash().String()
+				}
+			}
+			if err != nil {
+				err = x.redact(ctx, itemMsg, err)
+				result.Error = err.Error()
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("clone %s error: %w", redactURL(item.Repository), err)
+				}
+				mu.Unlock()
+				failed.Store(true)
+			}
+		}(i, item)
+	}
+	wg.Wait()
+	data, err := json.Marshal(results)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	if x.Config.FailFast && firstErr != nil {
+		x.tellFailure(ctx, msg, firstErr)
 	} else {
-		// 目录存在，执行拉取操作
-		r, err := git.PlainOpen(workDir)
+		ctx.TellSuccess(msg)
+	}
+}
+
+// checkItemDirectory 检查 msg.Data 中指定的目录，与配置的目录一样不能包含 ".."
+// CleanBeforeClone 时会删除该目录，目录必须在基础工作目录内
+func (x *GitCloneNode) checkItemDirectory(directory, baseWorkDir string) error {
+	if directory == "" {
+		return nil
+	}
+	if err := checkDirectory(directory); err != nil {
+		return err
+	}
+	if x.Config.CleanBeforeClone {
+		base, err := filepath.Abs(baseWorkDir)
 		if err != nil {
-			ctx.TellFailure(msg, err)
-			return
+			return err
 		}
-		w, err := r.Worktree()
+		dir, err := filepath.Abs(directory)
 		if err != nil {
-			ctx.TellFailure(msg, err)
-			return
-		}
-		pullOptions := &git.PullOptions{
-			//RemoteName: "origin",
-			RemoteURL: repository,
-			Force:     true,
-		}
-		if proxy := x.getProxy(); proxy.URL != "" {
-			pullOptions.ProxyOptions = proxy
-		}
-		if ref != "" {
-			pullOptions.ReferenceName = plumbing.ReferenceName(ref)
-		}
-		// 根据 AuthType 字段的值选择认证方式
-		if auth, err := x.getAuthMethod(); err != nil {
-			ctx.TellFailure(msg, err)
-			return
-		} else {
-			pullOptions.Auth = auth
-		}
-		if err = w.Pull(pullOptions); err != nil {
-			if err == git.NoErrAlreadyUpToDate {
-				ctx.TellSuccess(msg)
-			} else {
-				ctx.TellFailure(msg, err)
-			}
-		} else {
-			ctx.TellSuccess(msg)
+			return err
+		}
+		if !isWithinDir(base, dir) {
+			return fmt.Errorf("directory %s must be within %s when cleanBeforeClone is true", directory, baseWorkDir)
 		}
 	}
+	return nil
 }
 
 // Destroy 销毁
 func (x *GitCloneNode) Destroy() {
+	x.destroyBase()
+	if x.memoryRepos != nil {
+		x.memoryRepos.Lock()
+		defer x.memoryRepos.Unlock()
+		for id := range x.memoryRepos.items {
+			removeMemoryRepository(id)
+		}
+		x.memoryRepos.items = make(map[string]struct{})
+	}
+}
+
+// getCloneOptions 获取克隆选项
+func (x *GitCloneNode) getCloneOptions(repository, ref string, evn map[string]interface{}) (*git.CloneOptions, error) {
+	cloneOptions := &git.CloneOptions{
+		URL:             repository,
+		Progress:        os.Stdout,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		cloneOptions.ProxyOptions = proxy
+	}
+	// 如果指定了分支或标签，则设置为克隆特定的引用
+	if ref != "" {
+		cloneOptions.ReferenceName = plumbing.ReferenceName(ref)
+	}
+	// 根据 AuthType 字段的值选择认证方式
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		return nil, err
+	} else if auth != nil {
+		cloneOptions.Auth = auth
+	}
+	return cloneOptions, nil
+}
+
+// cloneInMemory 克隆到内存中，并把仓库ID写入元数据，下游节点通过该ID操作该仓库
+func (x *GitCloneNode) cloneInMemory(ctx types.RuleContext, msg types.RuleMsg, repository, ref string, evn map[string]interface{}) {
+	cloneOptions, err := x.getCloneOptions(repository, ref, evn)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	var r *git.Repository
+	if err := x.execute(ctx, msg, "clone", repository, func(opCtx context.Context) error {
+		var err error
+		r, err = git.CloneContext(opCtx, memory.NewStorage(), memfs.New(), cloneOptions)
+		return err
+	}); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	id := putMemoryRepository(r)
+	x.memoryRepos.Lock()
+	x.memoryRepos.items[id] = struct{}{}
+	x.memoryRepos.Unlock()
+	msg.Metadata.PutValue(x.metaKey(KeyRepoId), id)
+	x.tellSuccess(ctx, msg, r, plumbing.ZeroHash)
+}
+
+// tellSuccess 把HEAD的提交信息以及是否有更新写入元数据，然后发送到下一个节点
+func (x *GitCloneNode) tellSuccess(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, oldHash plumbing.Hash) {
+	hash, err := x.putHeadMetadata(r, msg)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyUpToDate), strconv.FormatBool(hash == oldHash))
+	ctx.TellSuccess(msg)
+}
+
+// checkoutReference 如果当前HEAD不是请求的引用，则拉取该引用并检出
+// 分支检出后需要继续执行拉取操作，标签以分离HEAD的方式检出，不需要再拉取
+func (x *GitCloneNode) checkoutReference(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, w *git.Worktree, ref plumbing.ReferenceName, repository string, auth transport.AuthMethod) (bool, error) {
+	head, err := r.Head()
+	if err == nil && head.Name() == ref {
+		return true, nil
+	}
+	localRef, err := x.fetchReference(ctx, msg, r, ref, repository, auth)
+	if err != nil {
+		return false, err
+	}
+	if ref.IsBranch() {
+		checkoutOptions := &git.CheckoutOptions{Branch: ref}
+		// 本地分支不存在，基于远程分支创建
+		if _, err = r.Reference(ref, false); err != nil {
+			remoteRef, err := r.Reference(localRef, true)
+			if err != nil {
+				return false, fmt.Errorf("reference %s not found: %w", ref, err)
+			}
+			checkoutOptions.Hash = remoteRef.Hash()
+			checkoutOptions.Create = true
+		}
+		return true, w.Checkout(checkoutOptions)
+	}
+	// 标签或者其他引用，检出对应的提交
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return false, fmt.Errorf("reference %s not found: %w", ref, err)
+	}
+	return false, w.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
+
+// fetchReference 拉取远程引用，分支拉取到远程跟踪分支，其他引用拉取到同名引用，返回本地引用名称
+func (x *GitCloneNode) fetchReference(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, ref plumbing.ReferenceName, repository string, auth transport.AuthMethod) (plumbing.ReferenceName, error) {
+	localRef := ref
+	if ref.IsBranch() {
+		localRef = plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref.Short())
+	}
+	fetchOptions := &git.FetchOptions{
+		RemoteURL:       repository,
+		RefSpecs:        []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", ref, localRef))},
+		Force:           true,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if auth != nil {
+		fetchOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		fetchOptions.ProxyOptions = proxy
+	}
+	if err := x.execute(ctx, msg, "fetch", repository, func(opCtx context.Context) error {
+		return r.FetchContext(opCtx, fetchOptions)
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return localRef, err
+	}
+	return localRef, nil
+}
+
+// updateByStrategy 拉取远程分支，然后按 rebase 或者 reset 策略更新当前分支
+func (x *GitCloneNode) updateByStrategy(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, w *git.Worktree, ref plumbing.ReferenceName, repository string, auth transport.AuthMethod) error {
+	if ref == "" {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+		ref = head.Name()
+	}
+	if !ref.IsBranch() {
+		return fmt.Errorf("pullStrategy=%s requires a branch, but got %s", x.Config.PullStrategy, ref)
+	}
+	localRef, err := x.fetchReference(ctx, msg, r, ref, repository, auth)
+	if err != nil {
+		return err
+	}
+	remoteRef, err := r.Reference(localRef, true)
+	if err != nil {
+		return fmt.Errorf("reference %s not found: %w", ref, err)
+	}
+	if x.Config.PullStrategy == PullStrategyReset {
+		// 丢弃本地的提交和修改
+		return w.Reset(&git.ResetOptions{Commit: remoteRef.Hash(), Mode: git.HardReset})
+	}
+	return x.rebase(r, w, remoteRef.Hash())
+}
+
+// rebase 把本地提交变基到远程分支之上，发生冲突时恢复到变基前的状态并返回冲突的文件列表
+// 每个本地提交按文件级别 cherry-pick，不做文件内容的三方合并
+func (x *GitCloneNode) rebase(r *git.Repository, w *git.Worktree, upstreamHash plumbing.Hash) error {
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	localCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	upstream, err := r.CommitObject(upstreamHash)
+	if err != nil {
+		return err
+	}
+	bases, err := localCommit.MergeBase(upstream)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("refusing to rebase %s onto unrelated history %s", localCommit.Hash, upstream.Hash)
+	}
+	// 本地已经包含远程的所有提交
+	if bases[0].Hash == upstream.Hash {
+		return nil
+	}
+	// 需要重新应用的本地提交
+	var commits []*object.Commit
+	for c := localCommit; c.Hash != bases[0].Hash && c.NumParents() > 0; {
+		commits = append(commits, c)
+		if c, err = c.Parent(0); err != nil {
+			return err
+		}
+	}
+	// 变基会强制重置工作区，有未提交的修改时拒绝变基
+	if dirty, err := isDirtyWorktree(w); err != nil {
+		return err
+	} else if dirty {
+		return ErrDirtyWorktree
+	}
+	if err = w.Reset(&git.ResetOptions{Commit: upstream.Hash, Mode: git.HardReset}); err != nil {
+		return err
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		if err = x.cherryPick(w, commits[i]); err != nil {
+			// 放弃变基，恢复到变基前的状态
+			_ = w.Reset(&git.ResetOptions{Commit: localCommit.Hash, Mode: git.HardReset})
+			return fmt.Errorf("rebase commit %s error: %w", commits[i].Hash, err)
+		}
+	}
+	return nil
+}
+
+// cherryPick 把提交的变更应用到工作区，并使用原提交信息创建新的提交
+func (x *GitCloneNode) cherryPick(w *git.Worktree, commit *object.Commit) error {
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	if err = applyTreeChanges(w, parentTree, tree); err != nil {
+		return err
+	}
+	committer := commit.Committer
+	committer.When = time.Now()
+	if _, err = w.Commit(commit.Message, &git.CommitOptions{Author: &commit.Author, Committer: &committer}); err != nil && !errors.Is(err, git.ErrEmptyCommit) {
+		return err
+	}
+	return nil
+}
+
+// cleanWorkDir 删除已存在的工作目录，返回是否执行了删除
+func (x *GitCloneNode) cleanWorkDir(workDir string) (bool, error) {
+	dir := filepath.Clean(workDir)
+	if workDir == "" || dir == "." || dir == string(filepath.Separator) || dir == filepath.VolumeName(dir)+string(filepath.Separator) {
+		return false, fmt.Errorf("refuse to clean workDir=%s", workDir)
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return false, nil
+	}
+	// 只删除git仓库目录，防止模板配置错误删除其他文件
+	if info, err := os.Stat(filepath.Join(dir, ".git")); err != nil || !info.IsDir() {
+		return false, fmt.Errorf("refuse to clean workDir=%s, it is not a git repository", workDir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return false, err
+	}
+	return true, nil
 }
@@ -17,11 +17,22 @@
 package action
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
 	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGitCloneNode(t *testing.T) {
@@ -34,6 +45,10 @@ func TestGitCloneNode(t *testing.T) {
 	})
 
 	t.Run("InitNode", func(t *testing.T) {
+		expectedWorkDir := "d:/rulego-components-ci"
+		if runtime.GOOS == "windows" {
+			expectedWorkDir = `d:\rulego-components-ci`
+		}
 		node, _ := test.CreateAndInitNode(targetNodeType, types.Configuration{
 			"repository": "",
 			"directory":  "",
@@ -50,7 +65,7 @@ func TestGitCloneNode(t *testing.T) {
 		workDir := (node.(*GitCloneNode)).getWorkDir(msg, evn)
 		repository := (node.(*GitCloneNode)).getRepository(msg, evn)
 		reference := (node.(*GitCloneNode)).getReferenceName(msg, evn)
-		assert.Equal(t, "d:/rulego-components-ci", workDir)
+		assert.Equal(t, expectedWorkDir, workDir)
 		assert.Equal(t, "git@github.com:rulego/rulego-components-ci.git", repository)
 		assert.Equal(t, "main", reference)
 
@@ -69,10 +84,477 @@ func TestGitCloneNode(t *testing.T) {
 			"reference":  "${metadata.ref}",
 			"authType":   "token",
 		}, Registry)
+		workDir = (node.(*GitCloneNode)).getWorkDir(msg, evn)
 		repository = (node.(*GitCloneNode)).getRepository(msg, evn)
-		assert.Equal(t, "d:/rulego-components-ci", workDir)
+		assert.Equal(t, expectedWorkDir, workDir)
 		assert.Equal(t, "https://github.com/rulego/rulego-components-ci", repository)
 		assert.Equal(t, "main", reference)
+
+		metaData.PutValue(KeyWorkDir, "/data/ci")
+		node, _ = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"authType":       "token",
+			"appendRepoPath": true,
+		}, Registry)
+		workDir = (node.(*GitCloneNode)).getWorkDir(msg, evn)
+		assert.Equal(t, filepath.FromSlash("/data/ci/rulego/rulego-components-ci"), workDir)
+
+		node, _ = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"authType":       "token",
+			"appendRepoName": false,
+		}, Registry)
+		workDir = (node.(*GitCloneNode)).getWorkDir(msg, evn)
+		assert.Equal(t, filepath.FromSlash("/data/ci"), workDir)
 	})
 
 }
+
+func TestGitCloneNodeCleanWorkDir(t *testing.T) {
+	node := &GitCloneNode{}
+	for _, dir := range []string{"", ".", "/"} {
+		cleaned, err := node.cleanWorkDir(dir)
+		assert.NotNil(t, err)
+		assert.False(t, cleaned)
+	}
+
+	tmp := t.TempDir()
+	//不存在的目录不需要删除
+	cleaned, err := node.cleanWorkDir(filepath.Join(tmp, "notExist"))
+	assert.Nil(t, err)
+	assert.False(t, cleaned)
+
+	//不是git仓库，拒绝删除
+	plainDir := filepath.Join(tmp, "plain")
+	_ = os.MkdirAll(plainDir, os.ModePerm)
+	cleaned, err = node.cleanWorkDir(plainDir)
+	assert.NotNil(t, err)
+	assert.False(t, cleaned)
+	_, err = os.Stat(plainDir)
+	assert.Nil(t, err)
+
+	repoDir := filepath.Join(tmp, "repo")
+	_, err = git.PlainInit(repoDir, false)
+	assert.Nil(t, err)
+	cleaned, err = node.cleanWorkDir(repoDir)
+	assert.Nil(t, err)
+	assert.True(t, cleaned)
+	_, err = os.Stat(repoDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGitCloneNodeCheckoutReference(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	w, _ := remote.Worktree()
+	_ = w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release-1.2"), Create: true})
+	releaseHash := commitTestFile(t, remote, "release.txt", "release", "release")
+	tagRef, err := remote.CreateTag("v1.0.0", releaseHash, &git.CreateTagOptions{Tagger: &testSignature, Message: "v1.0.0"})
+	assert.Nil(t, err)
+	_ = w.Checkout(&git.CheckoutOptions{Branch: plumbing.Main})
+
+	workDir := filepath.Join(tmp, "work")
+	newNode := func(ref string) types.Node {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"repository": remoteDir,
+			"directory":  workDir,
+			"reference":  ref,
+			"authType":   "",
+		}, Registry)
+		assert.Nil(t, err)
+		return node
+	}
+	msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+
+	_, relationType, err := onMsgSync(newNode("refs/heads/main"), msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+
+	//目录已存在，切换到其他分支
+	_, relationType, err = onMsgSync(newNode("refs/heads/release-1.2"), msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	r, _ := git.PlainOpen(filepath.Join(workDir, "remote"))
+	head, _ := r.Head()
+	assert.Equal(t, "refs/heads/release-1.2", head.Name().String())
+	assert.Equal(t, releaseHash, head.Hash())
+
+	//检出标签
+	_, relationType, err = onMsgSync(newNode("refs/tags/v1.0.0"), msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	head, _ = r.Head()
+	assert.Equal(t, plumbing.HEAD, head.Name())
+	assert.Equal(t, releaseHash, head.Hash())
+	assert.NotEqual(t, tagRef.Hash(), head.Hash())
+}
+
+func TestGitCloneNodeCloneFailed(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	workDir := filepath.Join(tmp, "work")
+	node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+		"repository": "${metadata.repository}",
+		"directory":  workDir,
+		"reference":  "refs/heads/main",
+		"authType":   "",
+	}, Registry)
+	assert.Nil(t, err)
+
+	metaData := types.NewMetadata()
+	metaData.PutValue("repository", remoteDir)
+	msg := types.NewMsg(0, "test", types.JSON, metaData, "")
+	//远程仓库不存在，克隆失败
+	_, relationType, err := onMsgSync(node, msg)
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relationType)
+	_, err = os.Stat(filepath.Join(workDir, "remote"))
+	assert.True(t, os.IsNotExist(err))
+
+	//远程仓库可用后，无需人工处理即可重新克隆
+	initTestRepo(t, remoteDir)
+	_, relationType, err = onMsgSync(node, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	_, err = git.PlainOpen(filepath.Join(workDir, "remote"))
+	assert.Nil(t, err)
+}
+
+func TestGitCloneNodeHeadMetadata(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	head, _ := remote.Head()
+	node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+		"repository": remoteDir,
+		"directory":  filepath.Join(tmp, "work"),
+		"reference":  "refs/heads/main",
+		"authType":   "",
+	}, Registry)
+	assert.Nil(t, err)
+
+	msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+	outMsg, relationType, err := onMsgSync(node, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+	assert.Equal(t, head.Hash().String()[:7], outMsg.Metadata.GetValue(KeyShortHash))
+	assert.Equal(t, "main", outMsg.Metadata.GetValue(KeyBranch))
+	assert.Equal(t, "init", outMsg.Metadata.GetValue(KeyCommitMessage))
+	assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+
+	//没有变化
+	msg = types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+	outMsg, relationType, err = onMsgSync(node, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyUpToDate))
+
+	//远程仓库有新的提交
+	hash := commitTestFile(t, remote, "a.txt", "a", "add a")
+	msg = types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+	outMsg, relationType, err = onMsgSync(node, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+	assert.Equal(t, hash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+	assert.Equal(t, "add a", outMsg.Metadata.GetValue(KeyCommitMessage))
+}
+
+func TestGitCloneNodeInMemory(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	head, _ := remote.Head()
+	workDir := filepath.Join(tmp, "work")
+	node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+		"repository": remoteDir,
+		"directory":  workDir,
+		"reference":  "refs/heads/main",
+		"authType":   "",
+		"inMemory":   true,
+	}, Registry)
+	assert.Nil(t, err)
+
+	msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+	outMsg, relationType, err := onMsgSync(node, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+	//不会写入磁盘
+	_, err = os.Stat(workDir)
+	assert.True(t, os.IsNotExist(err))
+
+	r, err := (&baseGitNode{}).openRepository(outMsg, "")
+	assert.Nil(t, err)
+	memoryHead, _ := r.Head()
+	assert.Equal(t, head.Hash(), memoryHead.Hash())
+
+	// 节点销毁时释放克隆到内存中的仓库
+	id := outMsg.Metadata.GetValue(KeyRepoId)
+	node.Destroy()
+	_, ok := getMemoryRepository(id)
+	assert.False(t, ok)
+}
+
+func TestMemoryRepositoryExpired(t *testing.T) {
+	ttl := MemoryRepositoryTTL
+	defer func() {
+		MemoryRepositoryTTL = ttl
+	}()
+	id := putMemoryRepository(&git.Repository{})
+	_, ok := getMemoryRepository(id)
+	assert.True(t, ok)
+	// 获取时清理过期的仓库
+	MemoryRepositoryTTL = 0
+	_, ok = getMemoryRepository("")
+	assert.False(t, ok)
+	memoryRepositories.Lock()
+	_, ok = memoryRepositories.items[id]
+	memoryRepositories.Unlock()
+	assert.False(t, ok)
+}
+
+func TestMemoryRepositoryAccessed(t *testing.T) {
+	id := putMemoryRepository(&git.Repository{})
+	defer removeMemoryRepository(id)
+	setAccessTime := func(accessTime time.Time) {
+		memoryRepositories.Lock()
+		memoryRepositories.items[id].accessTime = accessTime
+		memoryRepositories.Unlock()
+	}
+	// 快到保留时间时访问，重新计算保留时间
+	setAccessTime(time.Now().Add(-MemoryRepositoryTTL + time.Second))
+	_, ok := getMemoryRepository(id)
+	assert.True(t, ok)
+	memoryRepositories.Lock()
+	accessTime := memoryRepositories.items[id].accessTime
+	memoryRepositories.Unlock()
+	assert.True(t, time.Since(accessTime) < MemoryRepositoryTTL/2)
+	// 超过保留时间没有访问被清理
+	setAccessTime(time.Now().Add(-MemoryRepositoryTTL - time.Second))
+	_, ok = getMemoryRepository(id)
+	assert.False(t, ok)
+}
+
+func TestGitCloneNodePullStrategy(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+		"pullStrategy": "unknown",
+	}, Registry)
+	assert.NotNil(t, err)
+
+	prepare := func(t *testing.T, strategy string) (*git.Repository, *git.Repository, types.Node) {
+		tmp := t.TempDir()
+		remoteDir := filepath.Join(tmp, "remote")
+		remote := initTestRepo(t, remoteDir)
+		workDir := filepath.Join(tmp, "work")
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"repository":   remoteDir,
+			"directory":    workDir,
+			"reference":    "refs/heads/main",
+			"authType":     "",
+			"pullStrategy": strategy,
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Equal(t, types.Success, relationType)
+		local, _ := git.PlainOpen(filepath.Join(workDir, "remote"))
+		return remote, local, node
+	}
+
+	t.Run("rebase", func(t *testing.T) {
+		remote, local, node := prepare(t, PullStrategyRebase)
+		commitTestFile(t, local, "VERSION", "1.0.1", "bump version")
+		remoteHash := commitTestFile(t, remote, "a.txt", "a", "add a")
+
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, PullStrategyRebase, outMsg.Metadata.GetValue(KeyPullStrategy))
+		head, _ := local.Head()
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		commit, _ := local.CommitObject(head.Hash())
+		assert.Equal(t, "bump version", commit.Message)
+		assert.Equal(t, remoteHash, commit.ParentHashes[0])
+		tree, _ := commit.Tree()
+		_, err = tree.FindEntry("a.txt")
+		assert.Nil(t, err)
+	})
+
+	t.Run("rebaseConflict", func(t *testing.T) {
+		remote, local, node := prepare(t, PullStrategyRebase)
+		localHash := commitTestFile(t, local, "README.md", "local", "local change")
+		commitTestFile(t, remote, "README.md", "remote", "remote change")
+
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, strings.Contains(err.Error(), "README.md"))
+		//恢复到变基前的状态
+		head, _ := local.Head()
+		assert.Equal(t, localHash, head.Hash())
+	})
+
+	t.Run("rebaseDirty", func(t *testing.T) {
+		remote, local, node := prepare(t, PullStrategyRebase)
+		localHash := commitTestFile(t, local, "VERSION", "1.0.1", "bump version")
+		commitTestFile(t, remote, "a.txt", "a", "add a")
+		w, _ := local.Worktree()
+		readme := filepath.Join(w.Filesystem.Root(), "README.md")
+		assert.Nil(t, os.WriteFile(readme, []byte("uncommitted"), 0644))
+
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, errors.Is(err, ErrDirtyWorktree))
+		//未提交的修改没有被丢弃
+		head, _ := local.Head()
+		assert.Equal(t, localHash, head.Hash())
+		content, _ := os.ReadFile(readme)
+		assert.Equal(t, "uncommitted", string(content))
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		remote, local, node := prepare(t, PullStrategyReset)
+		commitTestFile(t, local, "README.md", "local", "local change")
+		remoteHash := commitTestFile(t, remote, "README.md", "remote", "remote change")
+
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, remoteHash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		head, _ := local.Head()
+		assert.Equal(t, remoteHash, head.Hash())
+	})
+}
+
+func TestGitCloneNodeMultiple(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	var targetNodeType = "ci/gitClone"
+
+	tmp := t.TempDir()
+	var remoteHashes = make(map[string]string)
+	for _, name := range []string{"app", "config", "infra"} {
+		remote := initTestRepo(t, filepath.Join(tmp, "remote", name))
+		head, _ := remote.Head()
+		remoteHashes[name] = head.Hash().String()
+	}
+	itemsData := func(names ...string) string {
+		var items []CloneItem
+		for _, name := range names {
+			items = append(items, CloneItem{Repository: filepath.Join(tmp, "remote", name)})
+		}
+		data, _ := json.Marshal(items)
+		return string(data)
+	}
+	newNode := func(t *testing.T, configuration types.Configuration) types.Node {
+		configuration["reference"] = "refs/heads/main"
+		configuration["authType"] = ""
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		return node
+	}
+
+	for _, parallelism := range []int{0, 3} {
+		workDir := filepath.Join(t.TempDir(), "work")
+		node := newNode(t, types.Configuration{"directory": workDir, "parallelism": parallelism})
+		msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), itemsData("app", "config", "infra"))
+		outMsg, relationType, err := onMsgSync(node, msg)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var results []CloneResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &results))
+		assert.Equal(t, 3, len(results))
+		for i, name := range []string{"app", "config", "infra"} {
+			assert.Equal(t, "", results[i].Error)
+			assert.Equal(t, filepath.Join(workDir, name), results[i].WorkDir)
+			assert.Equal(t, remoteHashes[name], results[i].CommitHash)
+			_, err = git.PlainOpen(results[i].WorkDir)
+			assert.Nil(t, err)
+		}
+	}
+
+	t.Run("EntryFailure", func(t *testing.T) {
+		node := newNode(t, types.Configuration{"directory": filepath.Join(t.TempDir(), "work")})
+		msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), itemsData("app", "notExist", "config"))
+		outMsg, relationType, err := onMsgSync(node, msg)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var results []CloneResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &results))
+		assert.Equal(t, "", results[0].Error)
+		assert.NotEqual(t, "", results[1].Error)
+		assert.Equal(t, "", results[2].Error)
+		assert.Equal(t, remoteHashes["config"], results[2].CommitHash)
+	})
+
+	t.Run("RedactError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		node := newNode(t, types.Configuration{"directory": filepath.Join(t.TempDir(), "work")})
+		repository := strings.Replace(server.URL, "http://", "http://tenant:url-secret@", 1) + "/rulego.git"
+		data, _ := json.Marshal([]CloneItem{{Repository: repository}})
+		outMsg, _, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), string(data)))
+		assert.Nil(t, err)
+		var results []CloneResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &results))
+		assert.NotEqual(t, "", results[0].Error)
+		assert.False(t, strings.Contains(results[0].Error, "url-secret"))
+	})
+
+	t.Run("DirectoryEscape", func(t *testing.T) {
+		tmp := t.TempDir()
+		outside := filepath.Join(tmp, "outside")
+		assert.Nil(t, os.MkdirAll(outside, os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(outside, "keep.txt"), []byte("keep"), 0644))
+		node := newNode(t, types.Configuration{
+			"directory":        filepath.Join(tmp, "work"),
+			"appendRepoName":   false,
+			"cleanBeforeClone": true,
+		})
+		data, _ := json.Marshal([]CloneItem{
+			{Repository: filepath.Join(tmp, "remote", "app"), Directory: filepath.Join(tmp, "work") + "/../outside"},
+			{Repository: filepath.Join(tmp, "remote", "app"), Directory: outside},
+		})
+		outMsg, _, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), string(data)))
+		assert.Nil(t, err)
+		var results []CloneResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &results))
+		assert.True(t, strings.Contains(results[0].Error, "can not contain '..'"))
+		assert.True(t, strings.Contains(results[1].Error, "must be within"))
+		_, err = os.Stat(filepath.Join(outside, "keep.txt"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		node := newNode(t, types.Configuration{"directory": filepath.Join(t.TempDir(), "work"), "failFast": true})
+		msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), itemsData("app", "notExist", "config"))
+		outMsg, relationType, err := onMsgSync(node, msg)
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		var results []CloneResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &results))
+		assert.Equal(t, "", results[0].Error)
+		assert.NotEqual(t, "", results[1].Error)
+		assert.Equal(t, "", results[2].CommitHash)
+		assert.NotEqual(t, "", results[2].Error)
+	})
+}
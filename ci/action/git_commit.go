@@ -17,14 +17,25 @@
 package action
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/rulego/rulego"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
-	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,24 +43,71 @@ func init() {
 	_ = rulego.Registry.Register(&GitCommitNode{})
 }
 
+// KeyEmptyCommit 是否是没有文件变更的空提交
+const KeyEmptyCommit = "emptyCommit"
+
+// ErrNoChanges 没有需要提交的变更，可以通过 errors.Is 区分没有变更和其他错误
+var ErrNoChanges = errors.New("no changes to commit")
+
+// CommitFile 提交的文件
+type CommitFile struct {
+	// 文件路径
+	Path string `json:"path"`
+	// 变更类型，可以是 add、delete 或 modify
+	ChangeType string `json:"changeType"`
+}
+
+// CommitResult 提交结果
+type CommitResult struct {
+	CommitInfo
+	// 提交所在的分支，分离HEAD时为空
+	Branch string `json:"branch"`
+	// 是否是没有文件变更的空提交
+	EmptyCommit bool `json:"emptyCommit"`
+	// 提交的文件
+	Files []CommitFile `json:"files"`
+}
+
 // GitCommitNodeConfiguration 节点配置
 type GitCommitNodeConfiguration struct {
 	// 本地目录
 	Directory string
-	// 添加的文件模式匹配
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 添加的文件模式匹配，多个与逗号隔开并按顺序添加，例如：dist/*,VERSION
 	Pattern string
+	// 是否添加所有变更，包括新增、修改和删除的文件，等同于 git add -A，为true时忽略 Pattern
+	AddAll bool
+	// 是否不添加任何文件，只提交索引中已经暂存的变更，为true时忽略 Pattern 和 AddAll
+	SkipAdd bool
+	// 是否不添加被 .gitignore 忽略的未跟踪文件，默认true
+	RespectGitignore bool
+	// 不添加的文件模式，语法与 .gitignore 相同，多个与逗号隔开，例如：*.log,/tmp/
+	ExcludePatterns string
+	// 没有变更时是否创建空提交，例如作为部署标记，false则没有变更时发送到Failure链，错误为 ErrNoChanges
+	AllowEmptyCommit bool
 	// 注释消息
 	Message string
 	//签名
 	Signature Signature
+	// 签名使用的PGP私钥，可以是 ASCII armored 内容或者文件路径，支持 env://变量名 或 file://文件路径 读取
+	SignKeyArmored string
+	// PGP私钥的密码，私钥没有加密则为空，支持 env://变量名 或 file://文件路径 读取
+	SignKeyPassphrase string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
 }
 
-// GitCommitNode 实现 Git 推送
+// GitCommitNode 添加匹配模式的文件并提交，提交hash写入元数据 hash，提交信息和提交的文件列表以JSON的形式写入 msg.Data
 type GitCommitNode struct {
 	baseGitNode
 	// 节点配置
 	Config GitCommitNodeConfiguration
 	hasVar bool
+	// 签名使用的PGP私钥
+	signKey *openpgp.Entity
 }
 
 // Type 组件类型
@@ -58,16 +116,36 @@ func (x *GitCommitNode) Type() string {
 }
 
 func (x *GitCommitNode) New() types.Node {
-	return &GitCommitNode{Config: GitCommitNodeConfiguration{}}
+	return &GitCommitNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCommitNodeConfiguration{
+			RespectGitignore: true,
+			AppendRepoName:   true,
+		},
+	}
 }
 
 // Init 初始化
 func (x *GitCommitNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
-	err := maps.Map2Struct(configuration, &x.Config)
-	err = maps.Map2Struct(configuration, &x.baseGitNode.Config)
-	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Pattern) || str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) {
+	err := x.initConfig(configuration, &x.Config)
+	if err == nil && !x.Config.AddAll && !x.Config.SkipAdd && len(splitPatterns(x.Config.Pattern)) == 0 {
+		err = errors.New("pattern can not be empty when addAll and skipAdd are false")
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Pattern) || str.CheckHasVar(x.Config.ExcludePatterns) || str.CheckHasVar(x.Config.Message) || str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) ||
+		str.CheckHasVar(x.Config.Signature.CommitterName) || str.CheckHasVar(x.Config.Signature.CommitterEmail) {
 		x.hasVar = true
 	}
+	if when := x.Config.Signature.When; str.CheckHasVar(when) {
+		x.hasVar = true
+	} else if _, whenErr := parseSignatureTime(when); err == nil && whenErr != nil {
+		err = whenErr
+	}
+	if err == nil {
+		err = validateSignature(x.Config.Signature)
+	}
+	if err == nil && strings.TrimSpace(x.Config.SignKeyArmored) != "" {
+		x.signKey, err = loadSignKey(x.Config.SignKeyArmored, x.Config.SignKeyPassphrase)
+	}
 	return err
 }
 
@@ -78,9 +156,15 @@ func (x *GitCommitNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
 	}
 	workDir := x.getWorkDir(msg, evn)
-	msg.Metadata.PutValue(KeyWorkDir, workDir)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
 	// 打开仓库
-	r, err := git.PlainOpen(workDir)
+	r, err := x.openLockedRepository(msg, workDir)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return
@@ -97,41 +181,170 @@ func (x *GitCommitNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		ctx.TellFailure(msg, err)
 		return
 	}
-	if status.IsClean() {
-		ctx.TellFailure(msg, errors.New("no changes to commit"))
-	} else {
+	clean := status.IsClean()
+	if x.Config.SkipAdd {
+		// 只提交索引中已经暂存的变更，忽略工作区的修改
+		clean = !hasStagedChanges(status)
+	}
+	if clean && !x.Config.AllowEmptyCommit {
+		ctx.TellFailure(msg, ErrNoChanges)
+		return
+	}
+	if !clean && !x.Config.SkipAdd {
 		//添加文件
-		err = w.AddGlob(x.getPattern(msg, evn))
-		if err != nil {
-			ctx.TellFailure(msg, err)
-			return
-		}
-		commit, err := w.Commit(x.getMessage(msg, evn), &git.CommitOptions{
-			Author: &object.Signature{
-				Name:  x.getSignatureName(msg, evn),
-				Email: x.getSignatureEmail(msg, evn),
-				When:  time.Now(),
-			},
-		})
-		if err != nil {
+		if err = x.stage(r, w, status, msg, evn); err != nil {
 			ctx.TellFailure(msg, err)
 			return
 		}
-		msg.Metadata.PutValue(KeyHash, commit.String())
-		ctx.TellSuccess(msg)
 	}
+	author, committer, err := getSignatures(r, x.Config.Signature, evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	commit, err := w.Commit(x.getMessage(msg, evn), &git.CommitOptions{
+		AllowEmptyCommits: x.Config.AllowEmptyCommit,
+		Author:            author,
+		Committer:         committer,
+		SignKey:           x.signKey,
+	})
+	if errors.Is(err, git.ErrEmptyCommit) {
+		// 工作区有变更但是没有匹配模式的文件需要提交
+		err = ErrNoChanges
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result, err := newCommitResult(r, commit)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), commit.String())
+	msg.Metadata.PutValue(x.metaKey(KeyEmptyCommit), strconv.FormatBool(result.EmptyCommit))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
 }
 
 // Destroy 销毁
 func (x *GitCommitNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// stage 添加文件到索引，AddAll为true时添加所有变更，否则按顺序添加每个模式匹配的文件，模式匹配目录时递归添加目录中的文件
+// 已删除的文件根据状态匹配模式后从索引中删除，匹配 ExcludePatterns 的文件不添加
+// RespectGitignore 为true时不添加被 .gitignore 忽略的未跟踪文件，已跟踪的文件不受影响
+// 单个模式没有匹配的文件时忽略，所有模式都没有匹配的文件时返回 git.ErrGlobNoMatches
+func (x *GitCommitNode) stage(r *git.Repository, w *git.Worktree, status git.Status, msg types.RuleMsg, evn map[string]interface{}) error {
+	var excludes []gitignore.Pattern
+	for _, pattern := range splitPatterns(x.getValue(x.Config.ExcludePatterns, evn)) {
+		excludes = append(excludes, gitignore.ParsePattern(pattern, nil))
+	}
+	if x.Config.AddAll && len(excludes) == 0 {
+		return w.AddWithOptions(&git.AddOptions{All: true})
+	}
+	var files []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = filepath.ToSlash(name)
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	var changed []string
+	for name := range status {
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+	if x.Config.AddAll {
+		// 状态中的未跟踪文件已经排除了被忽略的文件
+		for _, name := range changed {
+			add(name)
+		}
+	} else {
+		matched := false
+		for _, pattern := range x.getPatterns(msg, evn) {
+			matches, err := util.Glob(w.Filesystem, pattern)
+			if err != nil {
+				return err
+			}
+			for _, match := range matches {
+				if isGitDirPath(match) {
+					continue
+				}
+				matched = true
+				if err = walkFiles(w, match, add); err != nil {
+					return err
+				}
+			}
+			for _, name := range changed {
+				if status[name].Worktree == git.Deleted && matchGlobPath(pattern, name) {
+					matched = true
+					add(name)
+				}
+			}
+		}
+		if !matched {
+			return git.ErrGlobNoMatches
+		}
+	}
+	var ignore gitignore.Matcher
+	if x.Config.RespectGitignore {
+		patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return err
+		}
+		ignore = gitignore.NewMatcher(append(patterns, w.Excludes...))
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	tracked := make(map[string]bool, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		tracked[entry.Name] = true
+	}
+	exclude := gitignore.NewMatcher(excludes)
+	for _, name := range files {
+		if exclude.Match(strings.Split(name, "/"), false) {
+			continue
+		}
+		fileStatus, ok := status[name]
+		switch {
+		case tracked[name] && !ok:
+			// 没有修改
+			continue
+		case !tracked[name] && ignore != nil && ignore.Match(strings.Split(name, "/"), false):
+			continue
+		case ok && fileStatus.Worktree == git.Unmodified:
+			// 已经添加到索引
+			continue
+		case ok && fileStatus.Worktree == git.Deleted:
+			_, err = w.Remove(name)
+		default:
+			err = w.AddWithOptions(&git.AddOptions{Path: name, SkipStatus: true})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (x *GitCommitNode) getPattern(_ types.RuleMsg, evn map[string]interface{}) string {
+func (x *GitCommitNode) getPatterns(_ types.RuleMsg, evn map[string]interface{}) []string {
 	pattern := x.Config.Pattern
 	if evn != nil {
 		pattern = str.ExecuteTemplate(pattern, evn)
 	}
-	return pattern
+	return splitPatterns(pattern)
 }
 
 func (x *GitCommitNode) getMessage(_ types.RuleMsg, evn map[string]interface{}) string {
@@ -142,18 +355,193 @@ func (x *GitCommitNode) getMessage(_ types.RuleMsg, evn map[string]interface{})
 	return message
 }
 
-func (x *GitCommitNode) getSignatureName(_ types.RuleMsg, evn map[string]interface{}) string {
-	name := x.Config.Signature.AuthorName
-	if evn != nil {
-		name = str.ExecuteTemplate(name, evn)
+// getSignatures 获取作者和提交者签名，没有配置提交者则使用作者
+// 作者名称或者邮箱为空时使用仓库配置的 user.name 和 user.email，仍然为空则返回错误，避免创建身份为空的提交
+func getSignatures(r *git.Repository, signature Signature, evn map[string]interface{}) (*object.Signature, *object.Signature, error) {
+	getValue := func(value string) string {
+		if evn != nil {
+			value = str.ExecuteTemplate(value, evn)
+		}
+		return strings.TrimSpace(value)
+	}
+	when, err := parseSignatureTime(getValue(signature.When))
+	if err != nil {
+		return nil, nil, err
+	}
+	author := &object.Signature{
+		Name:  getValue(signature.AuthorName),
+		Email: getValue(signature.AuthorEmail),
+		When:  when,
+	}
+	if author.Name == "" || author.Email == "" {
+		cfg, err := r.Config()
+		if err != nil {
+			return nil, nil, err
+		}
+		if author.Name == "" {
+			author.Name = strings.TrimSpace(cfg.User.Name)
+		}
+		if author.Email == "" {
+			author.Email = strings.TrimSpace(cfg.User.Email)
+		}
+	}
+	if author.Name == "" {
+		return nil, nil, errors.New("commit author name is empty, set signature.authorName or user.name in the repository config")
+	}
+	if author.Email == "" {
+		return nil, nil, errors.New("commit author email is empty, set signature.authorEmail or user.email in the repository config")
+	}
+	committer := author
+	name := getValue(signature.CommitterName)
+	email := getValue(signature.CommitterEmail)
+	if name != "" || email != "" {
+		if name == "" {
+			name = author.Name
+		}
+		if email == "" {
+			email = author.Email
+		}
+		committer = &object.Signature{Name: name, Email: email, When: when}
 	}
-	return name
+	return author, committer, nil
 }
 
-func (x *GitCommitNode) getSignatureEmail(_ types.RuleMsg, evn map[string]interface{}) string {
-	email := x.Config.Signature.AuthorEmail
+func (x *GitCommitNode) getValue(value string, evn map[string]interface{}) string {
 	if evn != nil {
-		email = str.ExecuteTemplate(email, evn)
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return value
+}
+
+// validateSignature 校验没有变量的签名字段，空白字符串和包含 <、> 或换行的名称、邮箱会导致提交身份与配置不一致
+// 字段为空时使用仓库配置的 user.name 和 user.email
+func validateSignature(signature Signature) error {
+	fields := []struct{ name, value string }{
+		{"authorName", signature.AuthorName},
+		{"authorEmail", signature.AuthorEmail},
+		{"committerName", signature.CommitterName},
+		{"committerEmail", signature.CommitterEmail},
+	}
+	for _, field := range fields {
+		if field.value == "" || str.CheckHasVar(field.value) {
+			continue
+		}
+		if strings.TrimSpace(field.value) == "" {
+			return fmt.Errorf("signature.%s can not be blank", field.name)
+		}
+		if strings.ContainsAny(field.value, "<>\n") {
+			return fmt.Errorf("signature.%s can not contain <, > or line breaks", field.name)
+		}
+	}
+	return nil
+}
+
+// parseSignatureTime 解析RFC3339格式的签名时间，为空则使用当前时间
+func parseSignatureTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Now(), nil
+	}
+	when, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid signature when %s: %w", value, err)
+	}
+	return when, nil
+}
+
+// splitPatterns 拆分逗号分隔的文件模式
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			patterns = append(patterns, item)
+		}
+	}
+	return patterns
+}
+
+// hasStagedChanges 判断索引中是否有暂存的变更
+func hasStagedChanges(status git.Status) bool {
+	for _, fileStatus := range status {
+		if fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFiles 遍历文件或者目录下的所有文件，跳过 .git 目录
+func walkFiles(w *git.Worktree, root string, fn func(name string)) error {
+	return util.Walk(w.Filesystem, root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == git.GitDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fn(name)
+		return nil
+	})
+}
+
+// isGitDirPath 判断路径是否是 .git 目录或者在 .git 目录中
+func isGitDirPath(name string) bool {
+	return strings.Split(filepath.ToSlash(filepath.Clean(name)), "/")[0] == git.GitDirName
+}
+
+// matchGlobPath 判断文件路径或者其所在的任意上级目录是否匹配模式，与 AddGlob 匹配目录时递归添加目录内容的行为一致
+func matchGlobPath(pattern, name string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	for name != "." && name != "/" && name != "" {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
+
+// newCommitResult 生成提交结果，提交的文件通过比较提交与第一个父提交的树得到，只包含实际提交的文件
+func newCommitResult(r *git.Repository, hash plumbing.Hash) (CommitResult, error) {
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	result := CommitResult{CommitInfo: newCommitInfo(commit), Files: make([]CommitFile, 0)}
+	if head, err := r.Head(); err == nil && head.Name().IsBranch() {
+		result.Branch = head.Name().Short()
+	}
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return result, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return result, err
+		}
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return result, err
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return result, err
+	}
+	for _, change := range changes {
+		file := CommitFile{Path: change.To.Name, ChangeType: ChangeTypeModify}
+		if change.From.Name == "" {
+			file.ChangeType = ChangeTypeAdd
+		} else if change.To.Name == "" {
+			file.Path = change.From.Name
+			file.ChangeType = ChangeTypeDelete
+		}
+		result.Files = append(result.Files, file)
 	}
-	return email
+	result.EmptyCommit = len(result.Files) == 0
+	return result, nil
 }
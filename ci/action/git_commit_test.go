@@ -0,0 +1,471 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGitCommitNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCommitNode{})
+	var targetNodeType = "ci/gitCommit"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitCommitNode{}, types.Configuration{
+			"respectGitignore": true,
+			"appendRepoName":   true,
+		}, Registry)
+	})
+
+	commit := func(t *testing.T, configuration types.Configuration, metaData types.Metadata, repoDir string) {
+		r := initTestRepo(t, repoDir)
+		err := os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte("a"), 0644)
+		assert.Nil(t, err)
+		configuration["pattern"] = "*"
+		configuration["message"] = "add a"
+		configuration["signature"] = map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, repoDir, outMsg.Metadata.GetValue(KeyWorkDir))
+		head, _ := r.Head()
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+	}
+
+	t.Run("AppendRepoName", func(t *testing.T) {
+		tmp := t.TempDir()
+		metaData := types.NewMetadata()
+		metaData.PutValue(KeyWorkDir, tmp)
+		metaData.PutValue(KeyGitHttpUrl, "https://github.com/rulego/rulego-components-ci.git")
+		commit(t, types.Configuration{}, metaData, filepath.Join(tmp, "rulego-components-ci"))
+	})
+
+	t.Run("UpstreamWorkDir", func(t *testing.T) {
+		//上游节点输出的workDir已经包含仓库名称
+		tmp := t.TempDir()
+		metaData := types.NewMetadata()
+		metaData.PutValue(KeyWorkDir, filepath.Join(tmp, "rulego-components-ci"))
+		metaData.PutValue(KeyGitHttpUrl, "https://github.com/rulego/rulego-components-ci.git")
+		commit(t, types.Configuration{}, metaData, filepath.Join(tmp, "rulego-components-ci"))
+	})
+
+	t.Run("AppendRepoPath", func(t *testing.T) {
+		tmp := t.TempDir()
+		metaData := types.NewMetadata()
+		metaData.PutValue(KeyWorkDir, tmp)
+		metaData.PutValue(KeyGitHttpUrl, "https://github.com/rulego/rulego-components-ci.git")
+		commit(t, types.Configuration{"appendRepoPath": true}, metaData, filepath.Join(tmp, "rulego", "rulego-components-ci"))
+	})
+
+	t.Run("NotAppendRepoName", func(t *testing.T) {
+		tmp := t.TempDir()
+		metaData := types.NewMetadata()
+		metaData.PutValue(KeyGitHttpUrl, "https://github.com/rulego/rulego-components-ci.git")
+		commit(t, types.Configuration{"directory": tmp, "appendRepoName": false}, metaData, tmp)
+	})
+
+	t.Run("NoChanges", func(t *testing.T) {
+		tmp := t.TempDir()
+		initTestRepo(t, tmp)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      tmp,
+			"appendRepoName": false,
+			"pattern":        "*",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.True(t, errors.Is(err, ErrNoChanges))
+		assert.Equal(t, types.Failure, relationType)
+		_, err = git.PlainOpen(tmp)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Committer", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("a"), 0644))
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      tmp,
+			"appendRepoName": false,
+			"pattern":        "*",
+			"message":        "add a",
+			"signature": map[string]interface{}{
+				"authorName":     "${metadata.user}",
+				"authorEmail":    "${metadata.user}@rulego.cc",
+				"committerName":  "CI Bot",
+				"committerEmail": "ci@rulego.cc",
+				"when":           "2024-01-02T15:04:05+08:00",
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("user", "alice")
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		c, err := r.CommitObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.Equal(t, "alice", c.Author.Name)
+		assert.Equal(t, "alice@rulego.cc", c.Author.Email)
+		assert.Equal(t, "CI Bot", c.Committer.Name)
+		assert.Equal(t, "ci@rulego.cc", c.Committer.Email)
+		when, _ := time.Parse(time.RFC3339, "2024-01-02T15:04:05+08:00")
+		assert.True(t, when.Equal(c.Author.When))
+		assert.True(t, when.Equal(c.Committer.When))
+
+		var result CommitResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, c.Hash.String(), result.Hash)
+		assert.Equal(t, c.Hash.String()[:7], result.ShortHash)
+		assert.Equal(t, "add a", result.Message)
+		assert.Equal(t, "alice", result.AuthorName)
+		assert.Equal(t, "CI Bot", result.CommitterName)
+		assert.True(t, when.Equal(result.Time))
+		assert.Equal(t, "main", result.Branch)
+		assert.False(t, result.EmptyCommit)
+		assert.Equal(t, []CommitFile{{Path: "a.txt", ChangeType: ChangeTypeAdd}}, result.Files)
+	})
+
+	t.Run("Sign", func(t *testing.T) {
+		entity, keyring := newTestPGPEntity(t, "rulego", "rulego@rulego.cc")
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("a"), 0644))
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"pattern":           "*",
+			"signKeyArmored":    armorTestPrivateKey(t, entity, "secret"),
+			"signKeyPassphrase": "wrong",
+		}, Registry)
+		assert.NotNil(t, err)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":         tmp,
+			"appendRepoName":    false,
+			"pattern":           "*",
+			"message":           "signed",
+			"signKeyArmored":    armorTestPrivateKey(t, entity, "secret"),
+			"signKeyPassphrase": "secret",
+			"signature":         map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"},
+		}, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		c, err := r.CommitObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.True(t, c.PGPSignature != "")
+		_, err = c.Verify(keyring)
+		assert.Nil(t, err)
+	})
+
+	t.Run("ConfigSignature", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": "*", "signature": map[string]interface{}{"authorName": "  "}}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": "*", "signature": map[string]interface{}{"authorEmail": "<rulego@rulego.cc>"}}, Registry)
+		assert.NotNil(t, err)
+
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      tmp,
+			"appendRepoName": false,
+			"pattern":        "*",
+			"message":        "add a",
+			"signature":      map[string]interface{}{"authorEmail": "${metadata.email}"},
+		}, Registry)
+		assert.Nil(t, err)
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("a"), 0644))
+
+		//仓库没有配置身份
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		cfg, err := r.Config()
+		assert.Nil(t, err)
+		cfg.User.Name = "config-user"
+		cfg.User.Email = "config@rulego.cc"
+		assert.Nil(t, r.SetConfig(cfg))
+		metaData := types.NewMetadata()
+		metaData.PutValue("email", "meta@rulego.cc")
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		c, err := r.CommitObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.Equal(t, "config-user", c.Author.Name)
+		assert.Equal(t, "meta@rulego.cc", c.Author.Email)
+	})
+
+	t.Run("AllowEmptyCommit", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		parent, _ := r.Head()
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":        tmp,
+			"appendRepoName":   false,
+			"pattern":          "*",
+			"message":          "deploy ${metadata.version}",
+			"allowEmptyCommit": true,
+			"signature":        map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"},
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("version", "v1.0.0")
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyEmptyCommit))
+		head, _ := r.Head()
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+		c, err := r.CommitObject(head.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, "deploy v1.0.0", c.Message)
+		assert.Equal(t, parent.Hash(), c.ParentHashes[0])
+
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("a"), 0644))
+		outMsg, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyEmptyCommit))
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": " , "}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": "*", "signature": map[string]interface{}{"when": "2024-01-02"}}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"addAll": true}, Registry)
+		assert.Nil(t, err)
+	})
+
+	var lastResult CommitResult
+	// stageAndCommit 提交后返回HEAD树中的文件和工作区状态，提交结果保存在 lastResult
+	stageAndCommit := func(t *testing.T, r *git.Repository, dir string, configuration types.Configuration) (map[string]bool, git.Status) {
+		configuration["directory"] = dir
+		configuration["appendRepoName"] = false
+		configuration["message"] = "stage"
+		configuration["signature"] = map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		lastResult = CommitResult{}
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &lastResult))
+		head, err := r.Head()
+		assert.Nil(t, err)
+		c, err := r.CommitObject(head.Hash())
+		assert.Nil(t, err)
+		files := make(map[string]bool)
+		iter, err := c.Files()
+		assert.Nil(t, err)
+		_ = iter.ForEach(func(f *object.File) error {
+			files[f.Name] = true
+			return nil
+		})
+		w, err := r.Worktree()
+		assert.Nil(t, err)
+		status, err := w.Status()
+		assert.Nil(t, err)
+		return files, status
+	}
+
+	t.Run("MultiplePatterns", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		commitTestFile(t, r, "VERSION", "1.0.0", "add version")
+		assert.Nil(t, os.MkdirAll(filepath.Join(tmp, "dist"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "dist", "app.js"), []byte("app"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "VERSION"), []byte("1.1.0"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "notes.txt"), []byte("notes"), 0644))
+
+		files, status := stageAndCommit(t, r, tmp, types.Configuration{"pattern": "dist/*, build/*, VERSION"})
+		assert.Equal(t, []CommitFile{{Path: "VERSION", ChangeType: ChangeTypeModify}, {Path: "dist/app.js", ChangeType: ChangeTypeAdd}}, lastResult.Files)
+		assert.True(t, files["dist/app.js"])
+		assert.True(t, files["VERSION"])
+		assert.False(t, files["notes.txt"])
+		_, ok := status["VERSION"]
+		assert.False(t, ok)
+		assert.True(t, status.IsUntracked("notes.txt"))
+	})
+
+	t.Run("StageDeletions", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		commitTestFile(t, r, "docs/old.md", "old", "add old doc")
+		commitTestFile(t, r, "docs/api/v1.md", "v1", "add api doc")
+		commitTestFile(t, r, "keep.txt", "keep", "add keep")
+		assert.Nil(t, os.Remove(filepath.Join(tmp, "docs", "old.md")))
+		assert.Nil(t, os.RemoveAll(filepath.Join(tmp, "docs", "api")))
+		assert.Nil(t, os.Remove(filepath.Join(tmp, "keep.txt")))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "docs", "new.md"), []byte("new"), 0644))
+
+		files, status := stageAndCommit(t, r, tmp, types.Configuration{"pattern": "docs/*"})
+		assert.Equal(t, []CommitFile{
+			{Path: "docs/api/v1.md", ChangeType: ChangeTypeDelete},
+			{Path: "docs/new.md", ChangeType: ChangeTypeAdd},
+			{Path: "docs/old.md", ChangeType: ChangeTypeDelete},
+		}, lastResult.Files)
+		assert.False(t, files["docs/old.md"])
+		assert.False(t, files["docs/api/v1.md"])
+		assert.True(t, files["docs/new.md"])
+		// 不匹配模式的删除不提交
+		assert.True(t, files["keep.txt"])
+		assert.Equal(t, git.Deleted, status.File("keep.txt").Worktree)
+
+		// 只有删除的文件匹配模式
+		files, status = stageAndCommit(t, r, tmp, types.Configuration{"pattern": "*.txt"})
+		assert.False(t, files["keep.txt"])
+		assert.True(t, status.IsClean())
+	})
+
+	t.Run("Gitignore", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		commitTestFile(t, r, ".gitignore", "*.log\nnode_modules/\n", "add gitignore")
+		commitTestFile(t, r, "tracked.log", "v1", "add tracked log")
+		assert.Nil(t, os.MkdirAll(filepath.Join(tmp, "node_modules", "lib"), os.ModePerm))
+		assert.Nil(t, os.MkdirAll(filepath.Join(tmp, "src"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "node_modules", "lib", "index.js"), []byte("lib"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "build.log"), []byte("log"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "tracked.log"), []byte("v2"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "src", "main.go"), []byte("package main"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "src", "main_test.go"), []byte("package main"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "tmp.txt"), []byte("tmp"), 0644))
+
+		files, _ := stageAndCommit(t, r, tmp, types.Configuration{"pattern": "*,node_modules/lib/*", "excludePatterns": "*_test.go,tmp.txt"})
+		assert.True(t, files["src/main.go"])
+		// 已跟踪的文件不受 .gitignore 影响
+		assert.Equal(t, []CommitFile{{Path: "src/main.go", ChangeType: ChangeTypeAdd}, {Path: "tracked.log", ChangeType: ChangeTypeModify}}, lastResult.Files)
+
+		files, _ = stageAndCommit(t, r, tmp, types.Configuration{"addAll": true, "excludePatterns": "src"})
+		assert.True(t, files["tmp.txt"])
+		assert.False(t, files["src/main_test.go"])
+		assert.False(t, files["build.log"])
+
+		files, _ = stageAndCommit(t, r, tmp, types.Configuration{"pattern": "*.log,node_modules", "respectGitignore": false})
+		assert.True(t, files["build.log"])
+		assert.True(t, files["node_modules/lib/index.js"])
+	})
+
+	t.Run("SkipAdd", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		commitTestFile(t, r, "staged.txt", "v1", "add staged")
+		commitTestFile(t, r, "unstaged.txt", "v1", "add unstaged")
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "unstaged.txt"), []byte("v2"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "untracked.txt"), []byte("new"), 0644))
+
+		//没有暂存的变更
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      tmp,
+			"appendRepoName": false,
+			"skipAdd":        true,
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.True(t, errors.Is(err, ErrNoChanges))
+		assert.Equal(t, types.Failure, relationType)
+
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "staged.txt"), []byte("v2"), 0644))
+		w, err := r.Worktree()
+		assert.Nil(t, err)
+		_, err = w.Add("staged.txt")
+		assert.Nil(t, err)
+		_, status := stageAndCommit(t, r, tmp, types.Configuration{"skipAdd": true, "pattern": "*"})
+		assert.Equal(t, []CommitFile{{Path: "staged.txt", ChangeType: ChangeTypeModify}}, lastResult.Files)
+		assert.Equal(t, git.Modified, status.File("unstaged.txt").Worktree)
+		assert.True(t, status.IsUntracked("untracked.txt"))
+	})
+
+	t.Run("AddAll", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		commitTestFile(t, r, "old.txt", "old", "add old")
+		commitTestFile(t, r, "modified.txt", "v1", "add modified")
+		assert.Nil(t, os.Remove(filepath.Join(tmp, "old.txt")))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "modified.txt"), []byte("v2"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tmp, "new.txt"), []byte("new"), 0644))
+
+		files, status := stageAndCommit(t, r, tmp, types.Configuration{"addAll": true})
+		assert.False(t, files["old.txt"])
+		assert.True(t, files["modified.txt"])
+		assert.True(t, files["new.txt"])
+		assert.True(t, status.IsClean())
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		tmp := t.TempDir()
+		r := initTestRepo(t, tmp)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      tmp,
+			"appendRepoName": false,
+			"pattern":        "${metadata.file}",
+			"message":        "add ${metadata.file}",
+			"signature":      map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"},
+		}, Registry)
+		assert.Nil(t, err)
+
+		var wg sync.WaitGroup
+		var errs = make([]error, 2)
+		for i := 0; i < 2; i++ {
+			file := fmt.Sprintf("file%d.txt", i)
+			assert.Nil(t, os.WriteFile(filepath.Join(tmp, file), []byte(file), 0644))
+			metaData := types.NewMetadata()
+			metaData.PutValue("file", file)
+			wg.Add(1)
+			go func(i int, msg types.RuleMsg) {
+				defer wg.Done()
+				_, _, errs[i] = onMsgSync(node, msg)
+			}(i, types.NewMsg(0, "test", types.JSON, metaData, ""))
+		}
+		wg.Wait()
+		assert.Nil(t, errs[0])
+		assert.Nil(t, errs[1])
+
+		//索引没有损坏，两个文件都已提交
+		w, err := r.Worktree()
+		assert.Nil(t, err)
+		status, err := w.Status()
+		assert.Nil(t, err)
+		assert.True(t, status.IsClean())
+		iter, err := r.Log(&git.LogOptions{})
+		assert.Nil(t, err)
+		count := 0
+		_ = iter.ForEach(func(c *object.Commit) error {
+			count++
+			return nil
+		})
+		assert.Equal(t, 3, count)
+	})
+}
@@ -0,0 +1,301 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitCompareTagsNode{})
+}
+
+const (
+	// KeyFromRef 比较的起始引用，FromRef 为空时为自动查找到的上一个标签
+	KeyFromRef = "fromRef"
+	// KeyCommitCount 提交数
+	KeyCommitCount = "commitCount"
+)
+
+// ErrUnrelatedHistories 两个引用没有共同的祖先
+var ErrUnrelatedHistories = errors.New("refs have unrelated histories")
+
+// GitCompareTagsNodeConfiguration 节点配置
+type GitCompareTagsNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 起始引用(不包含)，可以是标签、分支或者提交hash，为空则使用 ToRef 之前最近的标签，没有标签则输出 ToRef 的所有历史
+	FromRef string
+	// 结束引用(包含)，默认HEAD
+	ToRef string
+	// FromRef 为空时查找上一个标签使用的标签类型，可以是 annotated 或 all，默认all
+	Tags string
+	// 最多输出的提交数，默认10000，0表示不限制
+	MaxCommits int
+}
+
+// CommitInfo 提交信息
+type CommitInfo struct {
+	// 提交hash
+	Hash string `json:"hash"`
+	// 短hash
+	ShortHash string `json:"shortHash"`
+	// 作者名称
+	AuthorName string `json:"authorName"`
+	// 作者邮箱
+	AuthorEmail string `json:"authorEmail"`
+	// 提交者名称
+	CommitterName string `json:"committerName"`
+	// 提交者邮箱
+	CommitterEmail string `json:"committerEmail"`
+	// 提交时间
+	Time time.Time `json:"time"`
+	// 提交信息的第一行
+	Subject string `json:"subject"`
+	// 完整的提交信息
+	Message string `json:"message"`
+	// 父提交hash
+	Parents []string `json:"parents"`
+}
+
+// CompareResult 比较结果
+type CompareResult struct {
+	// 起始引用
+	FromRef string `json:"fromRef"`
+	// 起始提交hash
+	FromHash string `json:"fromHash"`
+	// 结束引用
+	ToRef string `json:"toRef"`
+	// 结束提交hash
+	ToHash string `json:"toHash"`
+	// 按提交时间从新到旧排列的提交
+	Commits []CommitInfo `json:"commits"`
+	// 提交数
+	Count int `json:"count"`
+	// 合并提交数
+	Merges int `json:"merges"`
+	// 是否达到 MaxCommits 上限
+	Truncated bool `json:"truncated"`
+}
+
+// GitCompareTagsNode 列出两个引用之间的提交(从 ToRef 可达但从 FromRef 不可达)，用于生成发布说明，结果以JSON的形式写入 msg.Data
+// FromRef 为空时使用 ToRef 之前最近的标签，实际使用的起始引用和提交数写入元数据 fromRef、commitCount
+// FromRef 比 ToRef 新或者两者没有共同祖先时发送到Failure链
+type GitCompareTagsNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitCompareTagsNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitCompareTagsNode) Type() string {
+	return "ci/gitCompareTags"
+}
+
+func (x *GitCompareTagsNode) New() types.Node {
+	return &GitCompareTagsNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCompareTagsNodeConfiguration{
+			ToRef:          string(plumbing.HEAD),
+			Tags:           DescribeTagsAll,
+			MaxCommits:     10000,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitCompareTagsNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Tags = strings.ToLower(strings.TrimSpace(x.Config.Tags))
+	switch x.Config.Tags {
+	case "":
+		x.Config.Tags = DescribeTagsAll
+	case DescribeTagsAnnotated, DescribeTagsAll:
+	default:
+		return fmt.Errorf("unsupported tags: %s", x.Config.Tags)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.FromRef) || str.CheckHasVar(x.Config.ToRef) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitCompareTagsNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	toRef := x.getValue(x.Config.ToRef, evn)
+	if toRef == "" {
+		toRef = string(plumbing.HEAD)
+	}
+	to, err := resolveCommit(r, toRef)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	fromRef := x.getValue(x.Config.FromRef, evn)
+	var from *object.Commit
+	if fromRef == "" {
+		if from, fromRef, err = x.previousTag(r, to); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	} else if from, err = resolveCommit(r, fromRef); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result, err := x.compare(from, to)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result.FromRef = fromRef
+	result.ToRef = toRef
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyFromRef), fromRef)
+	msg.Metadata.PutValue(x.metaKey(KeyCommitCount), strconv.Itoa(result.Count))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitCompareTagsNode) Destroy() {
+}
+
+// previousTag 查找 to 之前最近的标签，to 本身的标签不计算在内，没有找到返回nil
+func (x *GitCompareTagsNode) previousTag(r *git.Repository, to *object.Commit) (*object.Commit, string, error) {
+	tags, err := getCommitTags(r, x.Config.Tags == DescribeTagsAll)
+	if err != nil {
+		return nil, "", err
+	}
+	var parents []*object.Commit
+	err = to.Parents().ForEach(func(parent *object.Commit) error {
+		parents = append(parents, parent)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return findNearestTag(parents, tags)
+}
+
+// compare 列出从 to 可达但从 from 不可达的提交，from 为nil则列出 to 的所有历史
+func (x *GitCompareTagsNode) compare(from, to *object.Commit) (CompareResult, error) {
+	result := CompareResult{ToHash: to.Hash.String(), Commits: make([]CommitInfo, 0)}
+	excluded := make(map[plumbing.Hash]bool)
+	if from != nil {
+		result.FromHash = from.Hash.String()
+		if from.Hash != to.Hash {
+			if newer, err := to.IsAncestor(from); err != nil {
+				return result, err
+			} else if newer {
+				return result, fmt.Errorf("fromRef %s is newer than toRef %s", from.Hash, to.Hash)
+			}
+			bases, err := from.MergeBase(to)
+			if err != nil {
+				return result, err
+			}
+			if len(bases) == 0 {
+				return result, fmt.Errorf("%w: %s and %s", ErrUnrelatedHistories, from.Hash, to.Hash)
+			}
+		}
+		err := object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	err := object.NewCommitIterCTime(to, excluded, nil).ForEach(func(c *object.Commit) error {
+		if x.Config.MaxCommits > 0 && len(result.Commits) >= x.Config.MaxCommits {
+			result.Truncated = true
+			return storer.ErrStop
+		}
+		result.Commits = append(result.Commits, newCommitInfo(c))
+		if c.NumParents() > 1 {
+			result.Merges++
+		}
+		return nil
+	})
+	result.Count = len(result.Commits)
+	return result, err
+}
+
+func (x *GitCompareTagsNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// newCommitInfo 转换提交信息
+func newCommitInfo(c *object.Commit) CommitInfo {
+	hash := c.Hash.String()
+	info := CommitInfo{
+		Hash:           hash,
+		ShortHash:      hash[:7],
+		AuthorName:     c.Author.Name,
+		AuthorEmail:    c.Author.Email,
+		CommitterName:  c.Committer.Name,
+		CommitterEmail: c.Committer.Email,
+		Time:           c.Committer.When,
+		Subject:        strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0]),
+		Message:        c.Message,
+		Parents:        make([]string, 0, len(c.ParentHashes)),
+	}
+	for _, parent := range c.ParentHashes {
+		info.Parents = append(info.Parents, parent.String())
+	}
+	return info
+}
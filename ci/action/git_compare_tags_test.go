@@ -0,0 +1,146 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestGitCompareTagsNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCompareTagsNode{})
+	var targetNodeType = "ci/gitCompareTags"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitCompareTagsNode{}, types.Configuration{
+			"toRef":          "HEAD",
+			"tags":           DescribeTagsAll,
+			"maxCommits":     10000,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"tags": "lightweight"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	v1 := commitTestFile(t, r, "a.txt", "1", "release 1.0")
+	_, err := r.CreateTag("v1.0.0", v1, nil)
+	assert.Nil(t, err)
+	time.Sleep(time.Second)
+	fix := commitTestFile(t, r, "a.txt", "2", "fix: bug")
+	time.Sleep(time.Second)
+	feat := commitTestFile(t, r, "b.txt", "1", "feat: new api\n\ndetails")
+	tagger := testSignature
+	tagger.When = time.Now()
+	_, err = r.CreateTag("v1.1.0", feat, &git.CreateTagOptions{Tagger: &tagger, Message: "v1.1.0"})
+	assert.Nil(t, err)
+	next := commitTestFile(t, r, "c.txt", "1", "chore: next")
+
+	// 没有共同祖先的提交
+	head, _ := r.CommitObject(next)
+	orphan := &object.Commit{Author: head.Author, Committer: head.Committer, Message: "orphan", TreeHash: head.TreeHash}
+	obj := r.Storer.NewEncodedObject()
+	assert.Nil(t, orphan.Encode(obj))
+	orphanHash, err := r.Storer.SetEncodedObject(obj)
+	assert.Nil(t, err)
+
+	compare := func(t *testing.T, config types.Configuration) (CompareResult, types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.TEXT, types.NewMetadata(), ""))
+		var result CompareResult
+		if err == nil {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		}
+		return result, outMsg, relationType, err
+	}
+
+	t.Run("BetweenTags", func(t *testing.T) {
+		result, outMsg, relationType, err := compare(t, types.Configuration{"fromRef": "v1.0.0", "toRef": "v1.1.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, v1.String(), result.FromHash)
+		assert.Equal(t, feat.String(), result.ToHash)
+		assert.Equal(t, 2, result.Count)
+		assert.Equal(t, feat.String(), result.Commits[0].Hash)
+		assert.Equal(t, "feat: new api", result.Commits[0].Subject)
+		assert.Equal(t, fix.String(), result.Commits[1].Hash)
+		assert.Equal(t, []string{v1.String()}, result.Commits[1].Parents)
+		assert.Equal(t, "2", outMsg.Metadata.GetValue(KeyCommitCount))
+		assert.Equal(t, "v1.0.0", outMsg.Metadata.GetValue(KeyFromRef))
+	})
+
+	t.Run("PreviousTag", func(t *testing.T) {
+		// ToRef 本身有标签时使用更早的标签
+		result, outMsg, _, err := compare(t, types.Configuration{"toRef": "v1.1.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.0.0", result.FromRef)
+		assert.Equal(t, 2, result.Count)
+		assert.Equal(t, "v1.0.0", outMsg.Metadata.GetValue(KeyFromRef))
+
+		result, _, _, err = compare(t, types.Configuration{})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.1.0", result.FromRef)
+		assert.Equal(t, 1, result.Count)
+		assert.Equal(t, next.String(), result.Commits[0].Hash)
+
+		result, _, _, err = compare(t, types.Configuration{"toRef": "v1.1.0", "tags": DescribeTagsAnnotated})
+		assert.Nil(t, err)
+		assert.Equal(t, "", result.FromRef)
+		assert.Equal(t, 4, result.Count)
+	})
+
+	t.Run("MaxCommits", func(t *testing.T) {
+		result, _, _, err := compare(t, types.Configuration{"toRef": v1.String(), "maxCommits": 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Count)
+		assert.True(t, result.Truncated)
+	})
+
+	t.Run("OutOfOrder", func(t *testing.T) {
+		_, _, relationType, err := compare(t, types.Configuration{"fromRef": "v1.1.0", "toRef": "v1.0.0"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("Unrelated", func(t *testing.T) {
+		_, _, relationType, err := compare(t, types.Configuration{"fromRef": orphanHash.String(), "toRef": "HEAD"})
+		assert.True(t, errors.Is(err, ErrUnrelatedHistories))
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("SameRef", func(t *testing.T) {
+		result, _, _, err := compare(t, types.Configuration{"fromRef": "HEAD", "toRef": plumbing.Main.Short()})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, result.Count)
+	})
+}
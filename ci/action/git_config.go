@@ -0,0 +1,338 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitConfigNode{})
+}
+
+const (
+	// ConfigActionGet 读取配置
+	ConfigActionGet = "get"
+	// ConfigActionSet 设置配置
+	ConfigActionSet = "set"
+	// ConfigActionUnset 删除配置
+	ConfigActionUnset = "unset"
+	// ConfigScopeLocal 仓库配置，即 .git/config
+	ConfigScopeLocal = "local"
+)
+
+// GitConfigNodeConfiguration 节点配置
+type GitConfigNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 get、set 或 unset，默认get
+	Action string
+	// 配置范围，目前只支持 local
+	Scope string
+	// 配置项，key 为 section.key 或者 section.subsection.key，例如：user.name、remote.origin.url
+	// set 时 value 为字符串或者字符串数组(多值配置项，会替换原有的所有值)，字符串支持 ${} 占位符
+	// get 和 unset 时只使用 key，get 时为空则读取所有配置
+	Entries map[string]interface{}
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitConfigNode 读取或者修改仓库配置，例如在其他工具执行前设置 user.name、user.email 或 http.extraHeader
+// 结果以JSON对象的形式写入 msg.Data，key 为 section.subsection.key，单值配置项的 value 为字符串，多值配置项为字符串数组
+// set 和 unset 输出操作后这些配置项的值，不存在的配置项不会输出
+type GitConfigNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitConfigNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitConfigNode) Type() string {
+	return "ci/gitConfig"
+}
+
+func (x *GitConfigNode) New() types.Node {
+	return &GitConfigNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitConfigNodeConfiguration{
+			Action:         ConfigActionGet,
+			Scope:          ConfigScopeLocal,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitConfigNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = ConfigActionGet
+	case ConfigActionGet, ConfigActionSet, ConfigActionUnset:
+	default:
+		return fmt.Errorf("unsupported config action: %s", x.Config.Action)
+	}
+	x.Config.Scope = strings.ToLower(strings.TrimSpace(x.Config.Scope))
+	if x.Config.Scope == "" {
+		x.Config.Scope = ConfigScopeLocal
+	} else if x.Config.Scope != ConfigScopeLocal {
+		return fmt.Errorf("unsupported config scope: %s", x.Config.Scope)
+	}
+	if x.Config.Action != ConfigActionGet && len(x.Config.Entries) == 0 {
+		return errors.New("entries can not be empty")
+	}
+	for key, value := range x.Config.Entries {
+		if _, _, _, err = parseConfigKey(key); err != nil {
+			return err
+		}
+		if x.Config.Action == ConfigActionSet {
+			values, err := configEntryValues(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			for _, v := range values {
+				if str.CheckHasVar(v) {
+					x.hasVar = true
+				}
+			}
+		}
+	}
+	if str.CheckHasVar(x.Config.Directory) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitConfigNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	if x.Config.Action != ConfigActionGet {
+		unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		defer unlock()
+	}
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	keys := make([]string, 0, len(x.Config.Entries))
+	for key := range x.Config.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if x.Config.Action != ConfigActionGet {
+		for _, key := range keys {
+			section, subsection, name, _ := parseConfigKey(key)
+			var values []string
+			if x.Config.Action == ConfigActionSet {
+				values, _ = configEntryValues(x.Config.Entries[key])
+				if evn != nil {
+					for i, v := range values {
+						values[i] = str.ExecuteTemplate(v, evn)
+					}
+				}
+			}
+			setRawOption(cfg.Raw, section, subsection, name, values)
+		}
+		if err = saveRawConfig(r, cfg.Raw); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	result := make(map[string]interface{})
+	if len(keys) == 0 {
+		for _, s := range cfg.Raw.Sections {
+			putRawOptions(result, s.Name, "", s.Options)
+			for _, ss := range s.Subsections {
+				putRawOptions(result, s.Name, ss.Name, ss.Options)
+			}
+		}
+	} else {
+		for _, key := range keys {
+			section, subsection, name, _ := parseConfigKey(key)
+			if values := getRawOption(cfg.Raw, section, subsection, name); len(values) > 0 {
+				result[key] = configOutputValue(values)
+			}
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitConfigNode) Destroy() {
+}
+
+// parseConfigKey 解析配置项，第一个点之前为 section，最后一个点之后为 key，中间为 subsection，subsection 可以包含点
+func parseConfigKey(key string) (string, string, string, error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("invalid config key: %s", key)
+	}
+	if first == last {
+		return key[:first], format.NoSubsection, key[last+1:], nil
+	}
+	return key[:first], key[first+1 : last], key[last+1:], nil
+}
+
+// configEntryValues 把配置的 value 转换为字符串数组
+func configEntryValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, errors.New("value can not be empty")
+	case []string:
+		return append([]string(nil), v...), nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, str.ToString(item))
+		}
+		return values, nil
+	default:
+		return []string{str.ToString(v)}, nil
+	}
+}
+
+// configOutputValue 单值配置项输出字符串，多值配置项输出数组
+func configOutputValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// getRawOption 读取配置项的所有值，不会创建不存在的 section
+func getRawOption(raw *format.Config, section, subsection, key string) []string {
+	for _, s := range raw.Sections {
+		if !s.IsName(section) {
+			continue
+		}
+		if subsection == format.NoSubsection {
+			return s.OptionAll(key)
+		}
+		for _, ss := range s.Subsections {
+			if ss.IsName(subsection) {
+				return ss.OptionAll(key)
+			}
+		}
+	}
+	return nil
+}
+
+// setRawOption 用 values 替换配置项的所有值，values 为空则删除该配置项，删除后为空的 section 同时删除
+func setRawOption(raw *format.Config, section, subsection, key string, values []string) {
+	if len(values) == 0 && !raw.HasSection(section) {
+		return
+	}
+	s := raw.Section(section)
+	if subsection == format.NoSubsection {
+		s.RemoveOption(key)
+		for _, v := range values {
+			s.AddOption(key, v)
+		}
+	} else {
+		if len(values) == 0 && !s.HasSubsection(subsection) {
+			return
+		}
+		ss := s.Subsection(subsection)
+		ss.RemoveOption(key)
+		for _, v := range values {
+			ss.AddOption(key, v)
+		}
+		if len(ss.Options) == 0 {
+			s.RemoveSubsection(subsection)
+		}
+	}
+	if len(s.Options) == 0 && len(s.Subsections) == 0 {
+		raw.RemoveSection(section)
+	}
+}
+
+// putRawOptions 把 section 中的所有配置项写入 result
+func putRawOptions(result map[string]interface{}, section, subsection string, options format.Options) {
+	prefix := section + "."
+	if subsection != format.NoSubsection {
+		prefix += subsection + "."
+	}
+	var keys []string
+	for _, o := range options {
+		key := strings.ToLower(o.Key)
+		if _, ok := result[prefix+key]; !ok {
+			keys = append(keys, key)
+			result[prefix+key] = nil
+		}
+	}
+	for _, key := range keys {
+		result[prefix+key] = configOutputValue(options.GetAll(key))
+	}
+}
+
+// saveRawConfig 保存修改后的原始配置
+// go-git 保存配置时会用 Remotes、Branches 等结构化字段覆盖原始配置，所以需要先从原始配置重新解析
+func saveRawConfig(r *git.Repository, raw *format.Config) error {
+	var buf bytes.Buffer
+	if err := format.NewEncoder(&buf).Encode(raw); err != nil {
+		return err
+	}
+	cfg := config.NewConfig()
+	if err := cfg.Unmarshal(buf.Bytes()); err != nil {
+		return err
+	}
+	return r.SetConfig(cfg)
+}
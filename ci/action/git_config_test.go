@@ -0,0 +1,141 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestGitConfigNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitConfigNode{})
+	var targetNodeType = "ci/gitConfig"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitConfigNode{}, types.Configuration{
+			"action":         ConfigActionGet,
+			"scope":          ConfigScopeLocal,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "edit"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"scope": "global"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "set"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "set", "entries": map[string]interface{}{"name": "x"}}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	initTestRepo(t, dir)
+
+	run := func(t *testing.T, config types.Configuration, metadata types.Metadata) map[string]interface{} {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		result := make(map[string]interface{})
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+
+	t.Run("Set", func(t *testing.T) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("token", "abc")
+		result := run(t, types.Configuration{
+			"action": ConfigActionSet,
+			"entries": map[string]interface{}{
+				"user.name":                            "rulego-bot",
+				"user.email":                           "bot@rulego.cc",
+				"remote.origin.url":                    "https://github.com/rulego/rulego.git",
+				"remote.origin.fetch":                  []interface{}{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"},
+				"http.https://github.com/.extraHeader": "Authorization: Bearer ${metadata.token}",
+				"branch.release/v1.0.remote":           "origin",
+				"branch.release/v1.0.merge":            "refs/heads/release/v1.0",
+			},
+		}, metadata)
+		assert.Equal(t, "rulego-bot", result["user.name"])
+		assert.Equal(t, 2, len(result["remote.origin.fetch"].([]interface{})))
+		assert.Equal(t, "Authorization: Bearer abc", result["http.https://github.com/.extraHeader"])
+
+		r, err := git.PlainOpen(dir)
+		assert.Nil(t, err)
+		cfg, err := r.Config()
+		assert.Nil(t, err)
+		assert.Equal(t, "rulego-bot", cfg.User.Name)
+		assert.Equal(t, "bot@rulego.cc", cfg.User.Email)
+		assert.Equal(t, "https://github.com/rulego/rulego.git", cfg.Remotes["origin"].URLs[0])
+		assert.Equal(t, 2, len(cfg.Remotes["origin"].Fetch))
+		assert.Equal(t, "origin", cfg.Branches["release/v1.0"].Remote)
+		assert.Equal(t, "Authorization: Bearer abc", cfg.Raw.Section("http").Subsection("https://github.com/").Option("extraHeader"))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		result := run(t, types.Configuration{
+			"entries": map[string]interface{}{"remote.origin.url": "", "remote.origin.fetch": "", "user.signingkey": ""},
+		}, types.NewMetadata())
+		assert.Equal(t, 2, len(result))
+		assert.Equal(t, "https://github.com/rulego/rulego.git", result["remote.origin.url"])
+		fetch := result["remote.origin.fetch"].([]interface{})
+		assert.Equal(t, "+refs/tags/*:refs/tags/*", fetch[1])
+
+		result = run(t, types.Configuration{}, types.NewMetadata())
+		assert.Equal(t, "rulego-bot", result["user.name"])
+		assert.Equal(t, "origin", result["branch.release/v1.0.remote"])
+		assert.Equal(t, "false", result["core.bare"])
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		result := run(t, types.Configuration{
+			"action":  ConfigActionUnset,
+			"entries": map[string]interface{}{"http.https://github.com/.extraHeader": "", "user.email": "", "user.name": "", "core.missing": ""},
+		}, types.NewMetadata())
+		assert.Equal(t, 0, len(result))
+
+		r, err := git.PlainOpen(dir)
+		assert.Nil(t, err)
+		cfg, err := r.Config()
+		assert.Nil(t, err)
+		assert.Equal(t, "", cfg.User.Name)
+		assert.False(t, cfg.Raw.HasSection("http"))
+		// go-git 保存配置时总会写入空的 user section
+		assert.Equal(t, 0, len(cfg.Raw.Section("user").Options))
+		assert.Equal(t, 2, len(cfg.Remotes["origin"].Fetch))
+	})
+
+	t.Run("ParseConfigKey", func(t *testing.T) {
+		section, subsection, key, err := parseConfigKey("url.git@github.com:.insteadOf")
+		assert.Nil(t, err)
+		assert.Equal(t, "url", section)
+		assert.Equal(t, "git@github.com:", subsection)
+		assert.Equal(t, "insteadOf", key)
+		_, _, _, err = parseConfigKey("user.")
+		assert.NotNil(t, err)
+	})
+}
@@ -17,13 +17,19 @@
 package action
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/rulego/rulego"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
-	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,24 +37,109 @@ func init() {
 	_ = rulego.Registry.Register(&GitCreateTagNode{})
 }
 
+// KeyAnnotated 创建的是否是附注标签，附注标签的 hash 是标签对象hash，轻量标签的 hash 是提交hash
+const KeyAnnotated = "annotated"
+
+// KeyTagExisted 标签在创建前是否已经存在
+const KeyTagExisted = "tagExisted"
+
+const (
+	// ExistingTagFail 标签已经存在时失败
+	ExistingTagFail = "fail"
+	// ExistingTagSkip 标签已经存在时不做任何修改，直接成功
+	ExistingTagSkip = "skip"
+	// ExistingTagReplace 标签已经存在时删除后重新创建
+	ExistingTagReplace = "replace"
+)
+
+const (
+	// KeyPreviousTag 自动计算版本时，当前最新的语义化版本标签，没有则为空
+	KeyPreviousTag = "previousTag"
+	// KeyNewTag 创建的标签名称
+	KeyNewTag = "newTag"
+)
+
+const (
+	// SemverBumpNone 不自动计算版本，使用配置的标签名称
+	SemverBumpNone = "none"
+	// SemverBumpPatch 递增修订号
+	SemverBumpPatch = "patch"
+	// SemverBumpMinor 递增次版本号，并把修订号置0
+	SemverBumpMinor = "minor"
+	// SemverBumpMajor 递增主版本号，并把次版本号和修订号置0
+	SemverBumpMajor = "major"
+)
+
+// KeyTagRefSpec 把创建或者删除操作推送到远程仓库使用的refspec，创建为 refs/tags/{tag}:refs/tags/{tag}，删除为 :refs/tags/{tag}
+// 可以在后续的 GitPushNode 中配置 refSpecs 为 ${metadata.tagRefSpec} 同步远程标签
+const KeyTagRefSpec = "tagRefSpec"
+
+// ErrNoCommits 仓库还没有任何提交，无法创建标签
+var ErrNoCommits = errors.New("repository has no commits yet")
+
 // GitCreateTagNodeConfiguration 节点配置
 type GitCreateTagNodeConfiguration struct {
 	// 本地目录
 	Directory string
-	// 标签名称
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 create 或 delete，默认create
+	Action string
+	// 删除不存在的标签时是否忽略，默认失败
+	IgnoreMissing bool
+	// 标签名称，为空并且 SemverBump 不为 none 时根据已有标签自动计算
 	Tag string
-	// 注释消息
+	// 自动计算下一个语义化版本的方式，可以是 none、patch、minor 或 major，默认none，允许使用 ${} 占位符变量
+	// 找到匹配 TagPrefix 的最新版本标签后递增，最新版本是先行版本时，如果递增后的版本号与先行版本相同则直接发布该版本，例如：v1.2.0-rc.1 递增 patch 或 minor 都得到 v1.2.0
+	SemverBump string
+	// 自动计算版本时匹配的标签前缀，默认v
+	TagPrefix string
+	// 没有匹配的版本标签时使用的初始版本，不包含前缀，默认0.1.0
+	InitialVersion string
+	// 标签指向的提交，可以是提交hash、分支或者标签，例如：${metadata.hash}，为空则使用HEAD
+	CommitHash string
+	// 是否创建附注标签，默认true，false则创建直接指向提交的轻量标签，不需要注释消息和签名
+	Annotated bool
+	// 标签已经存在时的处理方式，可以是 fail、skip 或 replace，默认fail
+	// skip 不做任何修改，replace 删除已有的标签后重新创建，并把原标签指向的提交hash写入元数据 oldHash
+	ExistingTag string
+	// 注释消息，只用于附注标签
 	Message string
 	//签名
 	Signature Signature
+	// 签名使用的PGP私钥，可以是 ASCII armored 内容或者文件路径，支持 env://变量名 或 file://文件路径 读取
+	SignKeyArmored string
+	// PGP私钥的密码，私钥没有加密则为空，支持 env://变量名 或 file://文件路径 读取
+	SignKeyPassphrase string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
 }
 
-// GitCreateTagNode 实现 Git 推送
+// TagResult 创建或者删除标签的结果
+type TagResult struct {
+	// 操作，create 或 delete
+	Action string `json:"action"`
+	// 标签信息，Hash 是标签指向的提交hash
+	TagInfo
+	// 标签引用的hash，附注标签是标签对象hash，轻量标签是提交hash
+	RefHash string `json:"refHash"`
+	// 操作前标签是否已经存在
+	Existed bool `json:"existed"`
+	// 把该操作推送到远程仓库使用的refspec
+	RefSpec string `json:"refSpec"`
+}
+
+// GitCreateTagNode 创建或者删除本地标签，支持附注标签和轻量标签
+// 结果以JSON的形式写入 msg.Data，标签引用的hash写入元数据 hash，推送使用的refspec写入元数据 tagRefSpec
 type GitCreateTagNode struct {
 	baseGitNode
 	// 节点配置
 	Config GitCreateTagNodeConfiguration
 	hasVar bool
+	// 签名使用的PGP私钥
+	signKey *openpgp.Entity
 }
 
 // Type 组件类型
@@ -57,16 +148,61 @@ func (x *GitCreateTagNode) Type() string {
 }
 
 func (x *GitCreateTagNode) New() types.Node {
-	return &GitCreateTagNode{Config: GitCreateTagNodeConfiguration{}}
+	return &GitCreateTagNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitCreateTagNodeConfiguration{
+			Action:         TagActionCreate,
+			AppendRepoName: true,
+			Annotated:      true,
+			ExistingTag:    ExistingTagFail,
+			SemverBump:     SemverBumpNone,
+			TagPrefix:      "v",
+			InitialVersion: "0.1.0",
+		},
+	}
 }
 
 // Init 初始化
 func (x *GitCreateTagNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
-	err := maps.Map2Struct(configuration, &x.Config)
-	err = maps.Map2Struct(configuration, &x.baseGitNode.Config)
-	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Tag) || str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) {
+	err := x.initConfig(configuration, &x.Config)
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	if err == nil {
+		switch x.Config.Action {
+		case "":
+			x.Config.Action = TagActionCreate
+		case TagActionCreate:
+		case TagActionDelete:
+			if strings.TrimSpace(x.Config.Tag) == "" {
+				err = errors.New("tag can not be empty")
+			}
+		default:
+			err = fmt.Errorf("unsupported tag action: %s", x.Config.Action)
+		}
+	}
+	if err == nil && !x.Config.Annotated && strings.TrimSpace(x.Config.Message) != "" {
+		err = errors.New("message can not be set when annotated is false")
+	}
+	if err == nil {
+		switch x.Config.ExistingTag {
+		case "", ExistingTagFail, ExistingTagSkip, ExistingTagReplace:
+		default:
+			err = errors.New("not existingTag=" + x.Config.ExistingTag)
+		}
+	}
+	if err == nil && !str.CheckHasVar(x.Config.SemverBump) {
+		err = checkSemverBump(x.Config.SemverBump)
+	}
+	if err == nil && x.Config.InitialVersion != "" {
+		if _, ok := parseSemver(x.Config.InitialVersion); !ok {
+			err = errors.New("initialVersion is not a semantic version: " + x.Config.InitialVersion)
+		}
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Tag) || str.CheckHasVar(x.Config.CommitHash) || str.CheckHasVar(x.Config.SemverBump) || str.CheckHasVar(x.Config.Message) || str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) {
 		x.hasVar = true
 	}
+	if err == nil && strings.TrimSpace(x.Config.SignKeyArmored) != "" {
+		x.signKey, err = loadSignKey(x.Config.SignKeyArmored, x.Config.SignKeyPassphrase)
+	}
 	return err
 }
 
@@ -77,46 +213,185 @@ func (x *GitCreateTagNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
 	}
 	workDir := x.getWorkDir(msg, evn)
-	msg.Metadata.PutValue(KeyWorkDir, workDir)
-	// 打开仓库
-	r, err := git.PlainOpen(workDir)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return
 	}
-	commit, err := r.Head()
-	if err != nil {
-		// 处理错误
-	}
-
-	// 获取提交对象
-	commitObj, err := r.CommitObject(commit.Hash())
+	defer unlock()
+	// 打开仓库
+	r, err := x.openLockedRepository(msg, workDir)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return
 	}
-
-	tagger := object.Signature{
-		Name:  x.getSignatureName(msg, evn),
-		Email: x.getSignatureEmail(msg, evn),
-		When:  time.Now(),
+	var result *TagResult
+	if x.Config.Action == TagActionDelete {
+		result, err = x.delete(r, msg, strings.TrimSpace(x.getTag(msg, evn)))
+	} else {
+		result, err = x.create(r, msg, evn)
 	}
-	opts := &git.CreateTagOptions{
-		Tagger:  &tagger,
-		Message: x.getMessage(msg, evn),
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
 	}
-	// 创建附注标签
-	annotatedTag, err := r.CreateTag(x.getTag(msg, evn), commitObj.Hash, opts)
+	data, err := json.Marshal(result)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return
 	}
-	msg.Metadata.PutValue(KeyHash, annotatedTag.Hash().String())
+	msg.Metadata.PutValue(x.metaKey(KeyTagRefSpec), result.RefSpec)
+	msg.DataType = types.JSON
+	msg.Data = string(data)
 	ctx.TellSuccess(msg)
 }
 
 // Destroy 销毁
 func (x *GitCreateTagNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// create 创建标签，标签已经存在时按 ExistingTag 处理
+func (x *GitCreateTagNode) create(r *git.Repository, msg types.RuleMsg, evn map[string]interface{}) (*TagResult, error) {
+	commitObj, err := x.resolveTarget(r, msg, evn)
+	if err != nil {
+		return nil, err
+	}
+	tagName := strings.TrimSpace(x.getTag(msg, evn))
+	if tagName == "" {
+		bump := x.getSemverBump(msg, evn)
+		if err = checkSemverBump(bump); err != nil {
+			return nil, err
+		}
+		if bump == "" || bump == SemverBumpNone {
+			return nil, errors.New("tag can not be empty when semverBump is none")
+		}
+		previousTag, nextTag, err := nextSemverTag(r, x.Config.TagPrefix, bump, x.Config.InitialVersion)
+		if err != nil {
+			return nil, err
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyPreviousTag), previousTag)
+		tagName = nextTag
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyNewTag), tagName)
+	existing, err := r.Tag(tagName)
+	if err != nil && !errors.Is(err, git.ErrTagNotFound) {
+		return nil, err
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyTagExisted), strconv.FormatBool(existing != nil))
+	if existing != nil {
+		switch x.Config.ExistingTag {
+		case ExistingTagSkip:
+			return x.newResult(r, msg, TagActionCreate, existing, true)
+		case ExistingTagReplace:
+			oldHash := existing.Hash()
+			if tag, err := r.TagObject(oldHash); err == nil {
+				oldHash = tag.Target
+			}
+			if err = r.DeleteTag(tagName); err != nil {
+				return nil, err
+			}
+			msg.Metadata.PutValue(x.metaKey(KeyOldHash), oldHash.String())
+		default:
+			return nil, fmt.Errorf("%w: %s", git.ErrTagExists, tagName)
+		}
+	}
+
+	var opts *git.CreateTagOptions
+	if x.Config.Annotated {
+		tagger := object.Signature{
+			Name:  x.getSignatureName(msg, evn),
+			Email: x.getSignatureEmail(msg, evn),
+			When:  time.Now(),
+		}
+		opts = &git.CreateTagOptions{
+			Tagger:  &tagger,
+			Message: x.getMessage(msg, evn),
+			SignKey: x.signKey,
+		}
+	}
+	// 选项为nil时创建轻量标签，否则创建附注标签
+	tagRef, err := r.CreateTag(tagName, commitObj.Hash, opts)
+	if err != nil {
+		// 创建失败时恢复被替换的标签
+		if existing != nil {
+			if restoreErr := r.Storer.SetReference(existing); restoreErr != nil {
+				return nil, errors.Join(err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+	return x.newResult(r, msg, TagActionCreate, tagRef, existing != nil)
+}
+
+// delete 删除本地标签，标签不存在时如果配置了 IgnoreMissing 则直接成功
+func (x *GitCreateTagNode) delete(r *git.Repository, msg types.RuleMsg, tagName string) (*TagResult, error) {
+	existing, err := r.Tag(tagName)
+	if errors.Is(err, git.ErrTagNotFound) {
+		if !x.Config.IgnoreMissing {
+			return nil, fmt.Errorf("%w: %s", err, tagName)
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyTagExisted), "false")
+		return &TagResult{
+			Action:  TagActionDelete,
+			TagInfo: TagInfo{Name: tagName},
+			RefSpec: ":" + plumbing.NewTagReferenceName(tagName).String(),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	result, err := x.newResult(r, msg, TagActionDelete, existing, true)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.DeleteTag(tagName); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// resolveTarget 获取标签指向的提交，没有配置 CommitHash 则使用HEAD
+func (x *GitCreateTagNode) resolveTarget(r *git.Repository, msg types.RuleMsg, evn map[string]interface{}) (*object.Commit, error) {
+	if commitHash := x.getCommitHash(msg, evn); commitHash != "" {
+		commitObj, err := resolveCommit(r, commitHash)
+		if err != nil {
+			return nil, fmt.Errorf("resolve commit %s: %w", commitHash, err)
+		}
+		return commitObj, nil
+	}
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, ErrNoCommits
+	} else if err != nil {
+		return nil, fmt.Errorf("resolve HEAD: %w", err)
+	}
+	commitObj, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("get commit %s: %w", head.Hash(), err)
+	}
+	return commitObj, nil
+}
+
+// newResult 根据标签引用生成结果，并把标签引用的hash以及是否附注标签写入元数据
+func (x *GitCreateTagNode) newResult(r *git.Repository, msg types.RuleMsg, action string, ref *plumbing.Reference, existed bool) (*TagResult, error) {
+	info, err := newTagInfo(r, ref)
+	if err != nil {
+		return nil, err
+	}
+	refSpec := ref.Name().String() + ":" + ref.Name().String()
+	if action == TagActionDelete {
+		refSpec = ":" + ref.Name().String()
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), ref.Hash().String())
+	msg.Metadata.PutValue(x.metaKey(KeyAnnotated), strconv.FormatBool(info.Annotated))
+	return &TagResult{
+		Action:  action,
+		TagInfo: info,
+		RefHash: ref.Hash().String(),
+		Existed: existed,
+		RefSpec: refSpec,
+	}, nil
 }
 
 func (x *GitCreateTagNode) getTag(_ types.RuleMsg, evn map[string]interface{}) string {
@@ -127,6 +402,22 @@ func (x *GitCreateTagNode) getTag(_ types.RuleMsg, evn map[string]interface{}) s
 	return tag
 }
 
+func (x *GitCreateTagNode) getCommitHash(_ types.RuleMsg, evn map[string]interface{}) string {
+	commitHash := x.Config.CommitHash
+	if evn != nil {
+		commitHash = str.ExecuteTemplate(commitHash, evn)
+	}
+	return strings.TrimSpace(commitHash)
+}
+
+func (x *GitCreateTagNode) getSemverBump(_ types.RuleMsg, evn map[string]interface{}) string {
+	bump := x.Config.SemverBump
+	if evn != nil {
+		bump = str.ExecuteTemplate(bump, evn)
+	}
+	return strings.TrimSpace(bump)
+}
+
 func (x *GitCreateTagNode) getMessage(_ types.RuleMsg, evn map[string]interface{}) string {
 	message := x.Config.Message
 	if evn != nil {
@@ -150,3 +441,72 @@ func (x *GitCreateTagNode) getSignatureEmail(_ types.RuleMsg, evn map[string]int
 	}
 	return email
 }
+
+// checkSemverBump 检查版本递增方式
+func checkSemverBump(bump string) error {
+	switch bump {
+	case "", SemverBumpNone, SemverBumpPatch, SemverBumpMinor, SemverBumpMajor:
+		return nil
+	default:
+		return errors.New("not semverBump=" + bump)
+	}
+}
+
+// nextSemverTag 找到以 prefix 开头的最新语义化版本标签并按 bump 递增，返回最新的标签和新标签，没有匹配的标签时新标签使用初始版本
+func nextSemverTag(r *git.Repository, prefix, bump, initialVersion string) (string, string, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return "", "", err
+	}
+	var previousTag string
+	var latest semver
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		v, ok := parseSemver(strings.TrimPrefix(name, prefix))
+		if !ok {
+			return nil
+		}
+		if previousTag == "" || compareSemver(strings.TrimPrefix(name, prefix), strings.TrimPrefix(previousTag, prefix)) > 0 {
+			previousTag = name
+			latest = v
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if previousTag == "" {
+		if initialVersion == "" {
+			initialVersion = "0.1.0"
+		}
+		return "", prefix + initialVersion, nil
+	}
+	next := bumpSemver(latest, bump)
+	return previousTag, fmt.Sprintf("%s%d.%d.%d", prefix, next.major, next.minor, next.patch), nil
+}
+
+// bumpSemver 递增版本号，先行版本递增后与其正式版本相同时直接发布正式版本
+func bumpSemver(v semver, bump string) semver {
+	isPrerelease := len(v.prerelease) > 0
+	switch bump {
+	case SemverBumpMajor:
+		if !isPrerelease || v.minor != 0 || v.patch != 0 {
+			v.major++
+		}
+		v.minor, v.patch = 0, 0
+	case SemverBumpMinor:
+		if !isPrerelease || v.patch != 0 {
+			v.minor++
+		}
+		v.patch = 0
+	default:
+		if !isPrerelease {
+			v.patch++
+		}
+	}
+	v.prerelease = nil
+	return v
+}
@@ -0,0 +1,338 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func TestGitCreateTagNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCreateTagNode{})
+	var targetNodeType = "ci/gitCreateTag"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitCreateTagNode{}, types.Configuration{
+			"action":         "create",
+			"appendRepoName": true,
+			"annotated":      true,
+			"existingTag":    "fail",
+			"semverBump":     "none",
+			"tagPrefix":      "v",
+			"initialVersion": "0.1.0",
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"tag": "v1.0.0", "annotated": false, "message": "release"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"tag": "v1.0.0", "existingTag": "ignore"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "delete"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"tag": "v1.0.0", "action": "move"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"semverBump": "build"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"semverBump": "patch", "initialVersion": "first"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	createTag := func(t *testing.T, dir string, config types.Configuration, metadata types.Metadata) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		config["signature"] = map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+	}
+
+	t.Run("CreateTag", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		metadata := types.NewMetadata()
+		metadata.PutValue("version", "v1.0.0")
+		outMsg, relationType, err := createTag(t, dir, types.Configuration{"tag": "${metadata.version}", "message": "release"}, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		ref, err := r.Tag("v1.0.0")
+		assert.Nil(t, err)
+		assert.Equal(t, ref.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+		tag, err := r.TagObject(ref.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, head.Hash(), tag.Target)
+		assert.Equal(t, "rulego", tag.Tagger.Name)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyAnnotated))
+		assert.Equal(t, "refs/tags/v1.0.0:refs/tags/v1.0.0", outMsg.Metadata.GetValue(KeyTagRefSpec))
+
+		var result TagResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, TagActionCreate, result.Action)
+		assert.Equal(t, "v1.0.0", result.Name)
+		assert.Equal(t, head.Hash().String(), result.Hash)
+		assert.Equal(t, ref.Hash().String(), result.RefHash)
+		assert.True(t, result.Annotated)
+		assert.Equal(t, "release", result.Message)
+		assert.Equal(t, "rulego", result.Tagger)
+		assert.Equal(t, "rulego@rulego.cc", result.TaggerEmail)
+		assert.False(t, result.Existed)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		_, _, err := createTag(t, dir, types.Configuration{"tag": "v1.0.0", "message": "release"}, types.NewMetadata())
+		assert.Nil(t, err)
+
+		config := types.Configuration{"tag": "v1.0.0", "action": "delete"}
+		outMsg, relationType, err := createTag(t, dir, config, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, ":refs/tags/v1.0.0", outMsg.Metadata.GetValue(KeyTagRefSpec))
+		var result TagResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, TagActionDelete, result.Action)
+		assert.Equal(t, head.Hash().String(), result.Hash)
+		assert.Equal(t, "release", result.Message)
+		assert.True(t, result.Existed)
+		_, err = r.Tag("v1.0.0")
+		assert.Equal(t, git.ErrTagNotFound, err)
+
+		_, relationType, err = createTag(t, dir, config, types.NewMetadata())
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, errors.Is(err, git.ErrTagNotFound))
+
+		config["ignoreMissing"] = true
+		outMsg, relationType, err = createTag(t, dir, config, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyTagExisted))
+		assert.Equal(t, ":refs/tags/v1.0.0", outMsg.Metadata.GetValue(KeyTagRefSpec))
+	})
+
+	t.Run("Lightweight", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		outMsg, relationType, err := createTag(t, dir, types.Configuration{"tag": "v1.0.0", "annotated": false}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		ref, err := r.Tag("v1.0.0")
+		assert.Nil(t, err)
+		assert.Equal(t, head.Hash(), ref.Hash())
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyAnnotated))
+		_, err = r.TagObject(ref.Hash())
+		assert.Equal(t, plumbing.ErrObjectNotFound, err)
+	})
+
+	t.Run("CommitHash", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		first := commitTestFile(t, r, "a.txt", "a", "first")
+		second := commitTestFile(t, r, "a.txt", "b", "second")
+		metadata := types.NewMetadata()
+		metadata.PutValue(KeyHash, first.String())
+		outMsg, relationType, err := createTag(t, dir, types.Configuration{"tag": "v1.0.0", "message": "release", "commitHash": "${metadata.hash}"}, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		tag, err := r.TagObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.Equal(t, first, tag.Target)
+		assert.NotEqual(t, second, tag.Target)
+
+		_, relationType, err = createTag(t, dir, types.Configuration{"tag": "v1.0.1", "commitHash": "0123456789abcdef0123456789abcdef01234567"}, types.NewMetadata())
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, strings.Contains(err.Error(), "0123456789abcdef0123456789abcdef01234567"))
+		_, err = r.Tag("v1.0.1")
+		assert.Equal(t, git.ErrTagNotFound, err)
+	})
+
+	t.Run("ExistingTag", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		first, _ := r.Head()
+		second := commitTestFile(t, r, "a.txt", "a", "second")
+
+		config := types.Configuration{"tag": "v1.0.0", "message": "release", "commitHash": first.Hash().String()}
+		outMsg, relationType, err := createTag(t, dir, config, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyTagExisted))
+		_, relationType, err = createTag(t, dir, config, types.NewMetadata())
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, errors.Is(err, git.ErrTagExists))
+
+		config["existingTag"] = ExistingTagSkip
+		config["commitHash"] = second.String()
+		for i := 0; i < 2; i++ {
+			outMsg, relationType, err = createTag(t, dir, config, types.NewMetadata())
+			assert.Nil(t, err)
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyTagExisted))
+			assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyAnnotated))
+			tag, err := r.TagObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+			assert.Nil(t, err)
+			assert.Equal(t, first.Hash(), tag.Target)
+		}
+
+		// 附注标签替换为轻量标签
+		config["existingTag"] = ExistingTagReplace
+		config["annotated"] = false
+		delete(config, "message")
+		for i := 0; i < 2; i++ {
+			outMsg, relationType, err = createTag(t, dir, config, types.NewMetadata())
+			assert.Nil(t, err)
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyTagExisted))
+			ref, err := r.Tag("v1.0.0")
+			assert.Nil(t, err)
+			assert.Equal(t, second, ref.Hash())
+		}
+		assert.Equal(t, second.String(), outMsg.Metadata.GetValue(KeyOldHash))
+
+		// 轻量标签替换为附注标签
+		config["annotated"] = true
+		config["message"] = "release"
+		config["commitHash"] = first.Hash().String()
+		outMsg, relationType, err = createTag(t, dir, config, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, second.String(), outMsg.Metadata.GetValue(KeyOldHash))
+		tag, err := r.TagObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.Equal(t, first.Hash(), tag.Target)
+
+		// 创建失败时保留原来的标签
+		oldRef, _ := r.Tag("v1.0.0")
+		config["message"] = "${metadata.message}"
+		config["commitHash"] = second.String()
+		metadata := types.NewMetadata()
+		metadata.PutValue("message", "")
+		_, relationType, err = createTag(t, dir, config, metadata)
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, errors.Is(err, git.ErrMissingMessage))
+		ref, err := r.Tag("v1.0.0")
+		assert.Nil(t, err)
+		assert.Equal(t, oldRef.Hash(), ref.Hash())
+	})
+
+	t.Run("SemverBump", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		metadata := types.NewMetadata()
+		metadata.PutValue("bump", "minor")
+		config := types.Configuration{"semverBump": "${metadata.bump}", "annotated": false}
+
+		// 没有匹配的标签时使用初始版本
+		outMsg, relationType, err := createTag(t, dir, config, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "", outMsg.Metadata.GetValue(KeyPreviousTag))
+		assert.Equal(t, "v0.1.0", outMsg.Metadata.GetValue(KeyNewTag))
+
+		for _, name := range []string{"v1.1.5", "v1.2.0-rc.1", "v1.2.0-beta.2", "release-9.0.0", "latest"} {
+			_, err = r.CreateTag(name, head.Hash(), nil)
+			assert.Nil(t, err)
+		}
+		for _, item := range []struct {
+			bump, previous, next string
+		}{
+			{"patch", "v1.2.0-rc.1", "v1.2.0"},
+			{"minor", "v1.2.0", "v1.3.0"},
+			{"patch", "v1.3.0", "v1.3.1"},
+			{"major", "v1.3.1", "v2.0.0"},
+		} {
+			metadata.PutValue("bump", item.bump)
+			outMsg, relationType, err = createTag(t, dir, config, metadata)
+			assert.Nil(t, err)
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, item.previous, outMsg.Metadata.GetValue(KeyPreviousTag))
+			assert.Equal(t, item.next, outMsg.Metadata.GetValue(KeyNewTag))
+			_, err = r.Tag(item.next)
+			assert.Nil(t, err)
+		}
+
+		outMsg, _, err = createTag(t, dir, types.Configuration{"semverBump": "patch", "tagPrefix": "release-", "annotated": false}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, "release-9.0.1", outMsg.Metadata.GetValue(KeyNewTag))
+
+		metadata.PutValue("bump", "none")
+		_, relationType, err = createTag(t, dir, config, metadata)
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("NoCommits", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := git.PlainInit(dir, false)
+		assert.Nil(t, err)
+		_, relationType, err := createTag(t, dir, types.Configuration{"tag": "v1.0.0", "message": "release"}, types.NewMetadata())
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, ErrNoCommits, err)
+	})
+
+	t.Run("Sign", func(t *testing.T) {
+		entity, keyring := newTestPGPEntity(t, "rulego", "rulego@rulego.cc")
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		outMsg, relationType, err := createTag(t, dir, types.Configuration{
+			"tag":            "v1.0.0",
+			"message":        "release",
+			"signKeyArmored": armorTestPrivateKey(t, entity, ""),
+		}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		tag, err := r.TagObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.True(t, tag.PGPSignature != "")
+		_, err = tag.Verify(keyring)
+		assert.Nil(t, err)
+	})
+}
+
+func TestBumpSemver(t *testing.T) {
+	for _, item := range []struct {
+		version, bump, expected string
+	}{
+		{"1.2.3", SemverBumpPatch, "1.2.4"},
+		{"1.2.3", SemverBumpMinor, "1.3.0"},
+		{"1.2.3", SemverBumpMajor, "2.0.0"},
+		{"1.2.0-rc.1", SemverBumpPatch, "1.2.0"},
+		{"1.2.0-rc.1", SemverBumpMinor, "1.2.0"},
+		{"1.2.0-rc.1", SemverBumpMajor, "2.0.0"},
+		{"1.2.3-rc.1", SemverBumpMinor, "1.3.0"},
+		{"2.0.0-alpha", SemverBumpMajor, "2.0.0"},
+	} {
+		v, ok := parseSemver(item.version)
+		assert.True(t, ok)
+		v = bumpSemver(v, item.bump)
+		assert.Equal(t, item.expected, fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch))
+	}
+}
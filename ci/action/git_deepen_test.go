@@ -0,0 +1,97 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestGitDeepenNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitDeepenNode{})
+	var targetNodeType = "ci/gitDeepen"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitDeepenNode{}, types.Configuration{
+			"unshallow": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"unshallow": false,
+			"depth":     0,
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		tmp := t.TempDir()
+		remoteDir := filepath.Join(tmp, "remote")
+		remote := initTestRepo(t, remoteDir)
+		for i := 1; i < 5; i++ {
+			commitTestFile(t, remote, "VERSION", strconv.Itoa(i), "commit "+strconv.Itoa(i))
+		}
+		workDir := filepath.Join(tmp, "work")
+		_, err := git.PlainClone(workDir, false, &git.CloneOptions{URL: remoteDir, Depth: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, countTestCommits(t, workDir))
+
+		deepen := func(config types.Configuration) types.RuleMsg {
+			config["directory"] = workDir
+			config["authType"] = ""
+			node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+			assert.Nil(t, err)
+			outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+			assert.Nil(t, err)
+			assert.Equal(t, types.Success, relationType)
+			return outMsg
+		}
+		outMsg := deepen(types.Configuration{"depth": 3, "unshallow": false})
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyShallow))
+		assert.Equal(t, 3, countTestCommits(t, workDir))
+
+		outMsg = deepen(types.Configuration{"unshallow": true})
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyShallow))
+		assert.Equal(t, 5, countTestCommits(t, workDir))
+
+		//已经是完整历史
+		outMsg = deepen(types.Configuration{"unshallow": true})
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyShallow))
+	})
+}
+
+// countTestCommits 统计HEAD可以访问到的本地提交数量
+func countTestCommits(t *testing.T, workDir string) int {
+	r, err := git.PlainOpen(workDir)
+	assert.Nil(t, err)
+	iter, err := r.Log(&git.LogOptions{})
+	assert.Nil(t, err)
+	count := 0
+	_ = iter.ForEach(func(commit *object.Commit) error {
+		count++
+		return nil
+	})
+	return count
+}
@@ -0,0 +1,332 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitDescribeNode{})
+}
+
+const (
+	// KeyDescribe 描述字符串，例如：v1.2.0-3-g1a2b3c4
+	KeyDescribe = "describe"
+	// KeyTag 最近的标签
+	KeyTag = "tag"
+	// KeyDistance 最近的标签之后的提交数
+	KeyDistance = "distance"
+	// KeyDirty 工作区是否有未提交的修改
+	KeyDirty = "dirty"
+)
+
+const (
+	// DescribeTagsAnnotated 只使用附注标签，与 git describe 一致
+	DescribeTagsAnnotated = "annotated"
+	// DescribeTagsAll 同时使用轻量标签，与 git describe --tags 一致
+	DescribeTagsAll = "all"
+)
+
+// ErrNoTagFound 没有找到可以描述提交的标签
+var ErrNoTagFound = errors.New("no tags can describe the commit")
+
+// GitDescribeNodeConfiguration 节点配置
+type GitDescribeNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 需要描述的引用，默认HEAD
+	Ref string
+	// 使用的标签，可以是 annotated 或 all，默认annotated
+	Tags string
+	// 没有标签时使用的标签，例如：0.0.0，此时距离为所有提交数，为空则发送到Failure链
+	FallbackTag string
+	// 短hash的长度，默认7
+	Abbrev int
+	// 工作区有未提交的修改时追加到描述字符串的后缀，只对HEAD有效，默认-dirty，为空则不追加
+	DirtySuffix string
+}
+
+// DescribeResult 描述结果
+type DescribeResult struct {
+	// 描述字符串
+	Describe string `json:"describe"`
+	// 最近的标签
+	Tag string `json:"tag"`
+	// 最近的标签之后的提交数
+	Distance int `json:"distance"`
+	// 提交hash
+	Hash string `json:"hash"`
+	// 提交短hash
+	ShortHash string `json:"shortHash"`
+	// 工作区是否有未提交的修改
+	Dirty bool `json:"dirty"`
+}
+
+// GitDescribeNode 查找距离提交最近的标签，生成与 git describe 一致的描述字符串：{tag}-{distance}-g{shortHash}，提交本身有标签时为标签名
+// 描述字符串和各组成部分写入元数据 describe、tag、distance、hash、shortHash 和 dirty，同时以JSON的形式写入 msg.Data
+type GitDescribeNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitDescribeNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitDescribeNode) Type() string {
+	return "ci/gitDescribe"
+}
+
+func (x *GitDescribeNode) New() types.Node {
+	return &GitDescribeNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitDescribeNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			Tags:           DescribeTagsAnnotated,
+			Abbrev:         7,
+			DirtySuffix:    "-dirty",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitDescribeNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Tags = strings.ToLower(strings.TrimSpace(x.Config.Tags))
+	switch x.Config.Tags {
+	case "":
+		x.Config.Tags = DescribeTagsAnnotated
+	case DescribeTagsAnnotated, DescribeTagsAll:
+	default:
+		return fmt.Errorf("unsupported tags: %s", x.Config.Tags)
+	}
+	if x.Config.Abbrev <= 0 || x.Config.Abbrev > 40 {
+		x.Config.Abbrev = 7
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitDescribeNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.Config.Ref
+	if evn != nil {
+		ref = str.ExecuteTemplate(ref, evn)
+	}
+	if ref = strings.TrimSpace(ref); ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result, err := x.describe(r, commit)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if ref == string(plumbing.HEAD) {
+		if w, err := r.Worktree(); err == nil {
+			if result.Dirty, err = isDirtyWorktree(w); err != nil {
+				ctx.TellFailure(msg, err)
+				return
+			}
+		}
+	}
+	if result.Dirty {
+		result.Describe += x.Config.DirtySuffix
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyDescribe), result.Describe)
+	msg.Metadata.PutValue(x.metaKey(KeyTag), result.Tag)
+	msg.Metadata.PutValue(x.metaKey(KeyDistance), strconv.Itoa(result.Distance))
+	msg.Metadata.PutValue(x.metaKey(KeyHash), result.Hash)
+	msg.Metadata.PutValue(x.metaKey(KeyShortHash), result.ShortHash)
+	msg.Metadata.PutValue(x.metaKey(KeyDirty), strconv.FormatBool(result.Dirty))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitDescribeNode) Destroy() {
+}
+
+// describe 找到从提交可达的最近的有标签的提交，距离为从提交可达但从标签不可达的提交数
+func (x *GitDescribeNode) describe(r *git.Repository, commit *object.Commit) (DescribeResult, error) {
+	hash := commit.Hash.String()
+	result := DescribeResult{Hash: hash, ShortHash: hash[:x.Config.Abbrev]}
+	tags, err := getCommitTags(r, x.Config.Tags == DescribeTagsAll)
+	if err != nil {
+		return result, err
+	}
+	tagCommit, tag, err := findNearestTag([]*object.Commit{commit}, tags)
+	if err != nil {
+		return result, err
+	}
+	result.Tag = tag
+	if tagCommit == nil {
+		if x.Config.FallbackTag == "" {
+			return result, fmt.Errorf("%w: %s", ErrNoTagFound, hash)
+		}
+		result.Tag = x.Config.FallbackTag
+	}
+	if result.Distance, err = countCommitsSince(commit, tagCommit); err != nil {
+		return result, err
+	}
+	if result.Distance == 0 && tagCommit != nil {
+		result.Describe = result.Tag
+	} else {
+		result.Describe = fmt.Sprintf("%s-%d-g%s", result.Tag, result.Distance, result.ShortHash)
+	}
+	return result, nil
+}
+
+// findNearestTag 从 start 开始按广度优先遍历历史，返回最近的有标签的提交和标签名，没有找到返回nil
+func findNearestTag(start []*object.Commit, tags map[plumbing.Hash][]string) (*object.Commit, string, error) {
+	queue := append([]*object.Commit(nil), start...)
+	visited := make(map[plumbing.Hash]bool, len(start))
+	for _, c := range start {
+		visited[c.Hash] = true
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if names, ok := tags[current.Hash]; ok {
+			return current, names[0], nil
+		}
+		err := current.Parents().ForEach(func(parent *object.Commit) error {
+			if !visited[parent.Hash] {
+				visited[parent.Hash] = true
+				queue = append(queue, parent)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return nil, "", nil
+}
+
+// getCommitTags 获取提交hash与标签名的映射，附注标签解析到其指向的提交，all 为false时忽略轻量标签
+// 同一个提交有多个标签时，附注标签优先，然后按名称倒序，使较新的版本号优先
+func getCommitTags(r *git.Repository, all bool) (map[plumbing.Hash][]string, error) {
+	type candidate struct {
+		name      string
+		annotated bool
+	}
+	candidates := make(map[plumbing.Hash][]candidate)
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		tag, err := r.TagObject(ref.Hash())
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			if all {
+				candidates[ref.Hash()] = append(candidates[ref.Hash()], candidate{name: name})
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		commit, err := tag.Commit()
+		if err != nil {
+			// 指向非提交对象的标签
+			return nil
+		}
+		candidates[commit.Hash] = append(candidates[commit.Hash], candidate{name: name, annotated: true})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[plumbing.Hash][]string, len(candidates))
+	for hash, items := range candidates {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].annotated != items[j].annotated {
+				return items[i].annotated
+			}
+			return items[i].name > items[j].name
+		})
+		for _, item := range items {
+			tags[hash] = append(tags[hash], item.name)
+		}
+	}
+	return tags, nil
+}
+
+// countCommitsSince 统计从 to 可达但从 from 不可达的提交数，from 为空则统计 to 可达的所有提交
+func countCommitsSince(to, from *object.Commit) (int, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if from != nil {
+		err := object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	var count int
+	// 已经访问过的提交及其父提交不会再次遍历
+	err := object.NewCommitPreorderIter(to, excluded, nil).ForEach(func(c *object.Commit) error {
+		count++
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitDescribeNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitDescribeNode{})
+	var targetNodeType = "ci/gitDescribe"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitDescribeNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"tags":           "annotated",
+			"abbrev":         7,
+			"dirtySuffix":    "-dirty",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"tags": "lightweight"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	describe := func(t *testing.T, dir string, config types.Configuration) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	first := commitTestFile(t, r, "a.txt", "a", "add a")
+	second := commitTestFile(t, r, "b.txt", "b", "add b")
+	commitTestFile(t, r, "c.txt", "c", "add c")
+	head := commitTestFile(t, r, "d.txt", "d", "add d")
+	_, err := r.CreateTag("v1.0.0", first, &git.CreateTagOptions{Tagger: &testSignature, Message: "release v1.0.0"})
+	assert.Nil(t, err)
+	_, err = r.CreateTag("v1.1.0-rc1", second, nil)
+	assert.Nil(t, err)
+
+	t.Run("Annotated", func(t *testing.T) {
+		outMsg, relationType, err := describe(t, dir, types.Configuration{})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "v1.0.0-3-g"+head.String()[:7], outMsg.Metadata.GetValue(KeyDescribe))
+		assert.Equal(t, "v1.0.0", outMsg.Metadata.GetValue(KeyTag))
+		assert.Equal(t, "3", outMsg.Metadata.GetValue(KeyDistance))
+		assert.Equal(t, head.String(), outMsg.Metadata.GetValue(KeyHash))
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyDirty))
+		var result DescribeResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, 3, result.Distance)
+		assert.Equal(t, "v1.0.0", result.Tag)
+	})
+
+	t.Run("All", func(t *testing.T) {
+		outMsg, _, err := describe(t, dir, types.Configuration{"tags": "all", "abbrev": 10})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.1.0-rc1-2-g"+head.String()[:10], outMsg.Metadata.GetValue(KeyDescribe))
+
+		outMsg, _, err = describe(t, dir, types.Configuration{"tags": "all", "ref": "HEAD~2"})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.1.0-rc1", outMsg.Metadata.GetValue(KeyDescribe))
+		assert.Equal(t, "0", outMsg.Metadata.GetValue(KeyDistance))
+	})
+
+	t.Run("Dirty", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("modified"), 0644))
+		defer os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+		outMsg, _, err := describe(t, dir, types.Configuration{})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.0.0-3-g"+head.String()[:7]+"-dirty", outMsg.Metadata.GetValue(KeyDescribe))
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyDirty))
+	})
+
+	t.Run("NoTags", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head := commitTestFile(t, r, "a.txt", "a", "add a")
+		_, relationType, err := describe(t, dir, types.Configuration{})
+		assert.True(t, errors.Is(err, ErrNoTagFound))
+		assert.Equal(t, types.Failure, relationType)
+
+		outMsg, _, err := describe(t, dir, types.Configuration{"fallbackTag": "0.0.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, "0.0.0-2-g"+head.String()[:7], outMsg.Metadata.GetValue(KeyDescribe))
+	})
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitDiffNode{})
+}
+
+// KeyChanged 是否有文件变更，配置了路径前缀时只判断这些路径
+const KeyChanged = "changed"
+
+const (
+	// ChangeTypeAdd 新增
+	ChangeTypeAdd = "add"
+	// ChangeTypeDelete 删除
+	ChangeTypeDelete = "delete"
+	// ChangeTypeModify 修改
+	ChangeTypeModify = "modify"
+	// ChangeTypeRename 重命名
+	ChangeTypeRename = "rename"
+)
+
+// GitDiffNodeConfiguration 节点配置
+type GitDiffNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 比较的起点，可以是提交hash、分支或者标签，例如：${metadata.lastDeployHash}
+	FromRef string
+	// 比较的终点，可以是提交hash、分支或者标签，默认HEAD
+	ToRef string
+	// 只统计这些路径前缀下的文件，多个与逗号隔开，例如：services/api/,libs/
+	PathPrefixes string
+	// 是否检测重命名，false则重命名作为删除和新增
+	DetectRenames bool
+}
+
+// FileChange 文件变更
+type FileChange struct {
+	// 文件路径，删除时为原路径
+	Path string `json:"path"`
+	// 变更类型，可以是 add、delete、modify 或 rename
+	ChangeType string `json:"changeType"`
+	// 新增行数
+	Additions int `json:"additions"`
+	// 删除行数
+	Deletions int `json:"deletions"`
+	// 重命名前的路径
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+// DiffStats 变更统计
+type DiffStats struct {
+	Files     int `json:"files"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// DiffResult 比较结果
+type DiffResult struct {
+	// 起点提交
+	FromHash string `json:"fromHash"`
+	// 终点提交
+	ToHash string `json:"toHash"`
+	// 变更的文件
+	Files []FileChange `json:"files"`
+	// 统计
+	Stats DiffStats `json:"stats"`
+}
+
+// GitDiffNode 比较两个引用，变更的文件列表和统计以JSON的形式写入 msg.Data，是否有变更写入元数据 changed
+type GitDiffNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitDiffNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitDiffNode) Type() string {
+	return "ci/gitDiff"
+}
+
+func (x *GitDiffNode) New() types.Node {
+	return &GitDiffNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitDiffNodeConfiguration{
+			ToRef:          string(plumbing.HEAD),
+			DetectRenames:  true,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitDiffNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.FromRef) || str.CheckHasVar(x.Config.ToRef) || str.CheckHasVar(x.Config.PathPrefixes) {
+		x.hasVar = true
+	}
+	if err == nil && x.Config.FromRef == "" {
+		err = errors.New("fromRef can not be empty")
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitDiffNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	toRef := x.getValue(x.Config.ToRef, evn)
+	if toRef == "" {
+		toRef = string(plumbing.HEAD)
+	}
+	from, err := resolveCommit(r, x.getValue(x.Config.FromRef, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	to, err := resolveCommit(r, toRef)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	var parent context.Context
+	if ctx != nil {
+		parent = ctx.GetContext()
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	files, err := x.diff(parent, from, to, splitPathPrefixes(x.getValue(x.Config.PathPrefixes, evn)))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result := DiffResult{FromHash: from.Hash.String(), ToHash: to.Hash.String(), Files: files}
+	for _, file := range files {
+		result.Stats.Files++
+		result.Stats.Additions += file.Additions
+		result.Stats.Deletions += file.Deletions
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyChanged), strconv.FormatBool(len(files) > 0))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitDiffNode) Destroy() {
+}
+
+// diff 比较两个提交的树，返回路径前缀下变更的文件
+func (x *GitDiffNode) diff(ctx context.Context, from, to *object.Commit, pathPrefixes []string) ([]FileChange, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	options := &object.DiffTreeOptions{}
+	if x.Config.DetectRenames {
+		options = object.DefaultDiffTreeOptions
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, fromTree, toTree, options)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileChange, 0, len(changes))
+	for _, change := range changes {
+		file := FileChange{Path: change.To.Name}
+		switch {
+		case change.From.Name == "":
+			file.ChangeType = ChangeTypeAdd
+		case change.To.Name == "":
+			file.ChangeType = ChangeTypeDelete
+			file.Path = change.From.Name
+		case change.From.Name != change.To.Name:
+			file.ChangeType = ChangeTypeRename
+			file.OldPath = change.From.Name
+		default:
+			file.ChangeType = ChangeTypeModify
+		}
+		if !matchPathPrefixes(pathPrefixes, file.Path, file.OldPath) {
+			continue
+		}
+		patch, err := change.PatchContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, stat := range patch.Stats() {
+			file.Additions += stat.Addition
+			file.Deletions += stat.Deletion
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (x *GitDiffNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// resolveCommit 解析引用或者提交hash对应的提交
+func resolveCommit(r *git.Repository, ref string) (*object.Commit, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("reference %s not found: %w", ref, err)
+	}
+	return r.CommitObject(*hash)
+}
+
+// splitPathPrefixes 拆分逗号分隔的路径前缀
+func splitPathPrefixes(value string) []string {
+	var pathPrefixes []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimPrefix(strings.TrimSpace(item), "./"); item != "" {
+			pathPrefixes = append(pathPrefixes, item)
+		}
+	}
+	return pathPrefixes
+}
+
+// matchPathPrefixes 判断任意路径是否匹配路径前缀，没有路径前缀则都匹配
+func matchPathPrefixes(pathPrefixes []string, paths ...string) bool {
+	if len(pathPrefixes) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		for _, prefix := range pathPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
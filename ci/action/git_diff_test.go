@@ -0,0 +1,139 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestGitDiffNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitDiffNode{})
+	var targetNodeType = "ci/gitDiff"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitDiffNode{}, types.Configuration{
+			"toRef":          "HEAD",
+			"detectRenames":  true,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	commitTestFile(t, r, "services/api/main.go", "package main\n", "add api")
+	commitTestFile(t, r, "libs/util.go", "package libs\n", "add libs")
+	from := commitTestFile(t, r, "docs/guide.md", "guide\n", "add docs")
+
+	commitTestFile(t, r, "services/api/main.go", "package main\n\nfunc main() {}\n", "modify api")
+	commitTestFile(t, r, "services/web/index.html", "<html></html>\n", "add web")
+	w, _ := r.Worktree()
+	_, err := w.Remove("libs/util.go")
+	assert.Nil(t, err)
+	_, err = w.Move("docs/guide.md", "docs/manual.md")
+	assert.Nil(t, err)
+	signature := testSignature
+	signature.When = time.Now()
+	_, err = w.Commit("remove libs and rename docs", &git.CommitOptions{Author: &signature})
+	assert.Nil(t, err)
+
+	diff := func(t *testing.T, config types.Configuration, metadata types.Metadata) (types.RuleMsg, string, error, DiffResult) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		var result DiffResult
+		if relationType == types.Success {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		}
+		return outMsg, relationType, err, result
+	}
+
+	t.Run("All", func(t *testing.T) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("lastDeployHash", from.String())
+		outMsg, relationType, err, result := diff(t, types.Configuration{
+			"fromRef": "${metadata.lastDeployHash}",
+		}, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyChanged))
+		assert.Equal(t, from.String(), result.FromHash)
+		assert.Equal(t, 4, result.Stats.Files)
+		assert.Equal(t, []FileChange{
+			{Path: "docs/manual.md", ChangeType: ChangeTypeRename, OldPath: "docs/guide.md"},
+			{Path: "libs/util.go", ChangeType: ChangeTypeDelete, Deletions: 1},
+			{Path: "services/api/main.go", ChangeType: ChangeTypeModify, Additions: 2},
+			{Path: "services/web/index.html", ChangeType: ChangeTypeAdd, Additions: 1},
+		}, result.Files)
+		assert.Equal(t, 3, result.Stats.Additions)
+		assert.Equal(t, 1, result.Stats.Deletions)
+	})
+
+	t.Run("NoRenames", func(t *testing.T) {
+		_, _, err, result := diff(t, types.Configuration{
+			"fromRef":       from.String(),
+			"pathPrefixes":  "docs/",
+			"detectRenames": false,
+		}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, []FileChange{
+			{Path: "docs/guide.md", ChangeType: ChangeTypeDelete, Deletions: 1},
+			{Path: "docs/manual.md", ChangeType: ChangeTypeAdd, Additions: 1},
+		}, result.Files)
+	})
+
+	t.Run("PathPrefixes", func(t *testing.T) {
+		outMsg, _, err, result := diff(t, types.Configuration{
+			"fromRef":      from.String(),
+			"toRef":        "HEAD~1",
+			"pathPrefixes": "services/api/, ./libs/",
+		}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyChanged))
+		assert.Equal(t, 1, len(result.Files))
+		assert.Equal(t, "services/api/main.go", result.Files[0].Path)
+
+		outMsg, _, err, result = diff(t, types.Configuration{
+			"fromRef":      from.String(),
+			"pathPrefixes": "services/worker/",
+		}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyChanged))
+		assert.Equal(t, 0, len(result.Files))
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		_, relationType, err, _ := diff(t, types.Configuration{
+			"fromRef": "v9.9.9",
+		}, types.NewMetadata())
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
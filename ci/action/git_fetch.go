@@ -0,0 +1,292 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strconv"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitFetchNode{})
+}
+
+const (
+	// FetchTagsAuto 只拉取指向已拉取提交的标签
+	FetchTagsAuto = "auto"
+	// FetchTagsAll 拉取所有标签
+	FetchTagsAll = "all"
+	// FetchTagsNone 不拉取标签
+	FetchTagsNone = "none"
+)
+
+// GitFetchNodeConfiguration 节点配置
+type GitFetchNodeConfiguration struct {
+	// Git 仓库 URL，为空则使用远程仓库配置的地址
+	Repository string
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 远程仓库名称，默认origin
+	RemoteName string
+	//RefSpecs 拉取的引用映射关系，例如：+refs/heads/*:refs/remotes/origin/*，多个映射关系与逗号隔开，为空则使用远程仓库配置的映射关系
+	RefSpecs string
+	// 是否删除远程已经不存在的本地引用
+	Prune bool
+	// 标签拉取方式，可以是 "auto"(只拉取指向已拉取提交的标签)、"all" 或 "none"，默认auto
+	Tags string
+	// 拉取的历史深度，0表示不限制
+	Depth int
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
+	ProxyUrl string
+	// 代理用户名
+	ProxyUsername string
+	// 代理密码
+	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// RefUpdate 被更新的引用，新增引用的OldHash为空，删除引用的NewHash为空
+type RefUpdate struct {
+	Name    string `json:"name"`
+	OldHash string `json:"oldHash"`
+	NewHash string `json:"newHash"`
+}
+
+// FetchResult 拉取结果
+type FetchResult struct {
+	// 是否已经是最新
+	UpToDate bool `json:"upToDate"`
+	// 被更新的引用
+	UpdatedRefs []RefUpdate `json:"updatedRefs"`
+}
+
+// GitFetchNode 拉取远程引用，不修改工作区，被更新的引用以JSON的形式写入 msg.Data
+type GitFetchNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitFetchNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitFetchNode) Type() string {
+	return "ci/gitFetch"
+}
+
+func (x *GitFetchNode) New() types.Node {
+	return &GitFetchNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitFetchNodeConfiguration{
+			RemoteName:     git.DefaultRemoteName,
+			Tags:           FetchTagsAuto,
+			AuthType:       "token",
+			AuthPassword:   "${vars.token}",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitFetchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.RefSpecs) || x.authHasVar() {
+		x.hasVar = true
+	}
+	if err == nil {
+		switch x.Config.Tags {
+		case "", FetchTagsAuto, FetchTagsAll, FetchTagsNone:
+		default:
+			err = errors.New("not tags=" + x.Config.Tags)
+		}
+	}
+	if err == nil && x.Config.Depth < 0 {
+		err = errors.New("depth can not be less than 0")
+	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitFetchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
+	repository := x.getRepository(msg, evn)
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	fetchOptions := &git.FetchOptions{
+		RemoteName:      x.Config.RemoteName,
+		RemoteURL:       repository,
+		Depth:           x.Config.Depth,
+		Prune:           x.Config.Prune,
+		Tags:            x.getTagMode(),
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if fetchOptions.RefSpecs, err = x.getRefSpecs(msg, evn); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	} else if auth != nil {
+		fetchOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		fetchOptions.ProxyOptions = proxy
+	}
+	before, err := x.snapshotRefs(r)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	// 下载的对象写入对象库，后续节点重新打开仓库
+	defer x.invalidateRepository(workDir)
+	if err = x.execute(ctx, msg, "fetch", repository, func(opCtx context.Context) error {
+		return r.FetchContext(opCtx, fetchOptions)
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	after, err := x.snapshotRefs(r)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	result := FetchResult{UpdatedRefs: diffRefs(before, after)}
+	result.UpToDate = len(result.UpdatedRefs) == 0
+	data, err := json.Marshal(result)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyUpToDate), strconv.FormatBool(result.UpToDate))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitFetchNode) Destroy() {
+	x.destroyBase()
+}
+
+func (x *GitFetchNode) getTagMode() git.TagMode {
+	switch x.Config.Tags {
+	case FetchTagsAll:
+		return git.AllTags
+	case FetchTagsNone:
+		return git.NoTags
+	default:
+		return git.TagFollowing
+	}
+}
+
+// snapshotRefs 获取仓库所有非符号引用及其指向的hash
+func (x *GitFetchNode) snapshotRefs(r *git.Repository) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(map[plumbing.ReferenceName]plumbing.Hash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			snapshot[ref.Name()] = ref.Hash()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// diffRefs 比较前后两次引用快照，返回按名称排序的变更引用
+func diffRefs(before, after map[plumbing.ReferenceName]plumbing.Hash) []RefUpdate {
+	updates := make([]RefUpdate, 0)
+	for name, newHash := range after {
+		if oldHash, ok := before[name]; !ok {
+			updates = append(updates, RefUpdate{Name: name.String(), NewHash: newHash.String()})
+		} else if oldHash != newHash {
+			updates = append(updates, RefUpdate{Name: name.String(), OldHash: oldHash.String(), NewHash: newHash.String()})
+		}
+	}
+	for name, oldHash := range before {
+		if _, ok := after[name]; !ok {
+			updates = append(updates, RefUpdate{Name: name.String(), OldHash: oldHash.String()})
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+	return updates
+}
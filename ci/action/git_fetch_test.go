@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitFetchNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitFetchNode{})
+	var targetNodeType = "ci/gitFetch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitFetchNode{}, types.Configuration{
+			"remoteName":     "origin",
+			"tags":           "auto",
+			"authType":       "token",
+			"authPassword":   "${vars.token}",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"tags": "unknown",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	localDir := filepath.Join(tmp, "local")
+	local, err := git.PlainClone(localDir, false, &git.CloneOptions{URL: remoteDir})
+	assert.Nil(t, err)
+	oldHead, _ := local.Head()
+
+	newNode := func(t *testing.T, configuration types.Configuration) types.Node {
+		configuration["directory"] = localDir
+		configuration["appendRepoName"] = false
+		configuration["authType"] = ""
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		return node
+	}
+	fetch := func(t *testing.T, node types.Node) FetchResult {
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result FetchResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+
+	mainHash := commitTestFile(t, remote, "a.txt", "a", "add a")
+	_, err = remote.CreateTag("v1.0.0", mainHash, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, remote.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature"), mainHash)))
+
+	node := newNode(t, types.Configuration{"tags": FetchTagsAll, "prune": true})
+	result := fetch(t, node)
+	assert.False(t, result.UpToDate)
+	assert.Equal(t, []RefUpdate{
+		{Name: "refs/remotes/origin/feature", NewHash: mainHash.String()},
+		{Name: "refs/remotes/origin/main", OldHash: oldHead.Hash().String(), NewHash: mainHash.String()},
+		{Name: "refs/tags/v1.0.0", NewHash: mainHash.String()},
+	}, result.UpdatedRefs)
+	//工作区不变
+	head, _ := local.Head()
+	assert.Equal(t, oldHead.Hash(), head.Hash())
+
+	//已经是最新
+	result = fetch(t, node)
+	assert.True(t, result.UpToDate)
+	assert.Equal(t, 0, len(result.UpdatedRefs))
+
+	//删除远程已经不存在的引用
+	assert.Nil(t, remote.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature")))
+	result = fetch(t, node)
+	assert.Equal(t, []RefUpdate{
+		{Name: "refs/remotes/origin/feature", OldHash: mainHash.String()},
+	}, result.UpdatedRefs)
+}
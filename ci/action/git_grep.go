@@ -0,0 +1,235 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitGrepNode{})
+}
+
+// KeyMatchCount 匹配的总行数
+const KeyMatchCount = "matchCount"
+
+// GitGrepNodeConfiguration 节点配置
+type GitGrepNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 搜索的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 正则表达式，支持 ${} 占位符
+	Pattern string
+	// 是否忽略大小写
+	IgnoreCase bool
+	// 只搜索匹配的文件，多个与逗号隔开，支持通配符，匹配相对仓库根目录的路径或者文件名，例如：*.go,conf/，为空则搜索所有文件
+	Paths string
+	// 最多输出的匹配行数，默认1000，0表示不限制，超过后继续统计总数
+	MaxMatches int
+	// 有匹配时发送到Failure链
+	FailIfFound bool
+	// 没有匹配时发送到Failure链
+	FailIfNotFound bool
+}
+
+// GrepMatch 匹配的行
+type GrepMatch struct {
+	// 文件路径
+	Path string `json:"path"`
+	// 行号，从1开始
+	LineNumber int `json:"lineNumber"`
+	// 行内容
+	Line string `json:"line"`
+}
+
+// GitGrepNode 在指定引用的提交中搜索文件内容，不受工作区未提交修改的影响，可以用于策略检查
+// 匹配的行以JSON数组的形式写入 msg.Data，匹配的总行数写入元数据 matchCount，二进制文件(包含0字节)会被跳过
+// 配置 FailIfFound 或 FailIfNotFound 后可以直接作为门禁节点使用
+type GitGrepNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitGrepNodeConfiguration
+	// 没有占位符时预编译的正则表达式
+	pattern *regexp.Regexp
+	hasVar  bool
+}
+
+// Type 组件类型
+func (x *GitGrepNode) Type() string {
+	return "ci/gitGrep"
+}
+
+func (x *GitGrepNode) New() types.Node {
+	return &GitGrepNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitGrepNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			MaxMatches:     1000,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitGrepNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.Pattern == "" {
+		return errors.New("pattern can not be empty")
+	}
+	if x.Config.FailIfFound && x.Config.FailIfNotFound {
+		return errors.New("failIfFound and failIfNotFound can not both be true")
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.Pattern) || str.CheckHasVar(x.Config.Paths) {
+		x.hasVar = true
+	}
+	if !str.CheckHasVar(x.Config.Pattern) {
+		x.pattern, err = x.compile(x.Config.Pattern)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitGrepNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	pattern := x.pattern
+	if pattern == nil {
+		if pattern, err = x.compile(str.ExecuteTemplate(x.Config.Pattern, evn)); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	matches, total, err := x.grep(commit, pattern, splitPathPrefixes(x.getValue(x.Config.Paths, evn)))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(matches)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	msg.Metadata.PutValue(x.metaKey(KeyMatchCount), strconv.Itoa(total))
+	if x.Config.FailIfFound && total > 0 {
+		ctx.TellFailure(msg, fmt.Errorf("pattern %s found %d matches at %s", pattern, total, ref))
+	} else if x.Config.FailIfNotFound && total == 0 {
+		ctx.TellFailure(msg, fmt.Errorf("pattern %s not found at %s", pattern, ref))
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// Destroy 销毁
+func (x *GitGrepNode) Destroy() {
+}
+
+// grep 搜索提交中的所有文件，返回输出的匹配行和匹配的总行数
+func (x *GitGrepNode) grep(commit *object.Commit, pattern *regexp.Regexp, paths []string) ([]GrepMatch, int, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, 0, err
+	}
+	matches := make([]GrepMatch, 0)
+	total := 0
+	err = tree.Files().ForEach(func(file *object.File) error {
+		if !file.Mode.IsFile() || !matchCleanPatterns(paths, file.Name) {
+			return nil
+		}
+		content, err := readBlob(&file.Blob)
+		if err != nil {
+			return err
+		}
+		if isBinaryContent(content) {
+			return nil
+		}
+		lineNumber := 0
+		for len(content) > 0 {
+			lineNumber++
+			line := content
+			if idx := bytes.IndexByte(content, '\n'); idx >= 0 {
+				line, content = content[:idx], content[idx+1:]
+			} else {
+				content = nil
+			}
+			line = bytes.TrimSuffix(line, []byte("\r"))
+			if !pattern.Match(line) {
+				continue
+			}
+			total++
+			if x.Config.MaxMatches <= 0 || len(matches) < x.Config.MaxMatches {
+				matches = append(matches, GrepMatch{Path: file.Name, LineNumber: lineNumber, Line: string(line)})
+			}
+		}
+		return nil
+	})
+	return matches, total, err
+}
+
+// compile 编译正则表达式
+func (x *GitGrepNode) compile(pattern string) (*regexp.Regexp, error) {
+	if x.Config.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func (x *GitGrepNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitGrepNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitGrepNode{})
+	var targetNodeType = "ci/gitGrep"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitGrepNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"maxMatches":     1000,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": "("}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"pattern": "a", "failIfFound": true, "failIfNotFound": true}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	commitTestFile(t, r, "conf/app.yaml", "name: app\nurl: http://internal.host/api\r\nretry: 3\n", "add config")
+	commitTestFile(t, r, "src/main.go", "package main\n\n// http://internal.host\nfunc main() {}", "add main")
+	commitTestFile(t, r, "bin/app", "\x00http://internal.host", "add binary")
+	clean := commitTestFile(t, r, "docs/guide.md", "see HTTPS://example.com\n", "add guide")
+	// 工作区的修改不影响搜索结果
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "docs", "guide.md"), []byte("http://internal.host\n"), 0644))
+
+	grep := func(t *testing.T, config types.Configuration, metadata types.Metadata) ([]GrepMatch, types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		var matches []GrepMatch
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &matches))
+		return matches, outMsg, relationType, err
+	}
+
+	t.Run("Grep", func(t *testing.T) {
+		matches, outMsg, relationType, err := grep(t, types.Configuration{"pattern": `http://internal\.host`}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "2", outMsg.Metadata.GetValue(KeyMatchCount))
+		assert.Equal(t, 2, len(matches))
+		assert.Equal(t, GrepMatch{Path: "conf/app.yaml", LineNumber: 2, Line: "url: http://internal.host/api"}, matches[0])
+		assert.Equal(t, GrepMatch{Path: "src/main.go", LineNumber: 3, Line: "// http://internal.host"}, matches[1])
+	})
+
+	t.Run("PathsAndIgnoreCase", func(t *testing.T) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("scheme", "https")
+		matches, _, _, err := grep(t, types.Configuration{"pattern": "${metadata.scheme}://", "ignoreCase": true, "paths": "docs/"}, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(matches))
+		assert.Equal(t, "docs/guide.md", matches[0].Path)
+
+		matches, _, _, err = grep(t, types.Configuration{"pattern": "internal", "paths": "*.go"}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(matches))
+	})
+
+	t.Run("MaxMatches", func(t *testing.T) {
+		matches, outMsg, _, err := grep(t, types.Configuration{"pattern": ".", "maxMatches": 2}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(matches))
+		assert.Equal(t, "8", outMsg.Metadata.GetValue(KeyMatchCount))
+	})
+
+	t.Run("Gate", func(t *testing.T) {
+		_, _, relationType, err := grep(t, types.Configuration{"pattern": "internal", "failIfFound": true}, types.NewMetadata())
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		_, _, relationType, err = grep(t, types.Configuration{"pattern": "internal", "failIfNotFound": true, "ref": "HEAD~4"}, types.NewMetadata())
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		_, _, relationType, err = grep(t, types.Configuration{"pattern": "internal", "failIfFound": true, "paths": "docs/", "ref": clean.String()}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+	})
+}
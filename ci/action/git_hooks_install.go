@@ -0,0 +1,348 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitHooksInstallNode{})
+}
+
+const (
+	// HookStatusInstalled 新安装
+	HookStatusInstalled = "installed"
+	// HookStatusReplaced 替换了已有的钩子
+	HookStatusReplaced = "replaced"
+	// HookStatusUnchanged 已有的钩子内容相同
+	HookStatusUnchanged = "unchanged"
+	// HookStatusSkipped 已有不同的钩子并且不允许覆盖
+	HookStatusSkipped = "skipped"
+)
+
+// gitHookNames git支持的客户端和服务端钩子
+var gitHookNames = map[string]bool{
+	"applypatch-msg": true, "pre-applypatch": true, "post-applypatch": true,
+	"pre-commit": true, "pre-merge-commit": true, "prepare-commit-msg": true, "commit-msg": true, "post-commit": true,
+	"pre-rebase": true, "post-checkout": true, "post-merge": true, "pre-push": true,
+	"pre-receive": true, "update": true, "proc-receive": true, "post-receive": true, "post-update": true,
+	"reference-transaction": true, "push-to-checkout": true, "pre-auto-gc": true, "post-rewrite": true,
+	"sendemail-validate": true, "fsmonitor-watchman": true, "post-index-change": true,
+}
+
+// GitHooksInstallNodeConfiguration 节点配置
+type GitHooksInstallNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 钩子脚本目录，目录中文件名为钩子名称(例如：pre-commit)的文件会被安装，其他文件忽略
+	SourceDirectory string
+	// 钩子名称与脚本内容的映射，脚本内容支持 ${} 占位符，与 SourceDirectory 中的同名钩子冲突时优先使用
+	Hooks map[string]string
+	// 是否覆盖已有的不同内容的钩子
+	Overwrite bool
+	// 是否只列出需要安装的钩子，不实际写入
+	DryRun bool
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// HookInfo 钩子安装结果
+type HookInfo struct {
+	// 钩子名称
+	Name string `json:"name"`
+	// 安装结果，可以是 installed、replaced、unchanged 或 skipped
+	Status string `json:"status"`
+}
+
+// HooksResult 安装结果
+type HooksResult struct {
+	// 钩子目录
+	HooksDir string `json:"hooksDir"`
+	// 按名称排列的钩子
+	Hooks []HookInfo `json:"hooks"`
+	// 是否替换了已有的钩子
+	Replaced bool `json:"replaced"`
+	// 是否只列出需要安装的钩子
+	DryRun bool `json:"dryRun"`
+}
+
+// GitHooksInstallNode 把钩子脚本安装到仓库的钩子目录并设置可执行权限，用于在克隆后统一配置 pre-commit、commit-msg 等钩子
+// 支持 .git 为文件的链接工作树和子模块，以及配置了 core.hooksPath 的仓库，安装结果以JSON的形式写入 msg.Data
+type GitHooksInstallNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitHooksInstallNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitHooksInstallNode) Type() string {
+	return "ci/gitHooksInstall"
+}
+
+func (x *GitHooksInstallNode) New() types.Node {
+	return &GitHooksInstallNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitHooksInstallNodeConfiguration{
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitHooksInstallNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.SourceDirectory) == "" && len(x.Config.Hooks) == 0 {
+		return errors.New("sourceDirectory and hooks can not both be empty")
+	}
+	for name, content := range x.Config.Hooks {
+		if !gitHookNames[name] {
+			return fmt.Errorf("unsupported hook: %s", name)
+		}
+		if str.CheckHasVar(content) {
+			x.hasVar = true
+		}
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.SourceDirectory) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitHooksInstallNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	if msg.Metadata.GetValue(x.metaKey(KeyRepoId)) != "" {
+		ctx.TellFailure(msg, errors.New("hooks are not supported for in-memory repository"))
+		return
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, "", workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	hooks, err := x.loadHooks(evn)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	hooksDir, err := findHooksDir(workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result, err := x.install(hooksDir, hooks)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitHooksInstallNode) Destroy() {
+}
+
+// loadHooks 读取钩子目录和内联的钩子脚本
+func (x *GitHooksInstallNode) loadHooks(evn map[string]interface{}) (map[string][]byte, error) {
+	hooks := make(map[string][]byte)
+	sourceDir := strings.TrimSpace(x.Config.SourceDirectory)
+	if evn != nil {
+		sourceDir = strings.TrimSpace(str.ExecuteTemplate(sourceDir, evn))
+	}
+	if sourceDir != "" {
+		entries, err := os.ReadDir(sourceDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !gitHookNames[entry.Name()] {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(sourceDir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			hooks[entry.Name()] = content
+		}
+	}
+	for name, content := range x.Config.Hooks {
+		if evn != nil {
+			content = str.ExecuteTemplate(content, evn)
+		}
+		hooks[name] = []byte(content)
+	}
+	return hooks, nil
+}
+
+// install 安装钩子，先写入临时文件再重命名，避免git执行到不完整的脚本
+func (x *GitHooksInstallNode) install(hooksDir string, hooks map[string][]byte) (HooksResult, error) {
+	result := HooksResult{HooksDir: hooksDir, Hooks: make([]HookInfo, 0, len(hooks)), DryRun: x.Config.DryRun}
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !x.Config.DryRun && len(names) > 0 {
+		if err := os.MkdirAll(hooksDir, os.ModePerm); err != nil {
+			return result, err
+		}
+	}
+	for _, name := range names {
+		content := hooks[name]
+		target := filepath.Join(hooksDir, name)
+		info := HookInfo{Name: name, Status: HookStatusInstalled}
+		if existing, err := os.ReadFile(target); err == nil {
+			if bytes.Equal(existing, content) {
+				info.Status = HookStatusUnchanged
+			} else if x.Config.Overwrite {
+				info.Status = HookStatusReplaced
+				result.Replaced = true
+			} else {
+				info.Status = HookStatusSkipped
+			}
+		} else if !os.IsNotExist(err) {
+			return result, err
+		}
+		result.Hooks = append(result.Hooks, info)
+		if x.Config.DryRun || info.Status == HookStatusSkipped {
+			continue
+		}
+		if info.Status == HookStatusUnchanged {
+			// 内容相同时只确保可执行
+			if err := os.Chmod(target, 0755); err != nil {
+				return result, err
+			}
+			continue
+		}
+		if err := writeExecutable(target, content); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// writeExecutable 写入临时文件并设置可执行权限后重命名为目标文件
+func writeExecutable(target string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0755)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), target)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// findHooksDir 查找仓库的钩子目录
+// .git 为文件(链接工作树、子模块)时读取其中的 gitdir，链接工作树的钩子位于 commondir 指向的主仓库目录
+// 配置了 core.hooksPath 时使用该目录，相对路径相对于工作目录
+func findHooksDir(workDir string) (string, error) {
+	gitDir := filepath.Join(workDir, ".git")
+	info, err := os.Stat(gitDir)
+	switch {
+	case err == nil && !info.IsDir():
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", err
+		}
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return "", fmt.Errorf("invalid .git file: %s", gitDir)
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(workDir, gitDir)
+		}
+	case os.IsNotExist(err):
+		// 裸仓库
+		if _, err := os.Stat(filepath.Join(workDir, "HEAD")); err != nil {
+			return "", fmt.Errorf("%s is not a git repository", workDir)
+		}
+		gitDir = workDir
+	case err != nil:
+		return "", err
+	}
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(commonDir, "config")); err == nil {
+		cfg := config.NewConfig()
+		if err = cfg.Unmarshal(data); err != nil {
+			return "", err
+		}
+		if hooksPath := cfg.Raw.Section("core").Option("hooksPath"); hooksPath != "" {
+			if strings.HasPrefix(hooksPath, "~/") {
+				if home, err := os.UserHomeDir(); err == nil {
+					hooksPath = filepath.Join(home, hooksPath[2:])
+				}
+			}
+			if !filepath.IsAbs(hooksPath) {
+				hooksPath = filepath.Join(workDir, hooksPath)
+			}
+			return filepath.Clean(hooksPath), nil
+		}
+	}
+	return filepath.Clean(filepath.Join(commonDir, "hooks")), nil
+}
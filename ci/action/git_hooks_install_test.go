@@ -0,0 +1,160 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestGitHooksInstallNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitHooksInstallNode{})
+	var targetNodeType = "ci/gitHooksInstall"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitHooksInstallNode{}, types.Configuration{
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"hooks": map[string]interface{}{"pre-build": "exit 0"}}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	install := func(t *testing.T, dir string, config types.Configuration, metadata types.Metadata) HooksResult {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result HooksResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+	readFile := func(name string) string {
+		data, _ := os.ReadFile(name)
+		return string(data)
+	}
+
+	t.Run("Install", func(t *testing.T) {
+		dir := t.TempDir()
+		initTestRepo(t, dir)
+		sourceDir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(sourceDir, "pre-commit"), []byte("#!/bin/sh\nmake lint\n"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(sourceDir, "README.md"), []byte("hooks"), 0644))
+		hooksDir := filepath.Join(dir, ".git", "hooks")
+
+		metadata := types.NewMetadata()
+		metadata.PutValue("ticket", "CI")
+		config := types.Configuration{
+			"sourceDirectory": sourceDir,
+			"hooks":           map[string]interface{}{"commit-msg": "#!/bin/sh\ngrep -q ${ticket} \"$1\"\n"},
+			"dryRun":          true,
+		}
+		result := install(t, dir, config, metadata)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, filepath.Clean(hooksDir), result.HooksDir)
+		assert.Equal(t, 2, len(result.Hooks))
+		assert.Equal(t, "commit-msg", result.Hooks[0].Name)
+		assert.Equal(t, HookStatusInstalled, result.Hooks[0].Status)
+		assert.Equal(t, "pre-commit", result.Hooks[1].Name)
+		_, err := os.Stat(filepath.Join(hooksDir, "pre-commit"))
+		assert.True(t, os.IsNotExist(err))
+
+		config["dryRun"] = false
+		result = install(t, dir, config, metadata)
+		assert.False(t, result.Replaced)
+		assert.Equal(t, "#!/bin/sh\ngrep -q CI \"$1\"\n", readFile(filepath.Join(hooksDir, "commit-msg")))
+		assert.Equal(t, "#!/bin/sh\nmake lint\n", readFile(filepath.Join(hooksDir, "pre-commit")))
+		_, err = os.Stat(filepath.Join(hooksDir, "README.md"))
+		assert.True(t, os.IsNotExist(err))
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(filepath.Join(hooksDir, "pre-commit"))
+			assert.Nil(t, err)
+			assert.True(t, info.Mode().Perm()&0100 != 0)
+		}
+
+		result = install(t, dir, config, metadata)
+		assert.Equal(t, HookStatusUnchanged, result.Hooks[0].Status)
+		assert.Equal(t, HookStatusUnchanged, result.Hooks[1].Status)
+
+		// 已有不同内容的钩子，不允许覆盖时跳过
+		assert.Nil(t, os.WriteFile(filepath.Join(hooksDir, "pre-commit"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+		result = install(t, dir, config, metadata)
+		assert.Equal(t, HookStatusSkipped, result.Hooks[1].Status)
+		assert.False(t, result.Replaced)
+		assert.Equal(t, "#!/bin/sh\nexit 1\n", readFile(filepath.Join(hooksDir, "pre-commit")))
+
+		config["overwrite"] = true
+		result = install(t, dir, config, metadata)
+		assert.Equal(t, HookStatusReplaced, result.Hooks[1].Status)
+		assert.True(t, result.Replaced)
+		assert.Equal(t, "#!/bin/sh\nmake lint\n", readFile(filepath.Join(hooksDir, "pre-commit")))
+	})
+
+	t.Run("LinkedWorktree", func(t *testing.T) {
+		mainDir := t.TempDir()
+		initTestRepo(t, mainDir)
+		// 链接工作树的 .git 是指向主仓库 worktrees 目录的文件
+		gitDir := filepath.Join(mainDir, ".git", "worktrees", "wt")
+		assert.Nil(t, os.MkdirAll(gitDir, os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(gitDir, "commondir"), []byte("../..\n"), 0644))
+		wtDir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(wtDir, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644))
+
+		result := install(t, wtDir, types.Configuration{"hooks": map[string]interface{}{"pre-push": "#!/bin/sh\n"}}, types.NewMetadata())
+		assert.Equal(t, filepath.Join(mainDir, ".git", "hooks"), result.HooksDir)
+		assert.Equal(t, "#!/bin/sh\n", readFile(filepath.Join(mainDir, ".git", "hooks", "pre-push")))
+	})
+
+	t.Run("HooksPath", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		cfg, err := r.Config()
+		assert.Nil(t, err)
+		cfg.Raw.Section("core").SetOption("hooksPath", ".githooks")
+		assert.Nil(t, r.SetConfig(cfg))
+
+		result := install(t, dir, types.Configuration{"hooks": map[string]interface{}{"pre-commit": "#!/bin/sh\n"}}, types.NewMetadata())
+		assert.Equal(t, filepath.Join(dir, ".githooks"), result.HooksDir)
+		assert.Equal(t, "#!/bin/sh\n", readFile(filepath.Join(dir, ".githooks", "pre-commit")))
+	})
+
+	t.Run("NotRepository", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      t.TempDir(),
+			"appendRepoName": false,
+			"hooks":          map[string]interface{}{"pre-commit": "#!/bin/sh\n"},
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	httptransport "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tlsVersions TlsMinVersion 支持的值
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var installHttpRouterOnce sync.Once
+
+// installHttpRouter 把 http/https 协议替换为按认证信息选择客户端的路由，只安装一次
+// 使用节点 HTTP 设置的操作由节点自己的客户端处理，其他操作仍然使用原来的客户端，不影响其他使用 go-git 的组件
+func installHttpRouter() {
+	installHttpRouterOnce.Do(func() {
+		for _, scheme := range []string{"http", "https"} {
+			fallback, ok := client.Protocols[scheme]
+			if !ok {
+				fallback = httptransport.DefaultClient
+			}
+			client.InstallProtocol(scheme, &httpRouter{fallback: fallback})
+		}
+	})
+}
+
+// newHttpClient 根据 HttpTimeoutSeconds 和 TlsMinVersion 创建节点的 go-git HTTP 客户端，没有配置时返回nil
+// CA证书、跳过证书校验以及代理仍然通过 go-git 的操作参数设置
+func newHttpClient(config baseGitNodeConfiguration) (transport.Transport, error) {
+	if config.HttpTimeoutSeconds <= 0 && config.TlsMinVersion == "" && len(config.ExtraHeaders) == 0 {
+		return nil, nil
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TlsMinVersion != "" {
+		version, ok := tlsVersions[config.TlsMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("not tlsMinVersion=%s", config.TlsMinVersion)
+		}
+		tr.TLSClientConfig = &tls.Config{MinVersion: version}
+	}
+	installHttpRouter()
+	return httptransport.NewClient(&http.Client{
+		Transport: tr,
+		Timeout:   time.Duration(config.HttpTimeoutSeconds) * time.Second,
+	}), nil
+}
+
+// httpRouter 根据认证信息选择 go-git HTTP 客户端
+type httpRouter struct {
+	fallback transport.Transport
+}
+
+func (r *httpRouter) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	if a, ok := auth.(*httpSettingsAuth); ok {
+		return a.client.NewUploadPackSession(ep, auth)
+	}
+	return r.fallback.NewUploadPackSession(ep, auth)
+}
+
+func (r *httpRouter) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	if a, ok := auth.(*httpSettingsAuth); ok {
+		return a.client.NewReceivePackSession(ep, auth)
+	}
+	return r.fallback.NewReceivePackSession(ep, auth)
+}
+
+// httpSettingsAuth 携带节点 HTTP 客户端的认证信息，每个请求都加上额外的请求头
+type httpSettingsAuth struct {
+	// 用户名密码或者 token 认证，匿名访问时为nil
+	auth    httptransport.AuthMethod
+	headers map[string]string
+	client  transport.Transport
+}
+
+func (a *httpSettingsAuth) SetAuth(r *http.Request) {
+	if a.auth != nil {
+		a.auth.SetAuth(r)
+	}
+	for k, v := range a.headers {
+		r.Header.Set(k, v)
+	}
+}
+
+func (a *httpSettingsAuth) Name() string {
+	if a.auth != nil {
+		return a.auth.Name()
+	}
+	return "http-settings"
+}
+
+// String 不包含请求头的值
+func (a *httpSettingsAuth) String() string {
+	if a.auth != nil {
+		return a.auth.String()
+	}
+	return a.Name()
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"crypto/tls"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitHttpServer 通过 git http-backend 提供 dir 下的仓库，请求头 PRIVATE-TOKEN 不是 token 时返回 401
+func gitHttpServer(t *testing.T, dir, token string) *httptest.Server {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git not found")
+	}
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+}
+
+func TestHttpExtraHeaders(t *testing.T) {
+	tmp := t.TempDir()
+	remote := initTestRepo(t, filepath.Join(tmp, "repo"))
+	head, _ := remote.Head()
+	server := gitHttpServer(t, tmp, "tenant-a-token")
+	defer server.Close()
+
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitLsRemoteNode{})
+	newNode := func(headers map[string]string) types.Node {
+		node, err := test.CreateAndInitNode("ci/gitLsRemote", types.Configuration{
+			"repository":         server.URL + "/repo",
+			"authType":           "",
+			"extraHeaders":       headers,
+			"httpTimeoutSeconds": 10,
+		}, Registry)
+		assert.Nil(t, err)
+		return node
+	}
+	metadata := types.NewMetadata()
+	metadata.PutValue("token", "tenant-a-token")
+
+	msg, relationType, err := onMsgSync(newNode(map[string]string{"PRIVATE-TOKEN": "${metadata.token}"}), types.NewMsg(0, "test", types.JSON, metadata, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.True(t, strings.Contains(msg.Data, head.Hash().String()))
+
+	Registry.Add(&GitCloneNode{})
+	cloneNode, err := test.CreateAndInitNode("ci/gitClone", types.Configuration{
+		"repository":   server.URL + "/repo",
+		"directory":    filepath.Join(tmp, "work"),
+		"authType":     "",
+		"extraHeaders": map[string]string{"PRIVATE-TOKEN": "${metadata.token}"},
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err = onMsgSync(cloneNode, types.NewMsg(0, "test", types.JSON, metadata, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, head.Hash().String(), msg.Metadata.GetValue(KeyCommitHash))
+
+	// 请求头的值错误时认证失败，错误信息不包含请求头的值
+	metadata.PutValue("token", "wrong-token")
+	_, relationType, err = onMsgSync(newNode(map[string]string{"PRIVATE-TOKEN": "${metadata.token}"}), types.NewMsg(0, "test", types.JSON, metadata, ""))
+	assert.Equal(t, types.Failure, relationType)
+	assert.NotNil(t, err)
+	assert.False(t, strings.Contains(err.Error(), "wrong-token"))
+
+	// 没有配置请求头时其他节点不受影响
+	_, relationType, _ = onMsgSync(newNode(nil), types.NewMsg(0, "test", types.JSON, metadata, ""))
+	assert.Equal(t, types.Failure, relationType)
+}
+
+func TestHttpTlsMinVersion(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitLsRemoteNode{})
+	_, err := test.CreateAndInitNode("ci/gitLsRemote", types.Configuration{
+		"repository":    "https://github.com/rulego/rulego.git",
+		"tlsMinVersion": "1.4",
+	}, Registry)
+	assert.NotNil(t, err)
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	node, err := test.CreateAndInitNode("ci/gitLsRemote", types.Configuration{
+		"repository":         server.URL + "/repo",
+		"authType":           "",
+		"insecureSkipVerify": true,
+		"tlsMinVersion":      "1.3",
+		"httpTimeoutSeconds": 5,
+	}, Registry)
+	assert.Nil(t, err)
+	start := time.Now()
+	_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, strings.Contains(err.Error(), "protocol version"))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// workDirLock 工作目录锁，refs为持有或者等待该锁的数量，为0时从锁表中删除
+type workDirLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+var workDirLocks = struct {
+	sync.Mutex
+	items map[string]*workDirLock
+}{items: make(map[string]*workDirLock)}
+
+// acquireWorkDirLock 获取工作目录锁，同一个工作目录同时只允许一个操作修改仓库
+// timeout<=0 表示一直等待，等待超时或者ctx取消返回错误，成功返回解锁函数
+func acquireWorkDirLock(ctx context.Context, key string, timeout time.Duration) (func(), error) {
+	workDirLocks.Lock()
+	lock, ok := workDirLocks.items[key]
+	if !ok {
+		lock = &workDirLock{ch: make(chan struct{}, 1)}
+		workDirLocks.items[key] = lock
+	}
+	lock.refs++
+	workDirLocks.Unlock()
+
+	release := func() {
+		workDirLocks.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(workDirLocks.items, key)
+		}
+		workDirLocks.Unlock()
+	}
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case lock.ch <- struct{}{}:
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				<-lock.ch
+				release()
+			})
+		}, nil
+	case <-timeoutCh:
+		release()
+		return nil, fmt.Errorf("wait for work directory %s lock timed out after %s", key, timeout)
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// workDirLockKey 获取工作目录锁的key，内存仓库使用仓库ID，本地目录使用绝对路径
+func workDirLockKey(repoId, workDir string) string {
+	if repoId != "" {
+		return KeyRepoId + ":" + repoId
+	}
+	if abs, err := filepath.Abs(workDir); err == nil {
+		return abs
+	}
+	return filepath.Clean(workDir)
+}
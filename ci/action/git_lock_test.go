@@ -0,0 +1,69 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"github.com/rulego/rulego/test/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireWorkDirLock(t *testing.T) {
+	key := workDirLockKey("", filepath.Join(t.TempDir(), "rulego"))
+	unlock, err := acquireWorkDirLock(context.Background(), key, 0)
+	assert.Nil(t, err)
+
+	//已被锁定，等待超时
+	_, err = acquireWorkDirLock(context.Background(), key, time.Millisecond*50)
+	assert.NotNil(t, err)
+
+	//取消等待
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = acquireWorkDirLock(cancelCtx, key, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	//其他目录不受影响
+	otherUnlock, err := acquireWorkDirLock(context.Background(), workDirLockKey("", filepath.Join(t.TempDir(), "other")), time.Millisecond*50)
+	assert.Nil(t, err)
+	otherUnlock()
+
+	//释放后等待者获得锁
+	done := make(chan error)
+	go func() {
+		waitUnlock, err := acquireWorkDirLock(context.Background(), key, time.Second)
+		if err == nil {
+			waitUnlock()
+		}
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+	unlock()
+	//重复解锁无影响
+	unlock()
+	assert.Nil(t, <-done)
+
+	//全部释放后清理
+	workDirLocks.Lock()
+	_, ok := workDirLocks.items[key]
+	workDirLocks.Unlock()
+	assert.False(t, ok)
+
+	assert.Equal(t, KeyRepoId+":abc", workDirLockKey("abc", "/data/ci"))
+}
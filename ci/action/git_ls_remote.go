@@ -0,0 +1,263 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"path"
+	"sort"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitLsRemoteNode{})
+}
+
+// KeyRemoteHash 远程引用的hash
+const KeyRemoteHash = "remoteHash"
+
+// GitLsRemoteNodeConfiguration 节点配置
+type GitLsRemoteNodeConfiguration struct {
+	// Git 仓库 URL，为空则使用元数据 gitHttpUrl 或者 gitSshUrl
+	Repository string
+	// 是否只列出分支，与 Tags 同时为false时列出所有引用
+	Heads bool
+	// 是否只列出标签，与 Heads 同时为true时列出分支和标签
+	Tags bool
+	// 引用名称的通配符，匹配完整名称或者短名称，例如：refs/heads/release-*、v1.*
+	Pattern string
+	// 需要查询的引用，可以是完整名称或者分支名、标签名，配置后把该引用的hash写入元数据 remoteHash，不存在则发送到Failure链
+	Reference string
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
+	ProxyUrl string
+	// 代理用户名
+	ProxyUsername string
+	// 代理密码
+	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+}
+
+// RemoteRef 远程引用
+type RemoteRef struct {
+	// 引用名称
+	Name string `json:"name"`
+	// 引用指向的hash，符号引用为目标引用的hash
+	Hash string `json:"hash"`
+	// 是否符号引用，例如：HEAD
+	IsSymbolic bool `json:"isSymbolic"`
+	// 符号引用的目标引用
+	Target string `json:"target,omitempty"`
+}
+
+// GitLsRemoteNode 不克隆仓库，列出远程仓库的引用，以JSON数组的形式写入 msg.Data
+// 可以用于克隆前检查分支是否存在，或者比较远程分支的hash判断远程仓库是否有变化
+type GitLsRemoteNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitLsRemoteNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitLsRemoteNode) Type() string {
+	return "ci/gitLsRemote"
+}
+
+func (x *GitLsRemoteNode) New() types.Node {
+	return &GitLsRemoteNode{}
+}
+
+// Init 初始化
+func (x *GitLsRemoteNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Pattern) || str.CheckHasVar(x.Config.Reference) || x.authHasVar() {
+		x.hasVar = true
+	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitLsRemoteNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	repository := x.getRepository(msg, evn)
+	if repository == "" {
+		x.tellFailure(ctx, msg, errors.New("repository can not be empty"))
+		return
+	}
+	listOptions := &git.ListOptions{
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	} else if auth != nil {
+		listOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		listOptions.ProxyOptions = proxy
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{repository},
+	})
+	var refs []*plumbing.Reference
+	err := x.execute(ctx, msg, "ls-remote", repository, func(opCtx context.Context) error {
+		var err error
+		refs, err = remote.ListContext(opCtx, listOptions)
+		return err
+	})
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash, len(refs))
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+	for _, ref := range refs {
+		if ref.Type() == plumbing.SymbolicReference {
+			if hash, ok := hashes[ref.Target()]; ok {
+				hashes[ref.Name()] = hash
+			}
+		}
+	}
+	if reference := x.getValue(x.Config.Reference, evn); reference != "" {
+		hash, ok := findRemoteHash(hashes, reference)
+		if !ok {
+			x.tellFailure(ctx, msg, fmt.Errorf("%w: %s", plumbing.ErrReferenceNotFound, reference))
+			return
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyRemoteHash), hash.String())
+	}
+	pattern := x.getValue(x.Config.Pattern, evn)
+	remoteRefs := make([]RemoteRef, 0, len(refs))
+	for _, ref := range refs {
+		if !x.matchRef(ref.Name(), pattern) {
+			continue
+		}
+		remoteRef := RemoteRef{Name: ref.Name().String()}
+		if ref.Type() == plumbing.SymbolicReference {
+			remoteRef.IsSymbolic = true
+			remoteRef.Target = ref.Target().String()
+			if hash, ok := hashes[ref.Name()]; ok {
+				remoteRef.Hash = hash.String()
+			}
+		} else {
+			remoteRef.Hash = ref.Hash().String()
+		}
+		remoteRefs = append(remoteRefs, remoteRef)
+	}
+	sort.Slice(remoteRefs, func(i, j int) bool {
+		return remoteRefs[i].Name < remoteRefs[j].Name
+	})
+	data, err := json.Marshal(remoteRefs)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitLsRemoteNode) Destroy() {
+	x.destroyBase()
+}
+
+// matchRef 判断引用是否满足 Heads、Tags 和 Pattern 的过滤条件
+func (x *GitLsRemoteNode) matchRef(name plumbing.ReferenceName, pattern string) bool {
+	if x.Config.Heads || x.Config.Tags {
+		if !(x.Config.Heads && name.IsBranch()) && !(x.Config.Tags && name.IsTag()) {
+			return false
+		}
+	}
+	if pattern == "" {
+		return true
+	}
+	if ok, _ := path.Match(pattern, name.String()); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, name.Short())
+	return ok
+}
+
+func (x *GitLsRemoteNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// findRemoteHash 按完整名称、分支名、标签名的顺序查找引用的hash
+func findRemoteHash(hashes map[plumbing.ReferenceName]plumbing.Hash, reference string) (plumbing.Hash, bool) {
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.ReferenceName(reference),
+		plumbing.NewBranchReferenceName(reference),
+		plumbing.NewTagReferenceName(reference),
+	} {
+		if hash, ok := hashes[name]; ok {
+			return hash, true
+		}
+	}
+	return plumbing.ZeroHash, false
+}
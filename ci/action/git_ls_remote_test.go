@@ -0,0 +1,89 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestGitLsRemoteNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitLsRemoteNode{})
+	var targetNodeType = "ci/gitLsRemote"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitLsRemoteNode{}, types.Configuration{}, Registry)
+	})
+
+	remoteDir := t.TempDir()
+	remote := initTestRepo(t, remoteDir)
+	mainHash := commitTestFile(t, remote, "a.txt", "a", "add a")
+	_, err := remote.CreateTag("v1.0.0", mainHash, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, remote.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("release-1.0"), mainHash)))
+
+	lsRemote := func(t *testing.T, config types.Configuration) (types.RuleMsg, string, error, []RemoteRef) {
+		config["repository"] = "${metadata.url}"
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		metadata := types.NewMetadata()
+		metadata.PutValue("url", remoteDir)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		var refs []RemoteRef
+		if relationType == types.Success {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &refs))
+		}
+		return outMsg, relationType, err, refs
+	}
+
+	t.Run("All", func(t *testing.T) {
+		outMsg, relationType, err, refs := lsRemote(t, types.Configuration{"reference": "main"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, mainHash.String(), outMsg.Metadata.GetValue(KeyRemoteHash))
+		assert.Equal(t, 4, len(refs))
+		assert.Equal(t, "HEAD", refs[0].Name)
+		assert.Equal(t, mainHash.String(), refs[0].Hash)
+		assert.Equal(t, []RemoteRef{
+			{Name: "refs/heads/main", Hash: mainHash.String()},
+			{Name: "refs/heads/release-1.0", Hash: mainHash.String()},
+			{Name: "refs/tags/v1.0.0", Hash: mainHash.String()},
+		}, refs[1:])
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		_, _, err, refs := lsRemote(t, types.Configuration{"heads": true, "pattern": "release-*"})
+		assert.Nil(t, err)
+		assert.Equal(t, []RemoteRef{{Name: "refs/heads/release-1.0", Hash: mainHash.String()}}, refs)
+
+		outMsg, _, err, refs := lsRemote(t, types.Configuration{"tags": true, "reference": "v1.0.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, mainHash.String(), outMsg.Metadata.GetValue(KeyRemoteHash))
+		assert.Equal(t, []RemoteRef{{Name: "refs/tags/v1.0.0", Hash: mainHash.String()}}, refs)
+	})
+
+	t.Run("ReferenceNotFound", func(t *testing.T) {
+		_, relationType, err, _ := lsRemote(t, types.Configuration{"reference": "feature"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
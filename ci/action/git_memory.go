@@ -0,0 +1,81 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/utils/str"
+	"sync"
+	"time"
+)
+
+// KeyRepoId 内存仓库ID，下游节点通过该ID获取克隆到内存中的仓库
+const KeyRepoId = "repoId"
+
+// MemoryRepositoryTTL 内存仓库的保留时间，超过该时间没有访问的仓库会被清理
+var MemoryRepositoryTTL = time.Minute * 10
+
+// memoryRepository 克隆到内存中的仓库
+type memoryRepository struct {
+	repository *git.Repository
+	accessTime time.Time
+}
+
+var memoryRepositories = struct {
+	sync.Mutex
+	items map[string]*memoryRepository
+}{items: make(map[string]*memoryRepository)}
+
+// putMemoryRepository 保存内存仓库，返回仓库ID
+func putMemoryRepository(r *git.Repository) string {
+	memoryRepositories.Lock()
+	defer memoryRepositories.Unlock()
+	sweepMemoryRepositories()
+	id := str.RandomStr(16)
+	memoryRepositories.items[id] = &memoryRepository{repository: r, accessTime: time.Now()}
+	return id
+}
+
+// getMemoryRepository 根据ID获取内存仓库并更新访问时间，同时清理过期的仓库
+func getMemoryRepository(id string) (*git.Repository, bool) {
+	memoryRepositories.Lock()
+	defer memoryRepositories.Unlock()
+	sweepMemoryRepositories()
+	item, ok := memoryRepositories.items[id]
+	if !ok {
+		return nil, false
+	}
+	item.accessTime = time.Now()
+	return item.repository, true
+}
+
+// removeMemoryRepository 删除内存仓库
+func removeMemoryRepository(id string) {
+	memoryRepositories.Lock()
+	defer memoryRepositories.Unlock()
+	delete(memoryRepositories.items, id)
+}
+
+// sweepMemoryRepositories 清理过期的仓库，调用者必须持有锁
+func sweepMemoryRepositories() {
+	now := time.Now()
+	for id, item := range memoryRepositories.items {
+		if now.Sub(item.accessTime) > MemoryRepositoryTTL {
+			delete(memoryRepositories.items, id)
+		}
+	}
+}
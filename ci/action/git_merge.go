@@ -0,0 +1,282 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitMergeNode{})
+}
+
+// KeyFastForward 是否以快进的方式合并
+const KeyFastForward = "fastForward"
+
+// ErrNonFastForward 只允许快进合并时，目标分支与源分支已经分叉
+var ErrNonFastForward = errors.New("non-fast-forward requires manual merge")
+
+// GitMergeNodeConfiguration 节点配置
+type GitMergeNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 合并的源引用，可以是分支名、完整引用名、标签或者提交hash，例如：develop、refs/remotes/origin/develop
+	SourceRef string
+	// 合并到的目标分支，为空则使用当前分支
+	TargetRef string
+	// 是否只允许快进合并
+	FastForwardOnly bool
+	// 合并提交的注释消息，为空则使用：Merge {SourceRef} into {TargetRef}
+	Message string
+	//签名
+	Signature Signature
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// MergeConflict 合并冲突时写入 msg.Data 的内容
+type MergeConflict struct {
+	// 冲突的文件列表
+	Conflicts []string `json:"conflicts"`
+}
+
+// GitMergeNode 把源引用合并到目标分支
+// 能快进时直接快进，否则在文件级别进行三方合并并创建合并提交，同一个文件在两边都有不同的修改视为冲突
+// 冲突时恢复目标分支，并把冲突的文件列表写入 msg.Data 后发送到Failure链
+type GitMergeNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitMergeNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitMergeNode) Type() string {
+	return "ci/gitMerge"
+}
+
+func (x *GitMergeNode) New() types.Node {
+	return &GitMergeNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitMergeNodeConfiguration{
+			SourceRef:      "develop",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitMergeNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.SourceRef) || str.CheckHasVar(x.Config.TargetRef) || str.CheckHasVar(x.Config.Message) ||
+		str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) {
+		x.hasVar = true
+	}
+	if err == nil && x.Config.SourceRef == "" {
+		err = errors.New("sourceRef can not be empty")
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitMergeNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if dirty, err := isDirtyWorktree(w); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	} else if dirty {
+		ctx.TellFailure(msg, ErrDirtyWorktree)
+		return
+	}
+	target, err := x.checkoutTarget(r, w, x.getValue(x.Config.TargetRef, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	sourceRef := x.getValue(x.Config.SourceRef, evn)
+	sourceHash, err := r.ResolveRevision(plumbing.Revision(sourceRef))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("reference %s not found: %w", sourceRef, err))
+		return
+	}
+	err = x.merge(r, w, msg, target, *sourceHash, sourceRef, evn)
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		if data, jsonErr := json.Marshal(MergeConflict{Conflicts: conflictErr.Files}); jsonErr == nil {
+			msg.DataType = types.JSON
+			msg.Data = string(data)
+		}
+		ctx.TellFailure(msg, err)
+		return
+	} else if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if _, err = x.putHeadMetadata(r, msg); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitMergeNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// checkoutTarget 检出目标分支，为空则使用当前分支，返回目标分支引用
+func (x *GitMergeNode) checkoutTarget(r *git.Repository, w *git.Worktree, targetRef string) (*plumbing.Reference, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	if targetRef == "" {
+		if !head.Name().IsBranch() {
+			return nil, errors.New("HEAD is detached, targetRef can not be empty")
+		}
+		return head, nil
+	}
+	name := branchReferenceName(targetRef)
+	target, err := r.Reference(name, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, name.Short())
+	}
+	if head.Name() != name {
+		if err = w.Checkout(&git.CheckoutOptions{Branch: name}); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// merge 把源提交合并到目标分支，合并提交或者快进后的hash写入元数据hash
+func (x *GitMergeNode) merge(r *git.Repository, w *git.Worktree, msg types.RuleMsg, target *plumbing.Reference, sourceHash plumbing.Hash, sourceRef string, evn map[string]interface{}) error {
+	targetCommit, err := r.CommitObject(target.Hash())
+	if err != nil {
+		return err
+	}
+	sourceCommit, err := r.CommitObject(sourceHash)
+	if err != nil {
+		return err
+	}
+	// 已经包含源提交
+	if merged, err := isMerged(r, sourceHash, target.Hash()); err != nil {
+		return err
+	} else if merged {
+		msg.Metadata.PutValue(x.metaKey(KeyUpToDate), "true")
+		msg.Metadata.PutValue(x.metaKey(KeyFastForward), "false")
+		msg.Metadata.PutValue(x.metaKey(KeyHash), target.Hash().String())
+		return nil
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyUpToDate), "false")
+	// 快进
+	if canFastForward, err := targetCommit.IsAncestor(sourceCommit); err != nil {
+		return err
+	} else if canFastForward {
+		if err = w.Reset(&git.ResetOptions{Commit: sourceHash, Mode: git.HardReset}); err != nil {
+			return err
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyFastForward), "true")
+		msg.Metadata.PutValue(x.metaKey(KeyHash), sourceHash.String())
+		return nil
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyFastForward), "false")
+	if x.Config.FastForwardOnly {
+		return ErrNonFastForward
+	}
+	bases, err := targetCommit.MergeBase(sourceCommit)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("refusing to merge unrelated history %s", sourceHash)
+	}
+	baseTree, err := bases[0].Tree()
+	if err != nil {
+		return err
+	}
+	sourceTree, err := sourceCommit.Tree()
+	if err != nil {
+		return err
+	}
+	if err = applyTreeChanges(w, baseTree, sourceTree); err != nil {
+		// 恢复目标分支
+		_ = w.Reset(&git.ResetOptions{Commit: target.Hash(), Mode: git.HardReset})
+		return err
+	}
+	message := x.getValue(x.Config.Message, evn)
+	if message == "" {
+		message = fmt.Sprintf("Merge %s into %s", sourceRef, target.Name().Short())
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  x.getValue(x.Config.Signature.AuthorName, evn),
+			Email: x.getValue(x.Config.Signature.AuthorEmail, evn),
+			When:  time.Now(),
+		},
+		Parents:           []plumbing.Hash{target.Hash(), sourceHash},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		_ = w.Reset(&git.ResetOptions{Commit: target.Hash(), Mode: git.HardReset})
+		return err
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), hash.String())
+	return nil
+}
+
+func (x *GitMergeNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return value
+}
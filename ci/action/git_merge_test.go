@@ -0,0 +1,127 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitMergeNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitMergeNode{})
+	var targetNodeType = "ci/gitMerge"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitMergeNode{}, types.Configuration{
+			"sourceRef":      "develop",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	// prepare 创建main和develop分支，develop基于main的第一个提交
+	prepare := func(t *testing.T, developFile, developContent string) (string, *git.Repository, plumbing.Hash) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		w, _ := r.Worktree()
+		assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("develop"), Create: true}))
+		developHash := commitTestFile(t, r, developFile, developContent, "develop change")
+		assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.Main}))
+		return dir, r, developHash
+	}
+	merge := func(t *testing.T, dir string, configuration types.Configuration) (types.RuleMsg, string, error) {
+		configuration["directory"] = dir
+		configuration["appendRepoName"] = false
+		configuration["signature"] = map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+
+	t.Run("FastForward", func(t *testing.T) {
+		dir, r, developHash := prepare(t, "d.txt", "d")
+		outMsg, relationType, err := merge(t, dir, types.Configuration{"sourceRef": "develop", "targetRef": "main"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyFastForward))
+		assert.Equal(t, developHash.String(), outMsg.Metadata.GetValue(KeyHash))
+		head, _ := r.Head()
+		assert.Equal(t, plumbing.Main, head.Name())
+		assert.Equal(t, developHash, head.Hash())
+
+		//已经是最新
+		outMsg, relationType, err = merge(t, dir, types.Configuration{"sourceRef": "develop"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyUpToDate))
+	})
+
+	t.Run("NonFastForward", func(t *testing.T) {
+		dir, r, developHash := prepare(t, "src/d.txt", "d")
+		mainHash := commitTestFile(t, r, "m.txt", "m", "main change")
+
+		_, relationType, err := merge(t, dir, types.Configuration{"sourceRef": "develop", "fastForwardOnly": true})
+		assert.Equal(t, ErrNonFastForward, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		outMsg, relationType, err := merge(t, dir, types.Configuration{"sourceRef": "develop"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyFastForward))
+		commit, err := r.CommitObject(plumbing.NewHash(outMsg.Metadata.GetValue(KeyHash)))
+		assert.Nil(t, err)
+		assert.Equal(t, []plumbing.Hash{mainHash, developHash}, commit.ParentHashes)
+		assert.Equal(t, "Merge develop into main", commit.Message)
+		for _, name := range []string{"src/d.txt", "m.txt"} {
+			_, err = os.Stat(filepath.Join(dir, name))
+			assert.Nil(t, err)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		dir, r, _ := prepare(t, "README.md", "develop")
+		mainHash := commitTestFile(t, r, "README.md", "main", "main change")
+
+		outMsg, relationType, err := merge(t, dir, types.Configuration{"sourceRef": "develop"})
+		var conflictErr *ConflictError
+		assert.True(t, errors.As(err, &conflictErr))
+		assert.Equal(t, types.Failure, relationType)
+		var conflict MergeConflict
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &conflict))
+		assert.Equal(t, []string{"README.md"}, conflict.Conflicts)
+		//目标分支保持不变
+		head, _ := r.Head()
+		assert.Equal(t, mainHash, head.Hash())
+		content, _ := os.ReadFile(filepath.Join(dir, "README.md"))
+		assert.Equal(t, "main", string(content))
+	})
+
+	t.Run("UnknownSource", func(t *testing.T) {
+		dir, _, _ := prepare(t, "d.txt", "d")
+		_, relationType, err := merge(t, dir, types.Configuration{"sourceRef": "notExist"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// go-git 的 SSH 传输通过 golang.org/x/net/proxy 连接代理，默认只支持 socks5
+// 注册 http/https 代理，使 SSH 可以通过 HTTP CONNECT 代理连接远程仓库
+func init() {
+	proxy.RegisterDialerType("http", newConnectDialer)
+	proxy.RegisterDialerType("https", newConnectDialer)
+}
+
+// proxyHandshakeTimeout 没有设置超时时间时，连接代理以及 CONNECT 握手的超时时间，避免错误的代理一直阻塞
+const proxyHandshakeTimeout = 30 * time.Second
+
+// connectDialer 通过 HTTP CONNECT 代理建立 TCP 隧道
+type connectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.Dialer
+}
+
+func newConnectDialer(proxyURL *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &connectDialer{proxyURL: proxyURL, forward: forward}, nil
+}
+
+// Dial 通过代理连接 addr
+func (d *connectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext 通过代理连接 addr，代理认证使用代理地址中的用户名和密码
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, proxyHandshakeTimeout)
+		defer cancel()
+	}
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		if d.proxyURL.Scheme == "https" {
+			proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), "443")
+		} else {
+			proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), "80")
+		}
+	}
+	var conn net.Conn
+	var err error
+	if forward, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = forward.DialContext(ctx, "tcp", proxyAddr)
+	} else {
+		conn, err = d.forward.Dial("tcp", proxyAddr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to proxy %s: %w", proxyAddr, err)
+	}
+	// 超时或者取消时中断握手
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	conn, err = d.handshake(ctx, conn, addr)
+	if !stop() || err != nil {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, fmt.Errorf("proxy %s CONNECT %s: %w", proxyAddr, addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake 发送 CONNECT 请求，代理返回2xx后连接即为到 addr 的隧道
+func (d *connectDialer) handshake(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return conn, err
+		}
+		conn = tlsConn
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return conn, err
+	}
+	// 成功的 CONNECT 响应之后是隧道中的数据，不能读取或者关闭响应体
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return conn, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		// 代理在响应后已经发送了隧道中的数据，先读取缓冲区中的数据
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读取握手时已经缓冲的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectProxyStub 记录 CONNECT 请求的本地代理，hang 为true时不响应握手，closeTunnel 为true时握手成功后关闭隧道
+type connectProxyStub struct {
+	listener    net.Listener
+	hang        bool
+	closeTunnel bool
+	requests    chan *http.Request
+}
+
+func newConnectProxyStub(t *testing.T, hang bool) *connectProxyStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	stub := &connectProxyStub{listener: listener, hang: hang, requests: make(chan *http.Request, 10)}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *connectProxyStub) serve(conn net.Conn) {
+	defer conn.Close()
+	if s.hang {
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	s.requests <- req
+	if req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("proxy:secret")) {
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return
+	}
+	if s.closeTunnel {
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		return
+	}
+	// 响应后立即发送隧道中的数据，然后回显
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nhello "))
+	_, _ = io.Copy(conn, reader)
+}
+
+func (s *connectProxyStub) url(user string) string {
+	return "http://" + user + s.listener.Addr().String()
+}
+
+func TestConnectDialer(t *testing.T) {
+	stub := newConnectProxyStub(t, false)
+	proxyURL, _ := url.Parse(stub.url("proxy:secret@"))
+	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	assert.Nil(t, err)
+	conn, err := dialer.(proxy.ContextDialer).DialContext(context.Background(), "tcp", "git.example.com:22")
+	assert.Nil(t, err)
+	req := <-stub.requests
+	assert.Equal(t, http.MethodConnect, req.Method)
+	assert.Equal(t, "git.example.com:22", req.Host)
+	_, err = conn.Write([]byte("ssh"))
+	assert.Nil(t, err)
+	buf := make([]byte, 9)
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello ssh", string(buf))
+	_ = conn.Close()
+
+	proxyURL, _ = url.Parse(stub.url("proxy:wrong@"))
+	dialer, err = proxy.FromURL(proxyURL, proxy.Direct)
+	assert.Nil(t, err)
+	_, err = dialer.Dial("tcp", "git.example.com:22")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "407"))
+}
+
+func TestConnectDialerTimeout(t *testing.T) {
+	stub := newConnectProxyStub(t, true)
+	proxyURL, _ := url.Parse(stub.url(""))
+	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", "git.example.com:22")
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestSshProxy(t *testing.T) {
+	stub := newConnectProxyStub(t, false)
+	stub.closeTunnel = true
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := gossh.MarshalPrivateKey(privateKey, "")
+	assert.Nil(t, err)
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitLsRemoteNode{})
+	node, err := test.CreateAndInitNode("ci/gitLsRemote", types.Configuration{
+		"repository":             "ssh://git@git.example.com:2222/rulego/rulego.git",
+		"authType":               "ssh",
+		"authUser":               "git",
+		"authPemContent":         string(pem.EncodeToMemory(block)),
+		"sshHostKeyVerification": "insecure",
+		"proxyUrl":               stub.url(""),
+		"proxyUsername":          "proxy",
+		"proxyPassword":          "secret",
+	}, Registry)
+	assert.Nil(t, err)
+	// 代理握手成功后关闭隧道，SSH连接失败，但是请求已经通过代理发送到远程仓库
+	_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relationType)
+	req := <-stub.requests
+	assert.Equal(t, "git.example.com:2222", req.Host)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("proxy:secret")), req.Header.Get("Proxy-Authorization"))
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strconv"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitPullNode{})
+}
+
+// GitPullNodeConfiguration 节点配置
+type GitPullNodeConfiguration struct {
+	// Git 仓库 URL，为空则使用仓库配置的 origin 地址
+	Repository string
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 拉取的分支完整引用名，例如：refs/heads/main，为空则使用元数据ref，都为空则拉取当前分支
+	Reference string
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
+	ProxyUrl string
+	// 代理用户名
+	ProxyUsername string
+	// 代理密码
+	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// PullResult 拉取结果
+type PullResult struct {
+	// 拉取前的HEAD
+	OldHash string `json:"oldHash"`
+	// 拉取后的HEAD
+	NewHash string `json:"newHash"`
+	// 是否已经是最新
+	UpToDate bool `json:"upToDate"`
+	// 两次HEAD之间变更的文件
+	ChangedFiles []string `json:"changedFiles"`
+}
+
+// GitPullNode 拉取已存在的本地仓库，与 GitCloneNode 不同，目录不是git仓库时不会克隆而是失败
+// 拉取前后的HEAD以及变更的文件列表写入 msg.Data
+type GitPullNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitPullNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitPullNode) Type() string {
+	return "ci/gitPull"
+}
+
+func (x *GitPullNode) New() types.Node {
+	return &GitPullNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitPullNodeConfiguration{
+			AuthType:       "token",
+			AuthPassword:   "${vars.token}",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitPullNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Reference) || x.authHasVar() {
+		x.hasVar = true
+	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitPullNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
+	repository := x.getRepository(msg, evn)
+	ref := x.getReferenceName(msg, evn)
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	// 记录拉取前的HEAD，用于判断是否有更新
+	var oldHash plumbing.Hash
+	if head, err := r.Head(); err == nil {
+		oldHash = head.Hash()
+	}
+	pullOptions := &git.PullOptions{
+		RemoteURL:       repository,
+		Force:           true,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if ref != "" {
+		pullOptions.ReferenceName = plumbing.ReferenceName(ref)
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	} else if auth != nil {
+		pullOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		pullOptions.ProxyOptions = proxy
+	}
+	// 下载的对象写入对象库，后续节点重新打开仓库
+	defer x.invalidateRepository(workDir)
+	if err = x.execute(ctx, msg, "pull", repository, func(opCtx context.Context) error {
+		return w.PullContext(opCtx, pullOptions)
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	newHash, err := x.putHeadMetadata(r, msg)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	result := PullResult{
+		OldHash:      oldHash.String(),
+		NewHash:      newHash.String(),
+		UpToDate:     newHash == oldHash,
+		ChangedFiles: []string{},
+	}
+	if !result.UpToDate {
+		if result.ChangedFiles, err = changedFiles(r, oldHash, newHash); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyUpToDate), strconv.FormatBool(result.UpToDate))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitPullNode) Destroy() {
+	x.destroyBase()
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitPullNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPullNode{})
+	var targetNodeType = "ci/gitPull"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitPullNode{}, types.Configuration{
+			"authType":       "token",
+			"authPassword":   "${vars.token}",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	tmp := t.TempDir()
+	remoteDir := filepath.Join(tmp, "remote")
+	remote := initTestRepo(t, remoteDir)
+	workDir := filepath.Join(tmp, "work")
+	_, err := git.PlainClone(filepath.Join(workDir, "remote"), false, &git.CloneOptions{URL: remoteDir})
+	assert.Nil(t, err)
+
+	node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+		"repository": remoteDir,
+		"directory":  workDir,
+		"reference":  "refs/heads/main",
+		"authType":   "",
+	}, Registry)
+	assert.Nil(t, err)
+
+	pull := func(t *testing.T) (types.RuleMsg, PullResult) {
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result PullResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return outMsg, result
+	}
+
+	t.Run("UpToDate", func(t *testing.T) {
+		head, _ := remote.Head()
+		outMsg, result := pull(t)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyUpToDate))
+		assert.True(t, result.UpToDate)
+		assert.Equal(t, head.Hash().String(), result.OldHash)
+		assert.Equal(t, head.Hash().String(), result.NewHash)
+		assert.Equal(t, 0, len(result.ChangedFiles))
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		oldHead, _ := remote.Head()
+		hash := commitTestFile(t, remote, "a.txt", "a", "add a")
+		outMsg, result := pull(t)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+		assert.Equal(t, hash.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		assert.False(t, result.UpToDate)
+		assert.Equal(t, oldHead.Hash().String(), result.OldHash)
+		assert.Equal(t, hash.String(), result.NewHash)
+		assert.Equal(t, []string{"a.txt"}, result.ChangedFiles)
+	})
+
+	t.Run("NotRepository", func(t *testing.T) {
+		plainDir := filepath.Join(t.TempDir(), "plain")
+		_ = os.MkdirAll(plainDir, os.ModePerm)
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"repository":     remoteDir,
+			"directory":      plainDir,
+			"appendRepoName": false,
+			"authType":       "",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		//不会克隆
+		_, err = os.Stat(filepath.Join(plainDir, ".git"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
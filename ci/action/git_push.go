@@ -17,43 +17,208 @@
 package action
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/rulego/rulego"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
-	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 func init() {
 	_ = rulego.Registry.Register(&GitPushNode{})
 }
 
+// KeyPushRefSpecs 实际推送使用的refspec，多个与逗号隔开
+const KeyPushRefSpecs = "pushRefSpecs"
+
+const (
+	// PushActionCreate 在远程仓库创建引用
+	PushActionCreate = "create"
+	// PushActionUpdate 更新远程仓库的引用
+	PushActionUpdate = "update"
+	// PushActionDelete 删除远程仓库的引用
+	PushActionDelete = "delete"
+)
+
+var (
+	// ErrLeaseRejected 远程引用已经不是期望的hash，说明远程仓库有其他推送，中止强制推送
+	ErrLeaseRejected = errors.New("remote ref does not match the expected hash")
+	// ErrPushOptionsNotSupported 远程仓库不支持推送选项
+	ErrPushOptionsNotSupported = errors.New("remote does not support push options")
+	// ErrAtomicNotSupported 远程仓库不支持原子推送
+	ErrAtomicNotSupported = errors.New("remote does not support atomic push")
+)
+
+const (
+	// FailOnAny 任意一个远程仓库推送失败都转到失败分支
+	FailOnAny = "any"
+	// FailOnAll 所有远程仓库都推送失败才转到失败分支
+	FailOnAll = "all"
+)
+
+// RemoteConfig 推送的远程仓库，没有配置的认证信息使用节点的配置
+type RemoteConfig struct {
+	// Git 仓库 URL，支持${metadata.xx}变量
+	Repository string
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值
+	AuthPemContent string
+}
+
 // GitPushNodeConfiguration 节点配置
 type GitPushNodeConfiguration struct {
-	// Git 仓库 URL
+	// Git 仓库 URL，多个与逗号隔开，同时推送到多个远程仓库。为空时使用元数据中的地址或者 RepositoriesFromData 指定的 msg.Data 中的地址
 	Repository string
+	// Repository 为空时是否推送到 msg.Data 中的远程仓库，msg.Data 为仓库地址的JSON数组，例如：["https://github.com/a/b.git"]
+	// msg.Data 不是仓库地址的JSON数组时使用元数据中的地址或者 RemoteName 的远程仓库
+	RepositoriesFromData bool
+	// 推送到的多个远程仓库，地址与 Repository 中相同时覆盖该远程仓库的认证信息，否则作为额外的推送目标
+	Remotes []RemoteConfig
+	// 推送到多个远程仓库时何时转到失败分支，可以是 any(任意一个失败，默认) 或 all(全部失败)，结果中保留每个远程仓库的成功或者失败信息
+	FailOn string
+	// 推送到的远程仓库名称，默认origin，Repository 为空时使用该远程仓库的地址
+	// 默认的 origin 不存在时使用元数据中的地址，为空则和 Repository 为空时一样使用元数据中的地址
+	RemoteName string
 	// 推送到的本地目录
 	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
 	//RefSpecs 用于定义本地分支与远程分支之间的映射关系，例如：refs/heads/your-branch:refs/heads/your-branch，多个映射关系与逗号隔开
+	// 支持 :refs/heads/old-branch 形式删除远程引用，为空则推送当前分支到远程仓库的同名分支，HEAD 处于分离状态时失败
 	RefSpecs string
-	// 认证类型，可以是 "ssh", "password", 或 "token"
+	// 是否强制推送，把每个refspec转换为强制更新的形式 +src:dst，允许非快进更新
+	Force bool
+	// 推送前远程引用期望的hash，例如：${metadata.remoteHash}，相当于 git push --force-with-lease
+	// 配置后 RefSpecs 中每个目标引用在远程仓库的hash都必须与该值一致，否则中止推送，全0的hash表示远程引用必须不存在
+	ExpectedRemoteHash string
+	// 是否只演练，不实际推送，只比较本地引用和远程仓库的引用，把计划的更新写入 msg.Data
+	DryRun bool
+	// 是否删除远程仓库中本地已经不存在的引用，只删除与 RefSpecs 目标匹配的引用，相当于 git push --prune
+	Prune bool
+	// 是否同时推送指向被推送提交的附注标签，相当于 git push --follow-tags
+	FollowTags bool
+	// 是否推送所有本地标签，相当于追加 refs/tags/*:refs/tags/*
+	PushAllTags bool
+	// 是否推送 GitCreateTagNode 刚创建的标签，元数据 newTag 不为空时追加 refs/tags/{newTag}:refs/tags/{newTag}
+	PushCreatedTag bool
+	// 推送选项，传递给远程仓库的 pre-receive 等钩子，相当于 git push -o key=value，例如：{"ci.skip":"true"}
+	// 值支持${metadata.xx}变量，远程仓库不支持推送选项时失败
+	Options map[string]string
+	// 是否原子推送，所有引用要么全部更新要么全部不更新，相当于 git push --atomic，远程仓库不支持时失败
+	Atomic bool
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
 	AuthType string
-	// 用户名
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
 	AuthUser string
-	// 密码或 token
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
 	AuthPassword string
-	// SSH 秘钥文件路径
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
 	AuthPemFile string
-	// 代理地址
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
 	ProxyUrl string
 	// 代理用户名
 	ProxyUsername string
 	// 代理密码
 	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// PushResult 推送结果，DryRun 时为计划的更新
+type PushResult struct {
+	// 远程仓库地址或者名称
+	Remote string `json:"remote"`
+	// 是否只是演练
+	DryRun bool `json:"dryRun"`
+	// 远程仓库中被创建或者更新的引用
+	Updated []PushedRef `json:"updated"`
+	// 远程仓库中被删除的引用
+	Deleted []PushedRef `json:"deleted"`
+	// 实际推送使用的refspec
+	RefSpecs []string `json:"refSpecs,omitempty"`
+	// 推送成功后列出远程引用失败时为true，Updated 和 Deleted 为根据推送前的远程引用预测的结果
+	Planned bool `json:"planned,omitempty"`
+}
+
+// MultiPushResult 推送到多个远程仓库的结果
+type MultiPushResult struct {
+	// 每个远程仓库的推送结果，顺序与配置一致
+	Remotes []RemotePushResult `json:"remotes"`
+	// 推送失败的远程仓库数量
+	Failed int `json:"failed"`
+}
+
+// RemotePushResult 一个远程仓库的推送结果
+type RemotePushResult struct {
+	PushResult
+	// 是否推送成功
+	Success bool `json:"success"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+}
+
+// PushedRef 远程仓库中发生变化的引用
+type PushedRef struct {
+	// 引用名称，例如：refs/heads/main
+	Name string `json:"name"`
+	// 推送前远程仓库的hash，新建的引用为空
+	OldHash string `json:"oldHash,omitempty"`
+	// 推送后远程仓库的hash，被删除的引用为空
+	NewHash string `json:"newHash,omitempty"`
+	// 操作，可以是 create、update 或 delete
+	Action string `json:"action"`
+	// 更新是否是快进
+	FastForward bool `json:"fastForward"`
+	// 是否是非快进的强制更新
+	Forced bool `json:"forced"`
+	// 是否因为非快进并且没有强制推送而会被远程仓库拒绝，只用于 DryRun
+	Rejected bool `json:"rejected,omitempty"`
 }
 
-// GitPushNode 实现 Git 推送
+// GitPushNode 实现 Git 推送，远程仓库中发生变化的引用以JSON的形式写入 msg.Data
 type GitPushNode struct {
 	baseGitNode
 	// 节点配置
@@ -67,20 +232,51 @@ func (x *GitPushNode) Type() string {
 }
 
 func (x *GitPushNode) New() types.Node {
-	return &GitPushNode{Config: GitPushNodeConfiguration{
-		RefSpecs:     "refs/heads/main:refs/heads/main",
-		AuthType:     "token",
-		AuthPassword: "${vars.token}",
-	}}
+	return &GitPushNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitPushNodeConfiguration{
+			RemoteName:     git.DefaultRemoteName,
+			FailOn:         FailOnAny,
+			AuthType:       "token",
+			AuthPassword:   "${vars.token}",
+			AppendRepoName: true,
+		},
+	}
 }
 
 // Init 初始化
 func (x *GitPushNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
-	err := maps.Map2Struct(configuration, &x.Config)
-	err = maps.Map2Struct(configuration, &x.baseGitNode.Config)
-	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.RefSpecs) {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.RefSpecs) || str.CheckHasVar(x.Config.ExpectedRemoteHash) || x.authHasVar() ||
+		str.CheckHasVar(x.Config.RemoteName) {
 		x.hasVar = true
 	}
+	for _, v := range x.Config.Options {
+		if str.CheckHasVar(v) {
+			x.hasVar = true
+		}
+	}
+	for _, remote := range x.Config.Remotes {
+		node := x.remoteNode(remote)
+		if str.CheckHasVar(remote.Repository) || node.authHasVar() {
+			x.hasVar = true
+		}
+		if err == nil {
+			err = node.validateConfig()
+		}
+	}
+	switch x.Config.FailOn {
+	case "":
+		x.Config.FailOn = FailOnAny
+	case FailOnAny, FailOnAll:
+	default:
+		if err == nil {
+			err = errors.New("not failOn=" + x.Config.FailOn)
+		}
+	}
+	if err == nil {
+		err = x.initBase(ruleConfig)
+	}
 	return err
 }
 
@@ -90,35 +286,631 @@ func (x *GitPushNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 	if x.hasVar {
 		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
 	}
-	refSpecs := x.getRefSpecs(msg, evn)
 	workDir := x.getWorkDir(msg, evn)
-	msg.Metadata.PutValue(KeyWorkDir, workDir)
-	repository := x.getRepository(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
 	// 打开仓库
-	r, err := git.PlainOpen(workDir)
+	r, err := x.openLockedRepository(msg, workDir)
 	if err != nil {
-		ctx.TellFailure(msg, err)
+		x.tellFailure(ctx, msg, err)
 		return
 	}
-	// 根据 AuthType 字段的值选择认证方式
-	if auth, err := x.getAuthMethod(); err != nil {
-		ctx.TellFailure(msg, err)
+	refSpecs, err := x.resolveRefSpecs(r, msg, evn)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
 		return
+	}
+	remoteName := x.Config.RemoteName
+	if evn != nil {
+		remoteName = str.ExecuteTemplate(remoteName, evn)
+	}
+	remoteName = strings.TrimSpace(remoteName)
+	targets, err := x.getPushTargets(msg, evn)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if len(targets) <= 1 {
+		target := RemoteConfig{}
+		if len(targets) == 1 {
+			target = targets[0]
+		} else if target.Repository, err = x.getPushRepository(r, msg, remoteName, evn); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+		result, err := x.pushRemote(ctx, msg, r, evn, remoteName, target, refSpecs)
+		if err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyPushRefSpecs), strings.Join(result.RefSpecs, ","))
+		msg.Metadata.PutValue(x.metaKey(KeyUpToDate), strconv.FormatBool(len(result.Updated) == 0 && len(result.Deleted) == 0))
+		x.tellResult(ctx, msg, result, nil)
+		return
+	}
+	// 依次推送到每个远程仓库，一个远程仓库失败不影响其他远程仓库
+	result := MultiPushResult{Remotes: make([]RemotePushResult, 0, len(targets))}
+	upToDate := true
+	var errs []error
+	for _, target := range targets {
+		pushResult, err := x.pushRemote(ctx, msg, r, evn, remoteName, target, refSpecs)
+		item := RemotePushResult{PushResult: pushResult, Success: err == nil}
+		if err != nil {
+			node := x.remoteNode(target)
+			err = node.redact(ctx, msg, err)
+			item.Error = err.Error()
+			result.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", redactURL(target.Repository), err))
+		} else if len(pushResult.Updated) > 0 || len(pushResult.Deleted) > 0 {
+			upToDate = false
+		}
+		result.Remotes = append(result.Remotes, item)
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyUpToDate), strconv.FormatBool(result.Failed == 0 && upToDate))
+	var pushErr error
+	if result.Failed > 0 && (x.Config.FailOn != FailOnAll || result.Failed == len(targets)) {
+		pushErr = fmt.Errorf("push failed for %d of %d remotes: %w", result.Failed, len(targets), errors.Join(errs...))
+	}
+	x.tellResult(ctx, msg, result, pushErr)
+}
+
+// pushRemote 推送到一个远程仓库，target.Repository 为空时推送到 remoteName 远程仓库
+func (x *GitPushNode) pushRemote(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, evn map[string]interface{}, remoteName string, target RemoteConfig, refSpecs []config.RefSpec) (PushResult, error) {
+	repository := target.Repository
+	remote := repository
+	if remote == "" {
+		remote = remoteName
+	}
+	if remote == "" {
+		remote = git.DefaultRemoteName
+	}
+	result := PushResult{Remote: redactURL(remote), DryRun: x.Config.DryRun}
+	// 根据 AuthType 字段的值选择认证方式，远程仓库配置了认证信息时覆盖节点的认证信息
+	node := x.remoteNode(target)
+	auth, err := node.getAuthMethod(evn)
+	if err != nil {
+		return result, err
+	}
+	leaseRefSpecs := refSpecs
+	if x.Config.Force {
+		refSpecs = forceRefSpecs(refSpecs)
+	}
+	if x.Config.PushAllTags {
+		refSpecs = append(refSpecs, config.RefSpec("refs/tags/*:refs/tags/*"))
+	} else if tag := msg.Metadata.GetValue(x.metaKey(KeyNewTag)); x.Config.PushCreatedTag && tag != "" {
+		tagRef := plumbing.NewTagReferenceName(tag).String()
+		refSpecs = append(refSpecs, config.RefSpec(tagRef+":"+tagRef))
+	}
+	before, err := x.listRemoteRefs(ctx, msg, r, remoteName, repository, auth)
+	if err != nil {
+		return result, err
+	}
+	if x.Config.ExpectedRemoteHash != "" {
+		if err = x.checkLease(msg, evn, leaseRefSpecs, before); err != nil {
+			return result, err
+		}
+	}
+	after, rejected, err := x.planPush(r, refSpecs, before)
+	if err != nil {
+		return result, err
+	}
+	// go-git 的 Prune 不支持强制更新的refspec，FollowTags 不支持同时删除引用，所以根据计划转换为明确的refspec推送
+	if x.Config.Prune {
+		explicit := make(map[plumbing.ReferenceName]bool)
+		for _, refSpec := range refSpecs {
+			if refSpec.IsDelete() {
+				explicit[refSpec.Dst("")] = true
+			}
+		}
+		for name := range before {
+			if _, ok := after[name]; !ok && !explicit[name] {
+				refSpecs = append(refSpecs, config.RefSpec(":"+name.String()))
+			}
+		}
+	}
+	if x.Config.FollowTags {
+		for name := range after {
+			if _, ok := before[name]; !ok && name.IsTag() && !config.MatchAny(refSpecs, name) {
+				refSpecs = append(refSpecs, config.RefSpec(name.String()+":"+name.String()))
+			}
+		}
+	}
+	if len(x.Config.Options) > 0 || x.Config.Atomic {
+		if err = x.checkCapabilities(ctx, msg, r, remoteName, repository, auth); err != nil {
+			return result, err
+		}
+	}
+	for _, refSpec := range refSpecs {
+		result.RefSpecs = append(result.RefSpecs, refSpec.String())
+	}
+	if x.Config.DryRun {
+		result.Updated, result.Deleted = diffRemoteRefs(r, before, after)
+		for name, hash := range rejected {
+			item := newPushedRef(r, name, before[name], hash)
+			item.Rejected = true
+			result.Updated = append(result.Updated, item)
+		}
+		sortPushedRefs(result.Updated)
+		return result, nil
+	}
+	pushOptions := &git.PushOptions{
+		RemoteName:      remoteName,
+		RefSpecs:        refSpecs,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+		Atomic:          x.Config.Atomic,
+	}
+	if pushOptions.RemoteName == "" {
+		pushOptions.RemoteName = git.DefaultRemoteName
+	}
+	if len(x.Config.Options) > 0 {
+		pushOptions.Options = make(map[string]string, len(x.Config.Options))
+		for k, v := range x.Config.Options {
+			if evn != nil {
+				v = str.ExecuteTemplate(v, evn)
+			}
+			pushOptions.Options[k] = v
+		}
+	}
+	if auth != nil {
+		pushOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		pushOptions.ProxyOptions = proxy
+	}
+	// 推送到远程仓库，配置了地址时不要求本地存在同名的远程仓库
+	err = x.execute(ctx, msg, "push", remote, func(opCtx context.Context) error {
+		if repository == "" {
+			return r.PushContext(opCtx, pushOptions)
+		}
+		return git.NewRemote(r.Storer, &config.RemoteConfig{
+			Name: pushOptions.RemoteName,
+			URLs: []string{repository},
+		}).PushContext(opCtx, pushOptions)
+	})
+	// 远程仓库已经是最新，重复执行推送也认为是成功
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		result.Updated, result.Deleted = diffRemoteRefs(r, before, before)
+		return result, nil
+	}
+	if err != nil {
+		return result, x.pushError(err, remote)
+	}
+	// 推送已经成功，重新列出远程引用失败时使用推送前预测的结果
+	if listed, err := x.listRemoteRefs(ctx, msg, r, remoteName, repository, auth); err == nil {
+		after = listed
 	} else {
-		pushOptions := &git.PushOptions{
-			RemoteURL: repository,
-			RefSpecs:  refSpecs,
-			Auth:      auth,
+		result.Planned = true
+	}
+	result.Updated, result.Deleted = diffRemoteRefs(r, before, after)
+	return result, nil
+}
+
+// remoteNode 返回使用远程仓库认证信息的节点副本，远程仓库没有配置的认证信息使用节点的配置
+func (x *GitPushNode) remoteNode(target RemoteConfig) baseGitNode {
+	node := x.baseGitNode
+	if target.AuthType != "" {
+		node.Config.AuthType = target.AuthType
+	}
+	if target.AuthUser != "" {
+		node.Config.AuthUser = target.AuthUser
+	}
+	if target.AuthPassword != "" {
+		node.Config.AuthPassword = target.AuthPassword
+	}
+	if target.AuthPemFile != "" {
+		node.Config.AuthPemFile = target.AuthPemFile
+		node.Config.AuthPemContent = ""
+	}
+	if target.AuthPemContent != "" {
+		node.Config.AuthPemContent = target.AuthPemContent
+	}
+	return node
+}
+
+// repositoriesFromData 解析 msg.Data 中的仓库地址JSON数组，不是仓库地址的数组时返回空
+func repositoriesFromData(data string) []string {
+	var urls []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &urls); err != nil {
+		return nil
+	}
+	for _, u := range urls {
+		if !isRepositoryURL(strings.TrimSpace(u)) {
+			return nil
+		}
+	}
+	return urls
+}
+
+// isRepositoryURL 判断是否是远程仓库地址：带协议的URL、scp 风格的地址或者本地仓库的绝对路径
+func isRepositoryURL(u string) bool {
+	return strings.Contains(u, "://") || scpLikeUrlRegExp.MatchString(u) || filepath.IsAbs(u)
+}
+
+// getPushTargets 获取推送的多个远程仓库，Repository 是逗号隔开的地址列表，为空并且 RepositoriesFromData 为true时使用 msg.Data 中的JSON数组
+// Remotes 中地址相同的配置覆盖认证信息，其他的追加为推送目标。没有配置多个远程仓库时返回空
+func (x *GitPushNode) getPushTargets(msg types.RuleMsg, evn map[string]interface{}) ([]RemoteConfig, error) {
+	var urls []string
+	if x.Config.Repository != "" {
+		repository := x.Config.Repository
+		if evn != nil {
+			repository = str.ExecuteTemplate(repository, evn)
+		}
+		urls = strings.Split(repository, ",")
+	} else if x.Config.RepositoriesFromData {
+		urls = repositoriesFromData(msg.Data)
+	}
+	var targets []RemoteConfig
+	index := make(map[string]int)
+	add := func(target RemoteConfig) {
+		target.Repository = strings.TrimSpace(target.Repository)
+		if target.Repository == "" {
+			return
+		}
+		if i, ok := index[target.Repository]; ok {
+			target.Repository = targets[i].Repository
+			targets[i] = target
+			return
 		}
-		// 推送到远程仓库
-		if err = r.Push(pushOptions); err != nil {
-			ctx.TellFailure(msg, err)
-		} else {
-			ctx.TellSuccess(msg)
+		index[target.Repository] = len(targets)
+		targets = append(targets, target)
+	}
+	for _, url := range urls {
+		add(RemoteConfig{Repository: url})
+	}
+	for _, remote := range x.Config.Remotes {
+		if evn != nil {
+			remote.Repository = str.ExecuteTemplate(remote.Repository, evn)
 		}
+		add(remote)
 	}
+	if x.Config.Repository != "" && len(targets) == 1 && len(x.Config.Remotes) == 0 {
+		// 只配置一个地址时和原来一样，兼容远程仓库不存在时的处理
+		return nil, nil
+	}
+	return targets, nil
 }
 
 // Destroy 销毁
 func (x *GitPushNode) Destroy() {
+	x.destroyBase()
+}
+
+// getPushRepository 获取推送的仓库地址，没有配置 Repository 并且远程仓库存在时返回空，使用远程仓库配置的地址
+func (x *GitPushNode) getPushRepository(r *git.Repository, msg types.RuleMsg, remoteName string, evn map[string]interface{}) (string, error) {
+	if remoteName == "" || x.Config.Repository != "" {
+		return x.getRepository(msg, evn), nil
+	}
+	if _, err := r.Remote(remoteName); err != nil {
+		// 默认的 origin 不存在时兼容使用元数据中的地址
+		if repository := x.getRepository(msg, evn); remoteName == git.DefaultRemoteName && repository != "" && errors.Is(err, git.ErrRemoteNotFound) {
+			return repository, nil
+		}
+		return "", fmt.Errorf("%w: %s", err, remoteName)
+	}
+	return "", nil
+}
+
+// resolveRefSpecs 获取推送的refspec，没有配置时推送当前分支到远程仓库的同名分支
+func (x *GitPushNode) resolveRefSpecs(r *git.Repository, msg types.RuleMsg, evn map[string]interface{}) ([]config.RefSpec, error) {
+	refSpecs, err := x.getRefSpecs(msg, evn)
+	if err != nil || len(refSpecs) > 0 {
+		return refSpecs, err
+	}
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return nil, err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return nil, errors.New("HEAD is detached, refSpecs can not be empty")
+	}
+	branch := head.Target().String()
+	return []config.RefSpec{config.RefSpec(branch + ":" + branch)}, nil
+}
+
+// tellResult 把推送结果写入 msg.Data，pushErr 不为空时保留结果并转到失败分支
+func (x *GitPushNode) tellResult(ctx types.RuleContext, msg types.RuleMsg, result interface{}, pushErr error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	if pushErr != nil {
+		x.tellFailure(ctx, msg, pushErr)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// planPush 根据本地引用和refspec预测推送后远程仓库的引用，同时返回因为非快进更新会被拒绝的引用
+func (x *GitPushNode) planPush(r *git.Repository, refSpecs []config.RefSpec, remoteRefs map[plumbing.ReferenceName]plumbing.Hash) (map[plumbing.ReferenceName]plumbing.Hash, map[plumbing.ReferenceName]plumbing.Hash, error) {
+	localRefs := make(map[plumbing.ReferenceName]plumbing.Hash)
+	iter, err := r.References()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			localRefs[ref.Name()] = ref.Hash()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	after := make(map[plumbing.ReferenceName]plumbing.Hash, len(remoteRefs))
+	for name, hash := range remoteRefs {
+		after[name] = hash
+	}
+	rejected := make(map[plumbing.ReferenceName]plumbing.Hash)
+	update := func(refSpec config.RefSpec, dst plumbing.ReferenceName, hash plumbing.Hash) {
+		old, ok := remoteRefs[dst]
+		if ok && old == hash {
+			return
+		}
+		if ok && !refSpec.IsForceUpdate() {
+			if ff, _ := isMerged(r, old, hash); !ff {
+				rejected[dst] = hash
+				return
+			}
+		}
+		after[dst] = hash
+	}
+	for _, refSpec := range refSpecs {
+		switch {
+		case refSpec.IsDelete():
+			delete(after, refSpec.Dst(""))
+			continue
+		case refSpec.IsWildcard():
+			for name, hash := range localRefs {
+				if refSpec.Match(name) {
+					update(refSpec, refSpec.Dst(name), hash)
+				}
+			}
+		default:
+			if hash, ok := localRefs[plumbing.ReferenceName(refSpec.Src())]; ok {
+				update(refSpec, refSpec.Dst(""), hash)
+			} else if plumbing.IsHash(refSpec.Src()) {
+				update(refSpec, refSpec.Dst(""), plumbing.NewHash(refSpec.Src()))
+			}
+		}
+		if x.Config.Prune {
+			reverse := config.RefSpec(strings.TrimPrefix(refSpec.String(), "+")).Reverse()
+			for name := range remoteRefs {
+				if !reverse.Match(name) {
+					continue
+				}
+				if _, ok := localRefs[reverse.Dst(name)]; !ok {
+					delete(after, name)
+				}
+			}
+		}
+	}
+	if x.Config.FollowTags {
+		planFollowTags(r, localRefs, remoteRefs, after)
+	}
+	return after, rejected, nil
+}
+
+// planFollowTags 预测 FollowTags 推送的附注标签，即指向被推送提交的祖先提交并且远程仓库还没有的附注标签
+func planFollowTags(r *git.Repository, localRefs, remoteRefs, after map[plumbing.ReferenceName]plumbing.Hash) {
+	var pushed []*object.Commit
+	for name, hash := range after {
+		if old, ok := remoteRefs[name]; (!ok || old != hash) && !name.IsTag() {
+			if commit, err := r.CommitObject(hash); err == nil {
+				pushed = append(pushed, commit)
+			}
+		}
+	}
+	for name, hash := range localRefs {
+		if _, ok := remoteRefs[name]; ok || !name.IsTag() {
+			continue
+		}
+		tag, err := r.TagObject(hash)
+		if err != nil {
+			continue
+		}
+		target, err := tag.Commit()
+		if err != nil {
+			continue
+		}
+		for _, commit := range pushed {
+			if ok, _ := target.IsAncestor(commit); ok {
+				after[name] = hash
+				break
+			}
+		}
+	}
+}
+
+// pushError 把常见的推送错误转换为更明确的错误信息
+func (x *GitPushNode) pushError(err error, remote string) error {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed):
+		return fmt.Errorf("push to %s: %w, check authType and the credentials", remote, err)
+	case !x.Config.Force && strings.Contains(err.Error(), "non-fast-forward"):
+		return fmt.Errorf("%w, the remote ref contains commits that are not present locally, pull first or enable force to overwrite it", err)
+	}
+	return err
+}
+
+// checkLease 检查 RefSpecs 中每个目标引用在远程仓库的hash是否与期望的hash一致
+func (x *GitPushNode) checkLease(msg types.RuleMsg, evn map[string]interface{}, refSpecs []config.RefSpec, remoteRefs map[plumbing.ReferenceName]plumbing.Hash) error {
+	expected := x.Config.ExpectedRemoteHash
+	if evn != nil {
+		expected = str.ExecuteTemplate(expected, evn)
+	}
+	expected = strings.TrimSpace(expected)
+	if !plumbing.IsHash(expected) {
+		return fmt.Errorf("invalid expectedRemoteHash: %q", expected)
+	}
+	for _, refSpec := range refSpecs {
+		if refSpec.IsWildcard() {
+			continue
+		}
+		dst := refSpec.Dst("")
+		if actual := remoteRefs[dst]; actual.String() != expected {
+			return fmt.Errorf("%w: %s is %s, expected %s", ErrLeaseRejected, dst, actual, expected)
+		}
+	}
+	return nil
+}
+
+// checkCapabilities 检查远程仓库是否支持推送选项和原子推送
+// go-git 在远程仓库不支持时会静默忽略这两个选项，所以推送前先获取 receive-pack 声明的能力
+func (x *GitPushNode) checkCapabilities(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, remoteName, repository string, auth transport.AuthMethod) error {
+	url := repository
+	if url == "" {
+		if remoteName == "" {
+			remoteName = git.DefaultRemoteName
+		}
+		remote, err := r.Remote(remoteName)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, remoteName)
+		}
+		if urls := remote.Config().URLs; len(urls) > 0 {
+			url = urls[0]
+		}
+	}
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return err
+	}
+	ep.InsecureSkipTLS = x.Config.InsecureSkipVerify
+	ep.CaBundle = x.caBundle
+	ep.Proxy = x.getProxy()
+	cli, err := client.NewClient(ep)
+	if err != nil {
+		return err
+	}
+	var ar *packp.AdvRefs
+	err = x.execute(ctx, msg, "receive-pack", url, func(opCtx context.Context) error {
+		session, err := cli.NewReceivePackSession(ep, auth)
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		ar, err = session.AdvertisedReferencesContext(opCtx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(x.Config.Options) > 0 && !ar.Capabilities.Supports(capability.PushOptions) {
+		return fmt.Errorf("%w: %s", ErrPushOptionsNotSupported, url)
+	}
+	if x.Config.Atomic && !ar.Capabilities.Supports(capability.Atomic) {
+		return fmt.Errorf("%w: %s", ErrAtomicNotSupported, url)
+	}
+	return nil
+}
+
+// forceRefSpecs 把refspec转换为强制更新的形式，删除引用的refspec保持不变
+func forceRefSpecs(refSpecs []config.RefSpec) []config.RefSpec {
+	forced := make([]config.RefSpec, 0, len(refSpecs))
+	for _, refSpec := range refSpecs {
+		if !refSpec.IsForceUpdate() && !refSpec.IsDelete() {
+			refSpec = "+" + refSpec
+		}
+		forced = append(forced, refSpec)
+	}
+	return forced
+}
+
+// listRemoteRefs 列出远程仓库的引用，用于比较推送前后远程仓库的变化，空仓库返回空结果
+func (x *GitPushNode) listRemoteRefs(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, remoteName, repository string, auth transport.AuthMethod) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	var remote *git.Remote
+	if repository != "" {
+		remote = git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{repository},
+		})
+	} else {
+		if remoteName == "" {
+			remoteName = git.DefaultRemoteName
+		}
+		var err error
+		if remote, err = r.Remote(remoteName); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, remoteName)
+		}
+		repository = remoteName
+	}
+	listOptions := &git.ListOptions{
+		Auth:            auth,
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+		PeelingOption:   git.IgnorePeeled,
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		listOptions.ProxyOptions = proxy
+	}
+	var refs []*plumbing.Reference
+	err := x.execute(ctx, msg, "ls-remote", repository, func(opCtx context.Context) error {
+		var err error
+		refs, err = remote.ListContext(opCtx, listOptions)
+		return err
+	})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, err
+	}
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash, len(refs))
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+	return hashes, nil
+}
+
+// diffRemoteRefs 比较推送前后远程仓库的引用，返回按名称排序的新建或者更新的引用，以及被删除的引用
+func diffRemoteRefs(r *git.Repository, before, after map[plumbing.ReferenceName]plumbing.Hash) ([]PushedRef, []PushedRef) {
+	updated := make([]PushedRef, 0)
+	for name, hash := range after {
+		if oldHash, ok := before[name]; !ok || oldHash != hash {
+			updated = append(updated, newPushedRef(r, name, oldHash, hash))
+		}
+	}
+	deleted := make([]PushedRef, 0)
+	for name, hash := range before {
+		if _, ok := after[name]; !ok {
+			deleted = append(deleted, newPushedRef(r, name, hash, plumbing.ZeroHash))
+		}
+	}
+	sortPushedRefs(updated)
+	sortPushedRefs(deleted)
+	return updated, deleted
+}
+
+// newPushedRef 根据远程引用推送前后的hash判断操作类型以及是否快进，旧的提交在本地不存在时认为是强制更新
+func newPushedRef(r *git.Repository, name plumbing.ReferenceName, oldHash, newHash plumbing.Hash) PushedRef {
+	item := PushedRef{Name: name.String()}
+	switch {
+	case oldHash.IsZero():
+		item.Action = PushActionCreate
+		item.NewHash = newHash.String()
+	case newHash.IsZero():
+		item.Action = PushActionDelete
+		item.OldHash = oldHash.String()
+	default:
+		item.Action = PushActionUpdate
+		item.OldHash = oldHash.String()
+		item.NewHash = newHash.String()
+		item.FastForward, _ = isMerged(r, oldHash, newHash)
+		item.Forced = !item.FastForward
+	}
+	return item
+}
+
+// sortPushedRefs 按名称排序
+func sortPushedRefs(refs []PushedRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
 }
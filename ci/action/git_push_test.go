@@ -0,0 +1,608 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGitPushNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	var targetNodeType = "ci/gitPush"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitPushNode{}, types.Configuration{
+			"remoteName":     "origin",
+			"authType":       "token",
+			"authPassword":   "${vars.token}",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	push := func(t *testing.T, configuration types.Configuration, localDir string) {
+		tmp := t.TempDir()
+		remoteDir := filepath.Join(tmp, "remote.git")
+		remote, err := git.PlainInit(remoteDir, true)
+		assert.Nil(t, err)
+		local := initTestRepo(t, localDir)
+		head, _ := local.Head()
+		_, err = local.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{remoteDir}})
+		assert.Nil(t, err)
+
+		configuration["repository"] = remoteDir
+		configuration["refSpecs"] = "refs/heads/main:refs/heads/main"
+		configuration["authType"] = ""
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, localDir, outMsg.Metadata.GetValue(KeyWorkDir))
+		ref, err := remote.Reference(plumbing.Main, true)
+		assert.Nil(t, err)
+		if ref != nil {
+			assert.Equal(t, head.Hash(), ref.Hash())
+		}
+		var result PushResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, remoteDir, result.Remote)
+		assert.Equal(t, []PushedRef{{Name: "refs/heads/main", NewHash: head.Hash().String(), Action: PushActionCreate}}, result.Updated)
+	}
+
+	t.Run("AppendRepoName", func(t *testing.T) {
+		tmp := t.TempDir()
+		push(t, types.Configuration{"directory": tmp}, filepath.Join(tmp, "remote"))
+	})
+
+	t.Run("NotAppendRepoName", func(t *testing.T) {
+		tmp := t.TempDir()
+		push(t, types.Configuration{"directory": tmp, "appendRepoName": false}, tmp)
+	})
+
+	t.Run("RemoteName", func(t *testing.T) {
+		localDir := t.TempDir()
+		backupDir := filepath.Join(t.TempDir(), "backup.git")
+		backup, err := git.PlainInit(backupDir, true)
+		assert.Nil(t, err)
+		local := initTestRepo(t, localDir)
+		head, _ := local.Head()
+		_, err = local.CreateRemote(&config.RemoteConfig{Name: "backup", URLs: []string{backupDir}})
+		assert.Nil(t, err)
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      localDir,
+			"appendRepoName": false,
+			"remoteName":     "${metadata.remote}",
+			"refSpecs":       "refs/heads/main:refs/heads/main",
+			"authType":       "",
+		}, Registry)
+		assert.Nil(t, err)
+		metadata := types.NewMetadata()
+		metadata.PutValue("remote", "backup")
+		// 没有配置 Repository 时不使用元数据中的地址
+		metadata.PutValue(KeyGitHttpUrl, "https://github.com/rulego/rulego.git")
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		ref, err := backup.Reference(plumbing.Main, true)
+		assert.Nil(t, err)
+		assert.Equal(t, head.Hash(), ref.Hash())
+
+		metadata.PutValue("remote", "unknown")
+		_, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
+
+func TestGitPushNodeTags(t *testing.T) {
+	setup := func(t *testing.T) (string, *git.Repository, string, *git.Repository) {
+		localDir, local, remoteDir, remote := initPushTestRepos(t)
+		head, _ := local.Head()
+		signature := testSignature
+		_, err := local.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{Tagger: &signature, Message: "release"})
+		assert.Nil(t, err)
+		_, err = local.CreateTag("nightly", head.Hash(), nil)
+		assert.Nil(t, err)
+		return localDir, local, remoteDir, remote
+	}
+	push := func(t *testing.T, localDir, remoteDir string, configuration types.Configuration, metadata types.Metadata) PushResult {
+		outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, configuration, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result PushResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+	updatedNames := func(result PushResult) []string {
+		var names []string
+		for _, item := range result.Updated {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	t.Run("FollowTags", func(t *testing.T) {
+		localDir, _, remoteDir, remote := setup(t)
+		result := push(t, localDir, remoteDir, types.Configuration{"followTags": true}, types.NewMetadata())
+		// 只推送附注标签
+		assert.Equal(t, []string{"refs/heads/main", "refs/tags/v1.0.0"}, updatedNames(result))
+		_, err := remote.Tag("nightly")
+		assert.Equal(t, git.ErrTagNotFound, err)
+	})
+
+	t.Run("PushCreatedTag", func(t *testing.T) {
+		localDir, _, remoteDir, _ := setup(t)
+		metadata := types.NewMetadata()
+		metadata.PutValue(KeyNewTag, "nightly")
+		result := push(t, localDir, remoteDir, types.Configuration{"pushCreatedTag": true}, metadata)
+		assert.Equal(t, []string{"refs/heads/main", "refs/tags/nightly"}, updatedNames(result))
+	})
+
+	t.Run("PushAllTags", func(t *testing.T) {
+		localDir, local, remoteDir, _ := setup(t)
+		result := push(t, localDir, remoteDir, types.Configuration{"pushAllTags": true}, types.NewMetadata())
+		assert.Equal(t, []string{"refs/heads/main", "refs/tags/nightly", "refs/tags/v1.0.0"}, updatedNames(result))
+		tagRef, _ := local.Tag("v1.0.0")
+		assert.Equal(t, tagRef.Hash().String(), result.Updated[2].NewHash)
+	})
+}
+
+func TestGitPushNodeForce(t *testing.T) {
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	first, _ := local.Head()
+	second := commitTestFile(t, local, "a.txt", "a", "second")
+	_, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+
+	// 本地分支与远程分支分叉
+	w, _ := local.Worktree()
+	assert.Nil(t, w.Reset(&git.ResetOptions{Commit: first.Hash(), Mode: git.HardReset}))
+	amended := commitTestFile(t, local, "b.txt", "b", "amended")
+	remoteHash := func() plumbing.Hash {
+		ref, err := remote.Reference(plumbing.Main, true)
+		assert.Nil(t, err)
+		return ref.Hash()
+	}
+
+	_, relationType, err = pushTestNode(t, localDir, remoteDir, types.Configuration{}, types.NewMetadata())
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, strings.Contains(err.Error(), "force"))
+	assert.Equal(t, second, remoteHash())
+
+	// 远程引用不是期望的hash
+	metadata := types.NewMetadata()
+	metadata.PutValue(KeyRemoteHash, first.Hash().String())
+	configuration := types.Configuration{"force": true, "expectedRemoteHash": "${metadata.remoteHash}"}
+	_, relationType, err = pushTestNode(t, localDir, remoteDir, configuration, metadata)
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, errors.Is(err, ErrLeaseRejected))
+	assert.Equal(t, second, remoteHash())
+
+	metadata.PutValue(KeyRemoteHash, second.String())
+	outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, configuration, metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, amended, remoteHash())
+	var result PushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, []PushedRef{{Name: "refs/heads/main", OldHash: second.String(), NewHash: amended.String(), Action: PushActionUpdate, Forced: true}}, result.Updated)
+}
+
+func TestGitPushNodeUpToDate(t *testing.T) {
+	localDir, _, remoteDir, _ := initPushTestRepos(t)
+	outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+
+	outMsg, relationType, err = pushTestNode(t, localDir, remoteDir, types.Configuration{}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyUpToDate))
+	var result PushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, 0, len(result.Updated))
+
+	node := &GitPushNode{}
+	err = node.pushError(transport.ErrAuthenticationRequired, "origin")
+	assert.True(t, errors.Is(err, transport.ErrAuthenticationRequired))
+	assert.True(t, strings.Contains(err.Error(), "credentials"))
+}
+
+func TestGitPushNodePrune(t *testing.T) {
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	head, _ := local.Head()
+	for _, name := range []plumbing.ReferenceName{"refs/heads/feature", "refs/heads/old", "refs/tags/v1.0.0", "refs/tags/v0.9.0"} {
+		assert.Nil(t, local.Storer.SetReference(plumbing.NewHashReference(name, head.Hash())))
+	}
+	_, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{"refSpecs": "refs/heads/*:refs/heads/*,refs/tags/*:refs/tags/*"}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+
+	assert.Nil(t, local.Storer.RemoveReference("refs/heads/old"))
+	assert.Nil(t, local.Storer.RemoveReference("refs/tags/v0.9.0"))
+	newHead := commitTestFile(t, local, "a.txt", "a", "update main")
+	// 只推送和修剪分支，远程标签不受影响
+	outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{"refSpecs": "refs/heads/*:refs/heads/*", "prune": true}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result PushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, []PushedRef{{Name: "refs/heads/main", OldHash: head.Hash().String(), NewHash: newHead.String(), Action: PushActionUpdate, FastForward: true}}, result.Updated)
+	assert.Equal(t, []PushedRef{{Name: "refs/heads/old", OldHash: head.Hash().String(), Action: PushActionDelete}}, result.Deleted)
+	_, err = remote.Reference("refs/heads/old", false)
+	assert.Equal(t, plumbing.ErrReferenceNotFound, err)
+	_, err = remote.Reference("refs/heads/feature", false)
+	assert.Nil(t, err)
+	_, err = remote.Reference("refs/tags/v0.9.0", false)
+	assert.Nil(t, err)
+}
+
+func TestGitPushNodeCurrentBranch(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	localDir, local, _, remote := initPushTestRepos(t)
+	w, _ := local.Worktree()
+	assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+	head := commitTestFile(t, local, "a.txt", "a", "feature")
+
+	// 没有配置 Repository 和 RefSpecs，推送当前分支到 origin
+	node, err := test.CreateAndInitNode("ci/gitPush", types.Configuration{
+		"directory":      localDir,
+		"appendRepoName": false,
+		"authType":       "",
+	}, Registry)
+	assert.Nil(t, err)
+	outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "refs/heads/feature:refs/heads/feature", outMsg.Metadata.GetValue(KeyPushRefSpecs))
+	ref, err := remote.Reference("refs/heads/feature", false)
+	assert.Nil(t, err)
+	assert.Equal(t, head, ref.Hash())
+	_, err = remote.Reference(plumbing.Main, false)
+	assert.Equal(t, plumbing.ErrReferenceNotFound, err)
+
+	assert.Nil(t, w.Checkout(&git.CheckoutOptions{Hash: head}))
+	_, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, strings.Contains(err.Error(), "detached"))
+}
+
+func TestGitPushNodeDryRun(t *testing.T) {
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	first, _ := local.Head()
+	second := commitTestFile(t, local, "a.txt", "a", "second")
+	assert.Nil(t, local.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", second)))
+	_, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{"refSpecs": "refs/heads/*:refs/heads/*"}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+
+	// main 分叉，删除 feature，新建 next 分支和指向新提交的附注标签
+	w, _ := local.Worktree()
+	assert.Nil(t, w.Reset(&git.ResetOptions{Commit: first.Hash(), Mode: git.HardReset}))
+	amended := commitTestFile(t, local, "b.txt", "b", "amended")
+	assert.Nil(t, local.Storer.RemoveReference("refs/heads/feature"))
+	assert.Nil(t, local.Storer.SetReference(plumbing.NewHashReference("refs/heads/next", amended)))
+	signature := testSignature
+	tagRef, err := local.CreateTag("v1.0.0", amended, &git.CreateTagOptions{Tagger: &signature, Message: "release"})
+	assert.Nil(t, err)
+
+	push := func(configuration types.Configuration) PushResult {
+		configuration["refSpecs"] = "refs/heads/*:refs/heads/*"
+		configuration["prune"] = true
+		configuration["followTags"] = true
+		outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, configuration, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+		var result PushResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result
+	}
+	deleted := []PushedRef{{Name: "refs/heads/feature", OldHash: second.String(), Action: PushActionDelete}}
+
+	result := push(types.Configuration{"dryRun": true})
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []PushedRef{
+		{Name: "refs/heads/main", OldHash: second.String(), NewHash: amended.String(), Action: PushActionUpdate, Forced: true, Rejected: true},
+		{Name: "refs/heads/next", NewHash: amended.String(), Action: PushActionCreate},
+		{Name: "refs/tags/v1.0.0", NewHash: tagRef.Hash().String(), Action: PushActionCreate},
+	}, result.Updated)
+	assert.Equal(t, deleted, result.Deleted)
+
+	result = push(types.Configuration{"dryRun": true, "force": true})
+	planned := []PushedRef{
+		{Name: "refs/heads/main", OldHash: second.String(), NewHash: amended.String(), Action: PushActionUpdate, Forced: true},
+		{Name: "refs/heads/next", NewHash: amended.String(), Action: PushActionCreate},
+		{Name: "refs/tags/v1.0.0", NewHash: tagRef.Hash().String(), Action: PushActionCreate},
+	}
+	assert.Equal(t, planned, result.Updated)
+	assert.Equal(t, deleted, result.Deleted)
+	// 演练不修改远程仓库
+	ref, err := remote.Reference(plumbing.Main, false)
+	assert.Nil(t, err)
+	assert.Equal(t, second, ref.Hash())
+	_, err = remote.Reference("refs/heads/feature", false)
+	assert.Nil(t, err)
+
+	// 实际推送的结果与演练一致
+	result = push(types.Configuration{"force": true})
+	assert.False(t, result.DryRun)
+	assert.Equal(t, planned, result.Updated)
+	assert.Equal(t, deleted, result.Deleted)
+}
+
+func TestGitPushNodeRefSpecs(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	for _, refSpecs := range []string{"refs/heads/main", "refs/heads/*:refs/heads/main", "refs/heads/main:refs/heads/main, refs/heads/a:"} {
+		_, err := test.CreateAndInitNode("ci/gitPush", types.Configuration{"refSpecs": refSpecs}, Registry)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "invalid refSpec"))
+	}
+
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	head, _ := local.Head()
+	assert.Nil(t, local.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", head.Hash())))
+	// 去掉空白和空项
+	outMsg, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{"refSpecs": " refs/heads/main:refs/heads/main, refs/heads/feature:refs/heads/feature,"}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "refs/heads/main:refs/heads/main,refs/heads/feature:refs/heads/feature", outMsg.Metadata.GetValue(KeyPushRefSpecs))
+	_, err = remote.Reference("refs/heads/feature", false)
+	assert.Nil(t, err)
+
+	// 变量渲染后的refspec在处理消息时校验，不会访问远程仓库
+	metadata := types.NewMetadata()
+	metadata.PutValue("refSpec", "refs/heads/feature")
+	_, relationType, err = pushTestNode(t, localDir, filepath.Join(t.TempDir(), "not-exist.git"), types.Configuration{"refSpecs": "${metadata.refSpec}"}, metadata)
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, strings.Contains(err.Error(), "invalid refSpec refs/heads/feature"))
+
+	// 删除远程分支
+	outMsg, relationType, err = pushTestNode(t, localDir, remoteDir, types.Configuration{"refSpecs": ":refs/heads/feature"}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result PushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, []PushedRef{{Name: "refs/heads/feature", OldHash: head.Hash().String(), Action: PushActionDelete}}, result.Deleted)
+	_, err = remote.Reference("refs/heads/feature", false)
+	assert.Equal(t, plumbing.ErrReferenceNotFound, err)
+	_, err = remote.Reference(plumbing.Main, false)
+	assert.Nil(t, err)
+}
+
+// initPushTestRepos 创建本地仓库以及作为 origin 的本地裸仓库
+func TestGitPushNodeOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	commitTestFile(t, local, "a.txt", "a", "add a")
+	// 远程仓库默认不声明支持推送选项
+	_, relationType, err := pushTestNode(t, localDir, remoteDir, types.Configuration{"options": map[string]interface{}{"ci.skip": "true"}}, types.NewMetadata())
+	assert.True(t, errors.Is(err, ErrPushOptionsNotSupported))
+	assert.Equal(t, types.Failure, relationType)
+
+	cfg, err := remote.Config()
+	assert.Nil(t, err)
+	cfg.Raw.Section("receive").SetOption("advertisePushOptions", "true")
+	assert.Nil(t, remote.SetConfig(cfg))
+	// pre-receive 钩子记录收到的推送选项
+	hook := "#!/bin/sh\necho \"$GIT_PUSH_OPTION_COUNT $GIT_PUSH_OPTION_0\" > push-options\n"
+	assert.Nil(t, os.MkdirAll(filepath.Join(remoteDir, "hooks"), os.ModePerm))
+	assert.Nil(t, os.WriteFile(filepath.Join(remoteDir, "hooks", "pre-receive"), []byte(hook), 0755))
+
+	metadata := types.NewMetadata()
+	metadata.PutValue("skip", "true")
+	_, relationType, err = pushTestNode(t, localDir, remoteDir, types.Configuration{
+		"options": map[string]interface{}{"ci.skip": "${metadata.skip}"},
+		"atomic":  true,
+	}, metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	data, err := os.ReadFile(filepath.Join(remoteDir, "push-options"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1 ci.skip=true\n", string(data))
+
+	cfg.Raw.Section("receive").SetOption("advertiseAtomic", "false")
+	assert.Nil(t, remote.SetConfig(cfg))
+	commitTestFile(t, local, "b.txt", "b", "add b")
+	_, relationType, err = pushTestNode(t, localDir, remoteDir, types.Configuration{"atomic": true}, types.NewMetadata())
+	assert.True(t, errors.Is(err, ErrAtomicNotSupported))
+	assert.Equal(t, types.Failure, relationType)
+}
+
+func TestGitPushNodeMultipleRemotes(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	_, err := test.CreateAndInitNode("ci/gitPush", types.Configuration{"failOn": "some"}, Registry)
+	assert.NotNil(t, err)
+	_, err = test.CreateAndInitNode("ci/gitPush", types.Configuration{"remotes": []interface{}{map[string]interface{}{"authType": "unknown"}}}, Registry)
+	assert.NotNil(t, err)
+
+	localDir, local, remoteDir, _ := initPushTestRepos(t)
+	head := commitTestFile(t, local, "a.txt", "a", "add a")
+	backupDir := filepath.Join(t.TempDir(), "backup.git")
+	backup, err := git.PlainInit(backupDir, true)
+	assert.Nil(t, err)
+	missingDir := filepath.Join(t.TempDir(), "missing.git")
+
+	outMsg, relationType, err := pushTestNode(t, localDir, remoteDir+", "+backupDir, types.Configuration{}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result MultiPushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, 0, result.Failed)
+	assert.Equal(t, 2, len(result.Remotes))
+	assert.Equal(t, backupDir, result.Remotes[1].Remote)
+	assert.True(t, result.Remotes[1].Success)
+	assert.Equal(t, "refs/heads/main", result.Remotes[1].Updated[0].Name)
+	ref, err := backup.Reference(plumbing.NewBranchReferenceName("main"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, head, ref.Hash())
+	assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyUpToDate))
+
+	// 地址列表来自 msg.Data，节点的认证方式无法使用，只有覆盖了认证信息的远程仓库推送成功
+	push := func(failOn string) (types.RuleMsg, string, error) {
+		node, err := test.CreateAndInitNode("ci/gitPush", types.Configuration{
+			"directory":            localDir,
+			"appendRepoName":       false,
+			"refSpecs":             "refs/heads/main:refs/heads/main",
+			"authType":             "ssh",
+			"authPemFile":          filepath.Join(t.TempDir(), "id_rsa"),
+			"failOn":               failOn,
+			"repositoriesFromData": true,
+			"remotes":              []interface{}{map[string]interface{}{"repository": remoteDir, "authType": "none"}},
+		}, Registry)
+		assert.Nil(t, err)
+		data, _ := json.Marshal([]string{remoteDir, missingDir})
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), string(data)))
+	}
+	outMsg, relationType, err = push(FailOnAll)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	result = MultiPushResult{}
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, 1, result.Failed)
+	assert.True(t, result.Remotes[0].Success)
+	assert.Equal(t, 0, len(result.Remotes[0].Updated))
+	assert.False(t, result.Remotes[1].Success)
+	assert.Equal(t, missingDir, result.Remotes[1].Remote)
+	assert.True(t, result.Remotes[1].Error != "")
+
+	outMsg, relationType, err = push(FailOnAny)
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relationType)
+	result = MultiPushResult{}
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, 1, result.Failed)
+	assert.True(t, strings.Contains(err.Error(), missingDir))
+}
+
+func initPushTestRepos(t *testing.T) (string, *git.Repository, string, *git.Repository) {
+	localDir := t.TempDir()
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	remote, err := git.PlainInit(remoteDir, true)
+	assert.Nil(t, err)
+	local := initTestRepo(t, localDir)
+	_, err = local.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{remoteDir}})
+	assert.Nil(t, err)
+	return localDir, local, remoteDir, remote
+}
+
+// pushTestNode 把本地仓库推送到远程仓库，没有配置 refSpecs 时推送 main 分支
+func pushTestNode(t *testing.T, localDir, remoteDir string, configuration types.Configuration, metadata types.Metadata) (types.RuleMsg, string, error) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	configuration["directory"] = localDir
+	configuration["appendRepoName"] = false
+	configuration["repository"] = remoteDir
+	if _, ok := configuration["refSpecs"]; !ok {
+		configuration["refSpecs"] = "refs/heads/main:refs/heads/main"
+	}
+	configuration["authType"] = ""
+	node, err := test.CreateAndInitNode("ci/gitPush", configuration, Registry)
+	assert.Nil(t, err)
+	return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+}
+
+func TestGitPushNodeDataPayload(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitPushNode{})
+	payloads := map[bool]string{
+		// 上游节点输出的JSON数组不影响推送
+		false: `[{"repository":"https://github.com/rulego/rulego.git","workDir":"/tmp/rulego"}]`,
+		// 不是仓库地址的数组时推送到 origin
+		true: `["src/main.go","README.md"]`,
+	}
+	for repositoriesFromData, data := range payloads {
+		localDir, local, _, remote := initPushTestRepos(t)
+		head, _ := local.Head()
+		node, err := test.CreateAndInitNode("ci/gitPush", types.Configuration{
+			"directory":            localDir,
+			"appendRepoName":       false,
+			"refSpecs":             "refs/heads/main:refs/heads/main",
+			"authType":             "",
+			"repositoriesFromData": repositoriesFromData,
+		}, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), data))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result PushResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		assert.Equal(t, "refs/heads/main", result.Updated[0].Name)
+		ref, err := remote.Reference(plumbing.NewBranchReferenceName("main"), false)
+		assert.Nil(t, err)
+		assert.Equal(t, head.Hash(), ref.Hash())
+	}
+}
+
+// listFailTransport 包装本地仓库的传输协议，第 failAt 次列出引用时失败
+type listFailTransport struct {
+	transport.Transport
+	failAt int
+	count  int
+}
+
+func (t *listFailTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	t.count++
+	if t.count == t.failAt {
+		return nil, errors.New("connection reset by peer")
+	}
+	return t.Transport.NewUploadPackSession(ep, auth)
+}
+
+func TestGitPushNodeListAfterPushFailure(t *testing.T) {
+	localDir, local, remoteDir, remote := initPushTestRepos(t)
+	// 推送前列出引用成功，推送后列出引用失败
+	client.InstallProtocol("listfail", &listFailTransport{Transport: file.DefaultClient, failAt: 2})
+	defer client.InstallProtocol("listfail", nil)
+	outMsg, relationType, err := pushTestNode(t, localDir, "listfail://"+filepath.ToSlash(remoteDir), types.Configuration{}, types.NewMetadata())
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result PushResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.True(t, result.Planned)
+	head, _ := local.Head()
+	assert.Equal(t, 1, len(result.Updated))
+	assert.Equal(t, head.Hash().String(), result.Updated[0].NewHash)
+	assert.Equal(t, PushActionCreate, result.Updated[0].Action)
+	ref, err := remote.Reference(plumbing.NewBranchReferenceName("main"), true)
+	assert.Nil(t, err)
+	assert.Equal(t, head.Hash(), ref.Hash())
+}
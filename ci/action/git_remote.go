@@ -0,0 +1,239 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitRemoteNode{})
+}
+
+const (
+	// RemoteActionAdd 添加远程仓库
+	RemoteActionAdd = "add"
+	// RemoteActionRemove 删除远程仓库
+	RemoteActionRemove = "remove"
+	// RemoteActionSetUrl 修改远程仓库地址
+	RemoteActionSetUrl = "set-url"
+	// RemoteActionList 列出所有远程仓库
+	RemoteActionList = "list"
+)
+
+// GitRemoteNodeConfiguration 节点配置
+type GitRemoteNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 add、remove、set-url 或 list，默认list
+	Action string
+	// 远程仓库名称，例如：backup
+	Name string
+	// 远程仓库地址，多个与逗号隔开，拉取和推送都使用这些地址，add 和 set-url 时使用
+	Urls string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// RemoteInfo 远程仓库信息
+type RemoteInfo struct {
+	// 名称
+	Name string `json:"name"`
+	// 地址，拉取和推送都使用这些地址
+	Urls []string `json:"urls"`
+	// 拉取时使用的 refspec
+	Fetch []string `json:"fetch"`
+}
+
+// GitRemoteNode 管理仓库的远程仓库配置，可以添加、删除远程仓库和修改地址，list 以JSON数组的形式把所有远程仓库写入 msg.Data
+// 可以与 GitPushNode 的 RemoteName 配合，把代码推送到镜像仓库
+type GitRemoteNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitRemoteNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitRemoteNode) Type() string {
+	return "ci/gitRemote"
+}
+
+func (x *GitRemoteNode) New() types.Node {
+	return &GitRemoteNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitRemoteNodeConfiguration{
+			Action:         RemoteActionList,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitRemoteNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = RemoteActionList
+	case RemoteActionAdd, RemoteActionSetUrl:
+		if strings.TrimSpace(x.Config.Urls) == "" {
+			return errors.New("urls can not be empty")
+		}
+		fallthrough
+	case RemoteActionRemove:
+		if strings.TrimSpace(x.Config.Name) == "" {
+			return errors.New("name can not be empty")
+		}
+	case RemoteActionList:
+	default:
+		return fmt.Errorf("unsupported remote action: %s", x.Config.Action)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Name) || str.CheckHasVar(x.Config.Urls) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitRemoteNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	name := x.getValue(x.Config.Name, evn)
+	urls := splitUrls(x.getValue(x.Config.Urls, evn))
+	switch x.Config.Action {
+	case RemoteActionAdd:
+		_, err = r.CreateRemote(&config.RemoteConfig{Name: name, URLs: urls})
+	case RemoteActionRemove:
+		err = r.DeleteRemote(name)
+	case RemoteActionSetUrl:
+		err = x.setUrls(r, name, urls)
+	default:
+		var data []byte
+		if data, err = x.list(r); err == nil {
+			msg.DataType = types.JSON
+			msg.Data = string(data)
+		}
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitRemoteNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// setUrls 修改远程仓库地址
+func (x *GitRemoteNode) setUrls(r *git.Repository, name string, urls []string) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	remote, ok := cfg.Remotes[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", git.ErrRemoteNotFound, name)
+	}
+	remote.URLs = urls
+	if err = remote.Validate(); err != nil {
+		return err
+	}
+	return r.SetConfig(cfg)
+}
+
+// list 以JSON数组的形式返回所有远程仓库，按名称排序
+func (x *GitRemoteNode) list(r *git.Repository) ([]byte, error) {
+	infos, err := listRemotes(r)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(infos)
+}
+
+// listRemotes 返回所有远程仓库，按名称排序
+func listRemotes(r *git.Repository) ([]RemoteInfo, error) {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RemoteInfo, 0, len(remotes))
+	for _, remote := range remotes {
+		cfg := remote.Config()
+		info := RemoteInfo{Name: cfg.Name, Urls: cfg.URLs, Fetch: make([]string, 0, len(cfg.Fetch))}
+		for _, refSpec := range cfg.Fetch {
+			info.Fetch = append(info.Fetch, refSpec.String())
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+func (x *GitRemoteNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// splitUrls 拆分逗号分隔的地址
+func splitUrls(value string) []string {
+	var urls []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			urls = append(urls, item)
+		}
+	}
+	return urls
+}
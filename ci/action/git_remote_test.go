@@ -0,0 +1,110 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestGitRemoteNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitRemoteNode{})
+	var targetNodeType = "ci/gitRemote"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitRemoteNode{}, types.Configuration{
+			"action":         "list",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		for _, config := range []types.Configuration{
+			{"action": "rename", "name": "backup"},
+			{"action": "add", "name": "backup"},
+			{"action": "set-url", "urls": "https://example.com/a.git"},
+			{"action": "remove"},
+		} {
+			_, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+			assert.NotNil(t, err)
+		}
+	})
+
+	dir := t.TempDir()
+	initTestRepo(t, dir)
+	remote := func(t *testing.T, config types.Configuration) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+	list := func(t *testing.T) []RemoteInfo {
+		outMsg, relationType, err := remote(t, types.Configuration{"action": "list"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var remotes []RemoteInfo
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &remotes))
+		return remotes
+	}
+
+	assert.Equal(t, 0, len(list(t)))
+
+	for _, name := range []string{"origin", "backup"} {
+		_, relationType, err := remote(t, types.Configuration{
+			"action": "add",
+			"name":   name,
+			"urls":   "https://example.com/" + name + ".git",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+	}
+	assert.Equal(t, []RemoteInfo{
+		{Name: "backup", Urls: []string{"https://example.com/backup.git"}, Fetch: []string{"+refs/heads/*:refs/remotes/backup/*"}},
+		{Name: "origin", Urls: []string{"https://example.com/origin.git"}, Fetch: []string{"+refs/heads/*:refs/remotes/origin/*"}},
+	}, list(t))
+
+	// 重复添加
+	_, relationType, err := remote(t, types.Configuration{"action": "add", "name": "backup", "urls": "https://example.com/a.git"})
+	assert.Equal(t, git.ErrRemoteExists, err)
+	assert.Equal(t, types.Failure, relationType)
+
+	_, relationType, err = remote(t, types.Configuration{
+		"action": "set-url",
+		"name":   "backup",
+		"urls":   "https://mirror1.example.com/backup.git, https://mirror2.example.com/backup.git",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, []string{"https://mirror1.example.com/backup.git", "https://mirror2.example.com/backup.git"}, list(t)[0].Urls)
+
+	_, relationType, err = remote(t, types.Configuration{"action": "set-url", "name": "unknown", "urls": "https://example.com/a.git"})
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relationType)
+
+	_, relationType, err = remote(t, types.Configuration{"action": "remove", "name": "backup"})
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	remotes := list(t)
+	assert.Equal(t, 1, len(remotes))
+	assert.Equal(t, "origin", remotes[0].Name)
+}
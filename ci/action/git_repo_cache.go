@@ -0,0 +1,152 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"sync"
+	"time"
+)
+
+// RepositoryCacheTTL 仓库缓存的保留时间，超过该时间没有访问的仓库会被清理
+var RepositoryCacheTTL = time.Minute * 5
+
+// RepositoryCacheSize 最多缓存的仓库数量，超过时清理最久没有访问的仓库
+var RepositoryCacheSize = 16
+
+// plainOpen 打开本地仓库
+var plainOpen = git.PlainOpen
+
+// cachedRepository 缓存的本地仓库
+type cachedRepository struct {
+	repository *git.Repository
+	accessTime time.Time
+}
+
+// repositoryCache 按工作目录缓存打开的本地仓库，同一个规则链中的节点复用，避免每个节点重新打开仓库和读取索引
+// 缓存的仓库只在持有工作目录锁时使用，同一时间只有一个操作访问
+var repositoryCache = struct {
+	sync.Mutex
+	items map[string]*cachedRepository
+}{items: make(map[string]*cachedRepository)}
+
+// getCachedRepository 根据工作目录锁的key获取缓存的仓库
+func getCachedRepository(key string) (*git.Repository, bool) {
+	repositoryCache.Lock()
+	defer repositoryCache.Unlock()
+	item, ok := repositoryCache.items[key]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.Sub(item.accessTime) > RepositoryCacheTTL {
+		delete(repositoryCache.items, key)
+		return nil, false
+	}
+	item.accessTime = now
+	return item.repository, true
+}
+
+// putCachedRepository 缓存仓库，同时清理过期的仓库，超过 RepositoryCacheSize 时清理最久没有访问的仓库
+func putCachedRepository(key string, r *git.Repository) {
+	repositoryCache.Lock()
+	defer repositoryCache.Unlock()
+	now := time.Now()
+	for k, item := range repositoryCache.items {
+		if now.Sub(item.accessTime) > RepositoryCacheTTL {
+			delete(repositoryCache.items, k)
+		}
+	}
+	repositoryCache.items[key] = &cachedRepository{repository: r, accessTime: now}
+	for len(repositoryCache.items) > RepositoryCacheSize {
+		var oldestKey string
+		var oldest time.Time
+		for k, item := range repositoryCache.items {
+			if oldestKey == "" || item.accessTime.Before(oldest) {
+				oldestKey, oldest = k, item.accessTime
+			}
+		}
+		delete(repositoryCache.items, oldestKey)
+	}
+}
+
+// removeCachedRepository 删除缓存的仓库
+func removeCachedRepository(key string) {
+	repositoryCache.Lock()
+	defer repositoryCache.Unlock()
+	delete(repositoryCache.items, key)
+}
+
+// repositoryKeys 节点缓存过的仓库，节点销毁时从缓存中删除
+type repositoryKeys struct {
+	sync.Mutex
+	items map[string]struct{}
+}
+
+// openLockedRepository 打开仓库，优先使用缓存的仓库，调用者必须已经通过 lockWorkDir 锁定工作目录
+// 没有持有锁的节点使用 openRepository，每次重新打开仓库
+func (x *baseGitNode) openLockedRepository(msg types.RuleMsg, workDir string) (*git.Repository, error) {
+	if msg.Metadata.GetValue(x.metaKey(KeyRepoId)) != "" {
+		return x.openRepository(msg, workDir)
+	}
+	if !x.Config.DisableRepoCache {
+		if r, ok := getCachedRepository(workDirLockKey("", workDir)); ok {
+			return r, nil
+		}
+	}
+	r, err := plainOpen(workDir)
+	if err != nil {
+		return nil, err
+	}
+	x.cacheRepository(workDir, r)
+	return r, nil
+}
+
+// cacheRepository 缓存持有工作目录锁时打开或者克隆的仓库
+// 禁用缓存时删除其他节点缓存的仓库，因为当前节点可能通过另外打开的仓库修改了对象库
+func (x *baseGitNode) cacheRepository(workDir string, r *git.Repository) {
+	key := workDirLockKey("", workDir)
+	if x.Config.DisableRepoCache {
+		removeCachedRepository(key)
+		return
+	}
+	putCachedRepository(key, r)
+	if x.repoKeys != nil {
+		x.repoKeys.Lock()
+		x.repoKeys.items[key] = struct{}{}
+		x.repoKeys.Unlock()
+	}
+}
+
+// invalidateRepository 删除缓存的仓库，用于删除工作目录或者重写对象库的操作之后
+func (x *baseGitNode) invalidateRepository(workDir string) {
+	removeCachedRepository(workDirLockKey("", workDir))
+}
+
+// releaseRepositories 删除节点缓存过的仓库
+func (x *baseGitNode) releaseRepositories() {
+	if x.repoKeys == nil {
+		return
+	}
+	x.repoKeys.Lock()
+	defer x.repoKeys.Unlock()
+	for key := range x.repoKeys.items {
+		removeCachedRepository(key)
+	}
+	x.repoKeys.items = make(map[string]struct{})
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// countOpens 统计测试期间打开本地仓库的次数
+func countOpens(t *testing.T) *int32 {
+	var opens int32
+	open := plainOpen
+	plainOpen = func(path string) (*git.Repository, error) {
+		atomic.AddInt32(&opens, 1)
+		return open(path)
+	}
+	t.Cleanup(func() {
+		plainOpen = open
+	})
+	return &opens
+}
+
+// runCacheChain 依次执行 clone → commit → createTag → push，返回执行的节点和工作目录
+func runCacheChain(t *testing.T, disableRepoCache bool) ([]types.Node, string) {
+	tmp := t.TempDir()
+	initTestRepo(t, filepath.Join(tmp, "source"))
+	remoteDir := filepath.Join(tmp, "remote.git")
+	_, err := git.PlainInit(remoteDir, true)
+	assert.Nil(t, err)
+	workDir := filepath.Join(tmp, "work")
+
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitCloneNode{})
+	Registry.Add(&GitCommitNode{})
+	Registry.Add(&GitCreateTagNode{})
+	Registry.Add(&GitPushNode{})
+	chain := []struct {
+		nodeType      string
+		configuration types.Configuration
+	}{
+		{"ci/gitClone", types.Configuration{"repository": filepath.Join(tmp, "source"), "directory": workDir, "appendRepoName": false, "authType": ""}},
+		{"ci/gitCommit", types.Configuration{"pattern": "*", "message": "update", "signature": map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}}},
+		{"ci/gitCreateTag", types.Configuration{"tag": "v1.0.0", "annotated": false}},
+		{"ci/gitPush", types.Configuration{"repository": remoteDir, "appendRepoName": false, "refSpecs": "refs/heads/main:refs/heads/main,refs/tags/*:refs/tags/*", "authType": ""}},
+	}
+	msg := types.NewMsg(0, "test", types.JSON, types.NewMetadata(), "")
+	var nodes []types.Node
+	for i, item := range chain {
+		item.configuration["disableRepoCache"] = disableRepoCache
+		node, err := test.CreateAndInitNode(item.nodeType, item.configuration, Registry)
+		assert.Nil(t, err)
+		nodes = append(nodes, node)
+		if i == 1 {
+			assert.Nil(t, os.WriteFile(filepath.Join(workDir, "update.txt"), []byte("update"), 0644))
+		}
+		var relationType string
+		msg, relationType, err = onMsgSync(node, msg)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+	}
+	remote, err := git.PlainOpen(remoteDir)
+	assert.Nil(t, err)
+	_, err = remote.Tag("v1.0.0")
+	assert.Nil(t, err)
+	return nodes, workDir
+}
+
+func TestRepositoryCache(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		opens := countOpens(t)
+		_, workDir := runCacheChain(t, true)
+		// commit、createTag、push 每个节点都重新打开仓库
+		assert.Equal(t, int32(3), atomic.LoadInt32(opens))
+		_, ok := getCachedRepository(workDirLockKey("", workDir))
+		assert.False(t, ok)
+	})
+	t.Run("Enabled", func(t *testing.T) {
+		opens := countOpens(t)
+		nodes, workDir := runCacheChain(t, false)
+		// 后续节点复用克隆的仓库
+		assert.Equal(t, int32(0), atomic.LoadInt32(opens))
+		_, ok := getCachedRepository(workDirLockKey("", workDir))
+		assert.True(t, ok)
+		// 节点销毁后删除缓存的仓库
+		for _, node := range nodes {
+			node.Destroy()
+		}
+		_, ok = getCachedRepository(workDirLockKey("", workDir))
+		assert.False(t, ok)
+	})
+}
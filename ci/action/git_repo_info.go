@@ -0,0 +1,258 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitRepoInfoNode{})
+}
+
+const (
+	// KeyHeadHash HEAD指向的提交hash
+	KeyHeadHash = "headHash"
+	// KeyIsDirty 工作区是否有未提交的修改
+	KeyIsDirty = "isDirty"
+)
+
+// GitRepoInfoNodeConfiguration 节点配置
+type GitRepoInfoNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 最多统计的提交数，默认10000，0表示不限制，用于避免遍历很长的历史
+	MaxCommits int
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// RepoInfo 仓库概况
+type RepoInfo struct {
+	// 当前分支，分离HEAD时为空
+	Branch string `json:"branch"`
+	// HEAD指向的提交hash，空仓库为空
+	HeadHash string `json:"headHash"`
+	// 是否处于分离HEAD状态
+	Detached bool `json:"detached"`
+	// 本地分支数
+	Branches int `json:"branches"`
+	// 标签数
+	Tags int `json:"tags"`
+	// HEAD可达的提交数
+	Commits int `json:"commits"`
+	// 提交数是否达到 MaxCommits 上限
+	CommitsTruncated bool `json:"commitsTruncated"`
+	// 远程仓库
+	Remotes []RemoteInfo `json:"remotes"`
+	// .git 目录占用的磁盘空间，单位字节，内存仓库为0
+	Size int64 `json:"size"`
+	// 是否为裸仓库
+	IsBare bool `json:"isBare"`
+	// 工作区是否有未提交的修改，未跟踪的文件不计算在内
+	IsDirty bool `json:"isDirty"`
+}
+
+// GitRepoInfoNode 汇总仓库的当前分支、分支和标签数量、提交数、远程仓库、磁盘占用以及工作区是否有修改，以JSON的形式写入 msg.Data
+// 同时把当前分支、HEAD提交hash和工作区是否有修改写入元数据 branch、headHash、isDirty，方便路由
+type GitRepoInfoNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitRepoInfoNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitRepoInfoNode) Type() string {
+	return "ci/gitRepoInfo"
+}
+
+func (x *GitRepoInfoNode) New() types.Node {
+	return &GitRepoInfoNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitRepoInfoNodeConfiguration{
+			MaxCommits:     10000,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitRepoInfoNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) {
+		x.hasVar = true
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitRepoInfoNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	info, err := x.repoInfo(r)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyBranch), info.Branch)
+	msg.Metadata.PutValue(x.metaKey(KeyHeadHash), info.HeadHash)
+	msg.Metadata.PutValue(x.metaKey(KeyIsDirty), strconv.FormatBool(info.IsDirty))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitRepoInfoNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// repoInfo 汇总仓库信息
+func (x *GitRepoInfoNode) repoInfo(r *git.Repository) (RepoInfo, error) {
+	var info RepoInfo
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return info, err
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		info.Branch = head.Target().Short()
+	} else {
+		info.Detached = true
+	}
+	if resolved, err := r.Head(); err == nil {
+		info.HeadHash = resolved.Hash().String()
+		if info.Commits, info.CommitsTruncated, err = x.countCommits(r, resolved.Hash()); err != nil {
+			return info, err
+		}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return info, err
+	}
+	refs, err := r.References()
+	if err != nil {
+		return info, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name().IsBranch() {
+			info.Branches++
+		} else if ref.Name().IsTag() {
+			info.Tags++
+		}
+		return nil
+	})
+	refs.Close()
+	if err != nil {
+		return info, err
+	}
+	if info.Remotes, err = listRemotes(r); err != nil {
+		return info, err
+	}
+	if s, ok := r.Storer.(*filesystem.Storage); ok {
+		if info.Size, err = dirSize(s.Filesystem().Root()); err != nil {
+			return info, err
+		}
+	}
+	w, err := r.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		info.IsBare = true
+		return info, nil
+	} else if err != nil {
+		return info, err
+	}
+	if info.HeadHash != "" {
+		if info.IsDirty, err = isDirtyWorktree(w); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// countCommits 统计 hash 可达的提交数，达到 MaxCommits 时停止
+func (x *GitRepoInfoNode) countCommits(r *git.Repository, hash plumbing.Hash) (int, bool, error) {
+	iter, err := r.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return 0, false, err
+	}
+	defer iter.Close()
+	count := 0
+	truncated := false
+	err = iter.ForEach(func(c *object.Commit) error {
+		if x.Config.MaxCommits > 0 && count >= x.Config.MaxCommits {
+			truncated = true
+			return storer.ErrStop
+		}
+		count++
+		return nil
+	})
+	return count, truncated, err
+}
+
+// dirSize 统计目录中所有文件的大小，不跟随符号链接
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
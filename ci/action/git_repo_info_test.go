@@ -0,0 +1,130 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitRepoInfoNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitRepoInfoNode{})
+	var targetNodeType = "ci/gitRepoInfo"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitRepoInfoNode{}, types.Configuration{
+			"maxCommits":     10000,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	repoInfo := func(t *testing.T, dir string, config types.Configuration) (RepoInfo, types.RuleMsg) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var info RepoInfo
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &info))
+		return info, outMsg
+	}
+
+	t.Run("RepoInfo", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		commitTestFile(t, r, "a.txt", "a", "add a")
+		head := commitTestFile(t, r, "b.txt", "b", "add b")
+		assert.Nil(t, r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("dev"), head)))
+		_, err := r.CreateTag("v1.0.0", head, nil)
+		assert.Nil(t, err)
+		_, err = r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"https://github.com/rulego/rulego.git"}})
+		assert.Nil(t, err)
+
+		info, outMsg := repoInfo(t, dir, types.Configuration{})
+		assert.Equal(t, "main", info.Branch)
+		assert.Equal(t, head.String(), info.HeadHash)
+		assert.False(t, info.Detached)
+		assert.Equal(t, 2, info.Branches)
+		assert.Equal(t, 1, info.Tags)
+		assert.Equal(t, 3, info.Commits)
+		assert.False(t, info.CommitsTruncated)
+		assert.Equal(t, 1, len(info.Remotes))
+		assert.Equal(t, "https://github.com/rulego/rulego.git", info.Remotes[0].Urls[0])
+		assert.True(t, info.Size > 0)
+		assert.False(t, info.IsDirty)
+		assert.Equal(t, "main", outMsg.Metadata.GetValue(KeyBranch))
+		assert.Equal(t, head.String(), outMsg.Metadata.GetValue(KeyHeadHash))
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyIsDirty))
+
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0644))
+		info, outMsg = repoInfo(t, dir, types.Configuration{"maxCommits": 2})
+		assert.Equal(t, 2, info.Commits)
+		assert.True(t, info.CommitsTruncated)
+		assert.True(t, info.IsDirty)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyIsDirty))
+	})
+
+	t.Run("Detached", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		w, _ := r.Worktree()
+		assert.Nil(t, w.Checkout(&git.CheckoutOptions{Hash: head.Hash()}))
+		info, outMsg := repoInfo(t, dir, types.Configuration{})
+		assert.True(t, info.Detached)
+		assert.Equal(t, "", info.Branch)
+		assert.Equal(t, "", outMsg.Metadata.GetValue(KeyBranch))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{InitOptions: git.InitOptions{DefaultBranch: plumbing.Main}})
+		assert.Nil(t, err)
+		info, _ := repoInfo(t, dir, types.Configuration{})
+		assert.Equal(t, "main", info.Branch)
+		assert.Equal(t, "", info.HeadHash)
+		assert.Equal(t, 0, info.Commits)
+		assert.Equal(t, 0, len(info.Remotes))
+	})
+
+	t.Run("Bare", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := git.PlainInit(dir, true)
+		assert.Nil(t, err)
+		info, _ := repoInfo(t, dir, types.Configuration{})
+		assert.True(t, info.IsBare)
+	})
+
+	t.Run("NotRepository", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"directory": t.TempDir(), "appendRepoName": false}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitResetNode{})
+}
+
+const (
+	// KeyOldHash 操作前HEAD的提交hash
+	KeyOldHash = "oldHash"
+	// KeyNewHash 操作后HEAD的提交hash
+	KeyNewHash = "newHash"
+)
+
+const (
+	// ResetModeSoft 只移动HEAD，保留暂存区和工作区
+	ResetModeSoft = "soft"
+	// ResetModeMixed 移动HEAD并重置暂存区，保留工作区
+	ResetModeMixed = "mixed"
+	// ResetModeHard 移动HEAD并重置暂存区和工作区
+	ResetModeHard = "hard"
+)
+
+// GitResetNodeConfiguration 节点配置
+type GitResetNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 重置模式，可以是 soft、mixed 或 hard，默认mixed
+	Mode string
+	// 重置到的引用，可以是分支、标签、提交hash或者远程分支，例如：origin/main，默认HEAD
+	Ref string
+	// hard模式下是否同时删除未跟踪的文件和目录
+	Clean bool
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitResetNode 重置工作区到指定引用，用于流水线步骤破坏了工作区后恢复，不需要重新克隆
+// 重置前后HEAD的提交hash写入元数据 oldHash 和 newHash
+type GitResetNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitResetNodeConfiguration
+	mode   git.ResetMode
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitResetNode) Type() string {
+	return "ci/gitReset"
+}
+
+func (x *GitResetNode) New() types.Node {
+	return &GitResetNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitResetNodeConfiguration{
+			Mode:           ResetModeMixed,
+			Ref:            string(plumbing.HEAD),
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitResetNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(x.Config.Mode)) {
+	case ResetModeSoft:
+		x.mode = git.SoftReset
+	case ResetModeMixed, "":
+		x.mode = git.MixedReset
+	case ResetModeHard:
+		x.mode = git.HardReset
+	default:
+		return fmt.Errorf("unsupported reset mode: %s", x.Config.Mode)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitResetNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		ctx.TellFailure(msg, fmt.Errorf("%s is not a git repository: %w", workDir, err))
+		return
+	} else if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	head, err := r.Head()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.Config.Ref
+	if evn != nil {
+		ref = str.ExecuteTemplate(ref, evn)
+	}
+	if ref = strings.TrimSpace(ref); ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	target, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("reference %s not found: %w", ref, err))
+		return
+	}
+	if err = w.Reset(&git.ResetOptions{Commit: *target, Mode: x.mode}); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.Clean && x.mode == git.HardReset {
+		if err = w.Clean(&git.CleanOptions{Dir: true}); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyOldHash), head.Hash().String())
+	msg.Metadata.PutValue(x.metaKey(KeyNewHash), target.String())
+	msg.Metadata.PutValue(x.metaKey(KeyCommitHash), target.String())
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitResetNode) Destroy() {
+	x.releaseRepositories()
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitResetNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitResetNode{})
+	var targetNodeType = "ci/gitReset"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitResetNode{}, types.Configuration{
+			"mode":           "mixed",
+			"ref":            "HEAD",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"mode": "keep",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	reset := func(t *testing.T, dir string, config types.Configuration) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+
+	t.Run("Hard", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		first := commitTestFile(t, r, "a.txt", "a", "add a")
+		second := commitTestFile(t, r, "a.txt", "aa", "modify a")
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("broken"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "tmp.txt"), []byte("tmp"), 0644))
+
+		outMsg, relationType, err := reset(t, dir, types.Configuration{
+			"mode":  "hard",
+			"ref":   "HEAD~1",
+			"clean": true,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, second.String(), outMsg.Metadata.GetValue(KeyOldHash))
+		assert.Equal(t, first.String(), outMsg.Metadata.GetValue(KeyNewHash))
+		data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+		assert.Equal(t, "a", string(data))
+		data, _ = os.ReadFile(filepath.Join(dir, "README.md"))
+		assert.Equal(t, "# test", string(data))
+		_, err = os.Stat(filepath.Join(dir, "tmp.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Soft", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		first := commitTestFile(t, r, "a.txt", "a", "add a")
+		commitTestFile(t, r, "a.txt", "aa", "modify a")
+
+		outMsg, relationType, err := reset(t, dir, types.Configuration{
+			"mode": "soft",
+			"ref":  first.String(),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, first.String(), outMsg.Metadata.GetValue(KeyNewHash))
+		head, _ := r.Head()
+		assert.Equal(t, first, head.Hash())
+		data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+		assert.Equal(t, "aa", string(data))
+		w, _ := r.Worktree()
+		status, _ := w.Status()
+		assert.False(t, status.IsClean())
+	})
+
+	t.Run("NotRepository", func(t *testing.T) {
+		_, relationType, err := reset(t, t.TempDir(), types.Configuration{})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("UnknownRef", func(t *testing.T) {
+		dir := t.TempDir()
+		initTestRepo(t, dir)
+		_, relationType, err := reset(t, dir, types.Configuration{
+			"ref": "origin/unknown",
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitRevParseNode{})
+}
+
+const (
+	// KeyCommitTime 提交时间，RFC3339格式
+	KeyCommitTime = "commitTime"
+	// KeyCommitSubject 提交信息的第一行
+	KeyCommitSubject = "commitSubject"
+)
+
+// GitRevParseNodeConfiguration 节点配置
+type GitRevParseNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 修订版本表达式，支持分支、标签、HEAD~N、完整或者缩写的提交hash等，例如：origin/main、v1.4.0^{}、${metadata.ref}
+	Rev string
+}
+
+// RevParseResult 解析结果
+type RevParseResult struct {
+	// 原始表达式
+	Rev string `json:"rev"`
+	// 提交hash
+	CommitHash string `json:"commitHash"`
+	// 提交短hash
+	ShortHash string `json:"shortHash"`
+	// 提交时间
+	CommitTime time.Time `json:"commitTime"`
+	// 提交信息的第一行
+	Subject string `json:"subject"`
+}
+
+// GitRevParseNode 把修订版本表达式解析为提交，附注标签会解析到其指向的提交
+// 提交hash、短hash、提交时间和提交信息的第一行写入元数据 commitHash、shortHash、commitTime 和 commitSubject，同时以JSON的形式写入 msg.Data
+type GitRevParseNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitRevParseNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitRevParseNode) Type() string {
+	return "ci/gitRevParse"
+}
+
+func (x *GitRevParseNode) New() types.Node {
+	return &GitRevParseNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitRevParseNodeConfiguration{
+			Rev:            "HEAD",
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitRevParseNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Rev) {
+		x.hasVar = true
+	}
+	if err == nil && strings.TrimSpace(x.Config.Rev) == "" {
+		err = errors.New("rev can not be empty")
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitRevParseNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	rev := x.Config.Rev
+	if evn != nil {
+		rev = str.ExecuteTemplate(rev, evn)
+	}
+	rev = strings.TrimSpace(rev)
+	commit, err := resolveCommit(r, rev)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	hash := commit.Hash.String()
+	result := RevParseResult{
+		Rev:        rev,
+		CommitHash: hash,
+		ShortHash:  hash[:7],
+		CommitTime: commit.Committer.When,
+		Subject:    strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0],
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyCommitHash), result.CommitHash)
+	msg.Metadata.PutValue(x.metaKey(KeyShortHash), result.ShortHash)
+	msg.Metadata.PutValue(x.metaKey(KeyCommitTime), result.CommitTime.Format(time.RFC3339))
+	msg.Metadata.PutValue(x.metaKey(KeyCommitSubject), result.Subject)
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitRevParseNode) Destroy() {
+}
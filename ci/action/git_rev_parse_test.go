@@ -0,0 +1,98 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func TestGitRevParseNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitRevParseNode{})
+	var targetNodeType = "ci/gitRevParse"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitRevParseNode{}, types.Configuration{
+			"rev":            "HEAD",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"rev": " "}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	first := commitTestFile(t, r, "a.txt", "a", "add a\n\ndetails")
+	second := commitTestFile(t, r, "b.txt", "b", "add b")
+	third := commitTestFile(t, r, "c.txt", "c", "add c")
+	_, err := r.CreateTag("v1.4.0", second, &git.CreateTagOptions{Tagger: &testSignature, Message: "release"})
+	assert.Nil(t, err)
+	_, err = r.CreateTag("v1.3.0", first, nil)
+	assert.Nil(t, err)
+
+	revParse := func(t *testing.T, rev string) (types.RuleMsg, string, error) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      dir,
+			"appendRepoName": false,
+			"rev":            "${metadata.rev}",
+		}, Registry)
+		assert.Nil(t, err)
+		metadata := types.NewMetadata()
+		metadata.PutValue("rev", rev)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+	}
+
+	for rev, expected := range map[string]string{
+		"main":              third.String(),
+		"HEAD~2":            first.String(),
+		"v1.4.0":            second.String(),
+		"v1.4.0^{}":         second.String(),
+		"refs/tags/v1.3.0":  first.String(),
+		second.String():     second.String(),
+		second.String()[:7]: second.String(),
+	} {
+		outMsg, relationType, err := revParse(t, rev)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, expected, outMsg.Metadata.GetValue(KeyCommitHash))
+		assert.Equal(t, expected[:7], outMsg.Metadata.GetValue(KeyShortHash))
+	}
+
+	outMsg, _, err := revParse(t, "HEAD~2")
+	assert.Nil(t, err)
+	assert.Equal(t, "add a", outMsg.Metadata.GetValue(KeyCommitSubject))
+	assert.True(t, outMsg.Metadata.GetValue(KeyCommitTime) != "")
+	var result RevParseResult
+	assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+	assert.Equal(t, "HEAD~2", result.Rev)
+	assert.Equal(t, first.String(), result.CommitHash)
+	assert.Equal(t, "add a", result.Subject)
+
+	_, relationType, err := revParse(t, "origin/unknown")
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relationType)
+	assert.True(t, strings.Contains(err.Error(), "origin/unknown"))
+}
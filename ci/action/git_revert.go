@@ -0,0 +1,232 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitRevertNode{})
+}
+
+// ErrRevertMergeCommit 撤销合并提交时没有指定主线父提交
+var ErrRevertMergeCommit = errors.New("commit is a merge but no mainlineParent was given")
+
+// GitRevertNodeConfiguration 节点配置
+type GitRevertNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 需要撤销的提交，可以是提交hash、分支或者标签，为空则使用元数据commitHash
+	CommitHash string
+	// 撤销合并提交时作为主线的父提交序号，从1开始，与 git revert -m 一致，0表示不是合并提交
+	MainlineParent int
+	// 撤销提交的注释消息，为空则使用：Revert "{原提交标题}"
+	Message string
+	//签名
+	Signature Signature
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitRevertNode 在当前分支创建撤销指定提交的新提交，新提交的hash写入元数据 hash 和 commitHash，可以接着使用 GitPushNode 推送
+// 撤销的变更与工作区冲突时恢复当前分支，并把冲突的文件列表写入 msg.Data 后发送到Failure链
+type GitRevertNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitRevertNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitRevertNode) Type() string {
+	return "ci/gitRevert"
+}
+
+func (x *GitRevertNode) New() types.Node {
+	return &GitRevertNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitRevertNodeConfiguration{
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitRevertNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.CommitHash) || str.CheckHasVar(x.Config.Message) ||
+		str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) {
+		x.hasVar = true
+	}
+	if err == nil && x.Config.MainlineParent < 0 {
+		err = errors.New("mainlineParent can not be negative")
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitRevertNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if dirty, err := isDirtyWorktree(w); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	} else if dirty {
+		ctx.TellFailure(msg, ErrDirtyWorktree)
+		return
+	}
+	commitHash := x.getValue(x.Config.CommitHash, evn)
+	if commitHash == "" {
+		commitHash = msg.Metadata.GetValue(x.metaKey(KeyCommitHash))
+	}
+	if commitHash == "" {
+		ctx.TellFailure(msg, errors.New("commitHash can not be empty"))
+		return
+	}
+	commit, err := resolveCommit(r, commitHash)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	err = x.revert(r, w, msg, commit, evn)
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		if data, jsonErr := json.Marshal(MergeConflict{Conflicts: conflictErr.Files}); jsonErr == nil {
+			msg.DataType = types.JSON
+			msg.Data = string(data)
+		}
+		ctx.TellFailure(msg, err)
+		return
+	} else if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if _, err = x.putHeadMetadata(r, msg); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitRevertNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// revert 把提交相对父提交的变更反向应用到工作区并提交，新提交的hash写入元数据hash
+func (x *GitRevertNode) revert(r *git.Repository, w *git.Worktree, msg types.RuleMsg, commit *object.Commit, evn map[string]interface{}) error {
+	parent, err := x.getParent(commit)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	commitTree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	var parentTree *object.Tree
+	if parent != nil {
+		if parentTree, err = parent.Tree(); err != nil {
+			return err
+		}
+	}
+	if err = applyTreeChanges(w, commitTree, parentTree); err != nil {
+		_ = w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset})
+		return err
+	}
+	message := x.getValue(x.Config.Message, evn)
+	if message == "" {
+		subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+		message = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject, commit.Hash)
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  x.getValue(x.Config.Signature.AuthorName, evn),
+			Email: x.getValue(x.Config.Signature.AuthorEmail, evn),
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		_ = w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset})
+		return err
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyHash), hash.String())
+	return nil
+}
+
+// getParent 获取撤销时作为基准的父提交，根提交返回nil
+func (x *GitRevertNode) getParent(commit *object.Commit) (*object.Commit, error) {
+	mainline := x.Config.MainlineParent
+	switch {
+	case commit.NumParents() > 1 && mainline == 0:
+		return nil, fmt.Errorf("%w: %s", ErrRevertMergeCommit, commit.Hash)
+	case commit.NumParents() <= 1 && mainline > 0:
+		return nil, fmt.Errorf("mainlineParent was specified but commit %s is not a merge", commit.Hash)
+	case mainline > commit.NumParents():
+		return nil, fmt.Errorf("commit %s does not have parent %d", commit.Hash, mainline)
+	case commit.NumParents() == 0:
+		return nil, nil
+	case mainline == 0:
+		mainline = 1
+	}
+	return commit.Parent(mainline - 1)
+}
+
+func (x *GitRevertNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitRevertNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitRevertNode{})
+	var targetNodeType = "ci/gitRevert"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitRevertNode{}, types.Configuration{
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	revert := func(t *testing.T, dir string, config types.Configuration, metadata types.Metadata) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		config["signature"] = map[string]interface{}{"authorName": "rulego", "authorEmail": "rulego@rulego.cc"}
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+	}
+	readFile := func(name string) string {
+		data, _ := os.ReadFile(name)
+		return string(data)
+	}
+
+	t.Run("Revert", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		commitTestFile(t, r, "conf/app.yaml", "v1", "add config")
+		bad := commitTestFile(t, r, "conf/app.yaml", "v2", "bump config\n\ndetails")
+		commitTestFile(t, r, "b.txt", "b", "add b")
+
+		metadata := types.NewMetadata()
+		metadata.PutValue(KeyCommitHash, bad.String())
+		outMsg, relationType, err := revert(t, dir, types.Configuration{}, metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "v1", readFile(filepath.Join(dir, "conf", "app.yaml")))
+		assert.Equal(t, "b", readFile(filepath.Join(dir, "b.txt")))
+		head, _ := r.Head()
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyHash))
+		assert.Equal(t, head.Hash().String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		commit, _ := r.CommitObject(head.Hash())
+		assert.Equal(t, "Revert \"bump config\"\n\nThis reverts commit "+bad.String()+".", commit.Message)
+		w, _ := r.Worktree()
+		status, _ := w.Status()
+		assert.True(t, status.IsClean())
+
+		_, _, err = revert(t, dir, types.Configuration{"commitHash": "HEAD", "message": "undo"}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, "v2", readFile(filepath.Join(dir, "conf", "app.yaml")))
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		bad := commitTestFile(t, r, "a.txt", "v1", "add a")
+		commitTestFile(t, r, "a.txt", "v2", "modify a")
+		head, _ := r.Head()
+
+		outMsg, relationType, err := revert(t, dir, types.Configuration{"commitHash": bad.String()}, types.NewMetadata())
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		var conflict MergeConflict
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &conflict))
+		assert.Equal(t, []string{"a.txt"}, conflict.Conflicts)
+		current, _ := r.Head()
+		assert.Equal(t, head.Hash(), current.Hash())
+	})
+
+	t.Run("MergeCommit", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		w, _ := r.Worktree()
+		assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("develop"), Create: true}))
+		developHash := commitTestFile(t, r, "d.txt", "d", "develop change")
+		assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.Main}))
+		mainHash := commitTestFile(t, r, "m.txt", "m", "main change")
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "d.txt"), []byte("d"), 0644))
+		_, err := w.Add("d.txt")
+		assert.Nil(t, err)
+		merge, err := w.Commit("Merge develop", &git.CommitOptions{
+			Author:  &testSignature,
+			Parents: []plumbing.Hash{mainHash, developHash},
+		})
+		assert.Nil(t, err)
+
+		_, relationType, err := revert(t, dir, types.Configuration{"commitHash": merge.String()}, types.NewMetadata())
+		assert.True(t, errors.Is(err, ErrRevertMergeCommit))
+		assert.Equal(t, types.Failure, relationType)
+
+		_, relationType, err = revert(t, dir, types.Configuration{"commitHash": merge.String(), "mainlineParent": 1}, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		_, err = os.Stat(filepath.Join(dir, "d.txt"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "m.txt"))
+		assert.Nil(t, err)
+	})
+}
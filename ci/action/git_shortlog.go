@@ -0,0 +1,275 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitShortlogNode{})
+}
+
+const (
+	// ShortlogGroupByAuthor 按作者统计
+	ShortlogGroupByAuthor = "author"
+	// ShortlogGroupByCommitter 按提交者统计
+	ShortlogGroupByCommitter = "committer"
+)
+
+// GitShortlogNodeConfiguration 节点配置
+type GitShortlogNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 开始遍历的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 开始时间(包含)，支持RFC3339、2006-01-02 15:04:05、2006-01-02、Unix时间戳(秒)或者相对当前时间的时长，例如：168h 表示7天前，为空则不限制
+	StartTime string
+	// 结束时间(包含)，格式与 StartTime 相同，为空则不限制
+	EndTime string
+	// 分组方式，可以是 author 或 committer，默认author
+	GroupBy string
+	// 是否按邮箱合并同一个人的不同显示名称，默认true，false则按名称和邮箱分组
+	MergeByEmail bool
+	// 是否统计增加和删除的行数，需要计算每个提交的差异，耗时较长
+	WithStats bool
+}
+
+// ShortlogEntry 贡献者统计
+type ShortlogEntry struct {
+	// 名称，按邮箱合并时使用最近一次提交的名称
+	Name string `json:"name"`
+	// 邮箱
+	Email string `json:"email"`
+	// 提交数
+	Commits int `json:"commits"`
+	// 增加的行数，WithStats 为true时统计
+	Additions int `json:"additions"`
+	// 删除的行数，WithStats 为true时统计
+	Deletions int `json:"deletions"`
+}
+
+// GitShortlogNode 统计一段时间内每个贡献者的提交数，与 git shortlog -sne 类似，用于生成周报等
+// 结果按提交数从多到少以JSON数组的形式写入 msg.Data
+type GitShortlogNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitShortlogNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitShortlogNode) Type() string {
+	return "ci/gitShortlog"
+}
+
+func (x *GitShortlogNode) New() types.Node {
+	return &GitShortlogNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitShortlogNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			GroupBy:        ShortlogGroupByAuthor,
+			MergeByEmail:   true,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitShortlogNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.GroupBy = strings.ToLower(strings.TrimSpace(x.Config.GroupBy))
+	switch x.Config.GroupBy {
+	case "":
+		x.Config.GroupBy = ShortlogGroupByAuthor
+	case ShortlogGroupByAuthor, ShortlogGroupByCommitter:
+	default:
+		return fmt.Errorf("unsupported groupBy: %s", x.Config.GroupBy)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.StartTime) || str.CheckHasVar(x.Config.EndTime) {
+		x.hasVar = true
+	} else {
+		if _, err = parseTimeValue(x.Config.StartTime); err != nil {
+			return err
+		}
+		if _, err = parseTimeValue(x.Config.EndTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitShortlogNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	startTime, err := parseTimeValue(x.getValue(x.Config.StartTime, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	endTime, err := parseTimeValue(x.getValue(x.Config.EndTime, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	entries, err := x.shortlog(r, commit, startTime, endTime)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitShortlogNode) Destroy() {
+}
+
+// shortlog 从 commit 开始遍历，按贡献者统计
+func (x *GitShortlogNode) shortlog(r *git.Repository, commit *object.Commit, startTime, endTime time.Time) ([]ShortlogEntry, error) {
+	options := &git.LogOptions{From: commit.Hash}
+	if !startTime.IsZero() {
+		options.Since = &startTime
+	}
+	if !endTime.IsZero() {
+		options.Until = &endTime
+	}
+	iter, err := r.Log(options)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	entries := make([]ShortlogEntry, 0)
+	index := make(map[string]int)
+	err = iter.ForEach(func(c *object.Commit) error {
+		signature := c.Author
+		if x.Config.GroupBy == ShortlogGroupByCommitter {
+			signature = c.Committer
+		}
+		key := strings.ToLower(signature.Email)
+		if !x.Config.MergeByEmail || key == "" {
+			key = signature.Name + "\x00" + key
+		}
+		i, ok := index[key]
+		if !ok {
+			// 按时间从新到旧遍历，第一次出现的即为最近一次提交的名称
+			i = len(entries)
+			index[key] = i
+			entries = append(entries, ShortlogEntry{Name: signature.Name, Email: signature.Email})
+		}
+		entries[i].Commits++
+		if x.Config.WithStats {
+			stats, err := c.Stats()
+			if err != nil {
+				return err
+			}
+			for _, stat := range stats {
+				entries[i].Additions += stat.Addition
+				entries[i].Deletions += stat.Deletion
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Commits != entries[j].Commits {
+			return entries[i].Commits > entries[j].Commits
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+func (x *GitShortlogNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// parseTimeValue 解析时间，支持RFC3339、2006-01-02 15:04:05、2006-01-02、Unix时间戳(秒)或者相对当前时间的时长
+// 不带时区的时间使用本地时区，为空返回零值
+func parseTimeValue(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			d = -d
+		}
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitShortlogNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitShortlogNode{})
+	var targetNodeType = "ci/gitShortlog"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitShortlogNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"groupBy":        ShortlogGroupByAuthor,
+			"mergeByEmail":   true,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"groupBy": "reviewer"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"startTime": "yesterday"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	w, err := r.Worktree()
+	assert.Nil(t, err)
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	commitAs := func(name, email string, when time.Time, file, content string) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+		_, err := w.Add(file)
+		assert.Nil(t, err)
+		author := &object.Signature{Name: name, Email: email, When: when}
+		committer := &object.Signature{Name: "bot", Email: "bot@rulego.cc", When: when}
+		_, err = w.Commit("update "+file, &git.CommitOptions{Author: author, Committer: committer})
+		assert.Nil(t, err)
+	}
+	commitAs("Alice", "alice@rulego.cc", base, "a.txt", "1\n2\n")
+	commitAs("Bob", "bob@rulego.cc", base.Add(24*time.Hour), "b.txt", "1\n")
+	commitAs("alice", "Alice@rulego.cc", base.Add(48*time.Hour), "a.txt", "1\n3\n4\n")
+	commitAs("Alice Liddell", "alice@rulego.cc", base.Add(72*time.Hour), "c.txt", "1\n")
+
+	shortlog := func(t *testing.T, config types.Configuration) []ShortlogEntry {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var entries []ShortlogEntry
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &entries))
+		return entries
+	}
+
+	t.Run("MergeByEmail", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"startTime": "2024-06-01", "endTime": "2024-06-30"})
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, "Alice Liddell", entries[0].Name)
+		assert.Equal(t, 3, entries[0].Commits)
+		assert.Equal(t, 0, entries[0].Additions)
+		assert.Equal(t, "Bob", entries[1].Name)
+		assert.Equal(t, 1, entries[1].Commits)
+	})
+
+	t.Run("ByNameAndEmail", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"startTime": "2024-06-01", "endTime": "2024-06-30", "mergeByEmail": false})
+		assert.Equal(t, 4, len(entries))
+	})
+
+	t.Run("TimeRange", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"startTime": "2024-06-02T00:00:00Z", "endTime": "2024-06-03 23:59:59"})
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, 1, entries[0].Commits)
+		assert.Equal(t, 1, entries[1].Commits)
+	})
+
+	t.Run("WithStats", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"startTime": "2024-06-01T00:00:00Z", "endTime": "2024-06-30", "withStats": true})
+		// a.txt: +2, 然后 -1 +2；c.txt: +1
+		assert.Equal(t, 5, entries[0].Additions)
+		assert.Equal(t, 1, entries[0].Deletions)
+		assert.Equal(t, 1, entries[1].Additions)
+	})
+
+	t.Run("Committer", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"startTime": "1717200000", "endTime": "1719705600", "groupBy": ShortlogGroupByCommitter})
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, "bot", entries[0].Name)
+		assert.Equal(t, 4, entries[0].Commits)
+	})
+
+	t.Run("Ref", func(t *testing.T) {
+		entries := shortlog(t, types.Configuration{"ref": "HEAD~3"})
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, "rulego", entries[1].Name)
+	})
+}
+
+func TestParseTimeValue(t *testing.T) {
+	v, err := parseTimeValue("")
+	assert.Nil(t, err)
+	assert.True(t, v.IsZero())
+	v, err = parseTimeValue("2024-06-01T08:00:00+08:00")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1717200000), v.Unix())
+	v, err = parseTimeValue("1717200000")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1717200000), v.Unix())
+	v, err = parseTimeValue("24h")
+	assert.Nil(t, err)
+	assert.True(t, time.Since(v) >= 24*time.Hour)
+	_, err = parseTimeValue("next week")
+	assert.NotNil(t, err)
+}
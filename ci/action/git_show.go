@@ -0,0 +1,230 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitShowNode{})
+}
+
+const (
+	// KeyBlobHash 文件内容的 blob hash
+	KeyBlobHash = "blobHash"
+	// KeyBlobSize 文件大小，单位字节
+	KeyBlobSize = "blobSize"
+	// KeyFileMode 文件模式，例如：0100644
+	KeyFileMode = "fileMode"
+)
+
+// GitShowNodeConfiguration 节点配置
+type GitShowNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 读取的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 文件路径，相对仓库根目录，为空则使用 msg.Data
+	FilePath string
+	// 输出的数据类型，可以是 JSON、TEXT 或 BINARY(base64编码)，为空则根据文件内容自动选择 TEXT 或 BINARY
+	DataType string
+	// 允许读取的最大文件大小，单位字节，默认10MB，0表示不限制
+	MaxSize int64
+}
+
+// GitShowNode 读取指定引用下的文件内容，不需要检出该引用，文件内容写入 msg.Data
+// 文件的 blob hash、大小和模式写入元数据 blobHash、blobSize、fileMode
+// 文件不存在、超过最大大小或者内容不是合法的JSON(DataType为JSON时)发送到Failure链
+type GitShowNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitShowNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitShowNode) Type() string {
+	return "ci/gitShow"
+}
+
+func (x *GitShowNode) New() types.Node {
+	return &GitShowNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitShowNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			MaxSize:        10 * 1024 * 1024,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitShowNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.DataType = strings.ToUpper(strings.TrimSpace(x.Config.DataType))
+	switch types.DataType(x.Config.DataType) {
+	case "", types.JSON, types.TEXT, types.BINARY:
+	default:
+		return fmt.Errorf("unsupported data type: %s", x.Config.DataType)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.FilePath) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitShowNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	filePath := x.getValue(x.Config.FilePath, evn)
+	if filePath == "" {
+		filePath = strings.TrimSpace(msg.Data)
+	}
+	filePath = strings.Trim(strings.TrimPrefix(strings.ReplaceAll(filePath, "\\", "/"), "./"), "/")
+	if filePath == "" {
+		ctx.TellFailure(msg, errors.New("filePath can not be empty"))
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	entry, err := tree.FindEntry(filePath)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		ctx.TellFailure(msg, fmt.Errorf("file %s does not exist at %s", filePath, ref))
+		return
+	} else if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if !entry.Mode.IsFile() {
+		ctx.TellFailure(msg, fmt.Errorf("%s at %s is not a file", filePath, ref))
+		return
+	}
+	blob, err := r.BlobObject(entry.Hash)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.MaxSize > 0 && blob.Size > x.Config.MaxSize {
+		ctx.TellFailure(msg, fmt.Errorf("file %s at %s is too large: %d bytes, limit %d bytes", filePath, ref, blob.Size, x.Config.MaxSize))
+		return
+	}
+	content, err := readBlob(blob)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	dataType := types.DataType(x.Config.DataType)
+	if dataType == "" {
+		dataType = types.TEXT
+		if isBinaryContent(content) {
+			dataType = types.BINARY
+		}
+	}
+	switch dataType {
+	case types.BINARY:
+		msg.Data = base64.StdEncoding.EncodeToString(content)
+	case types.JSON:
+		if !json.Valid(content) {
+			ctx.TellFailure(msg, fmt.Errorf("file %s at %s is not valid JSON", filePath, ref))
+			return
+		}
+		msg.Data = string(content)
+	default:
+		msg.Data = string(content)
+	}
+	msg.DataType = dataType
+	msg.Metadata.PutValue(x.metaKey(KeyBlobHash), blob.Hash.String())
+	msg.Metadata.PutValue(x.metaKey(KeyBlobSize), strconv.FormatInt(blob.Size, 10))
+	msg.Metadata.PutValue(x.metaKey(KeyFileMode), entry.Mode.String())
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitShowNode) Destroy() {
+}
+
+func (x *GitShowNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// readBlob 读取 blob 的全部内容
+func readBlob(blob *object.Blob) ([]byte, error) {
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// isBinaryContent 前8000个字节包含0则认为是二进制内容，与 object.File.IsBinary 一致
+func isBinaryContent(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	for _, b := range content {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/base64"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func TestGitShowNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitShowNode{})
+	var targetNodeType = "ci/gitShow"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitShowNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"maxSize":        int64(10 * 1024 * 1024),
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"dataType": "xml"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	v1 := commitTestFile(t, r, "conf/app.json", `{"version":1}`, "add config")
+	commitTestFile(t, r, "conf/app.json", `{"version":2}`, "bump config")
+	commitTestFile(t, r, "bin/logo.png", "\x89PNG\x00\x01\x02", "add logo")
+
+	show := func(t *testing.T, config types.Configuration, metadata types.Metadata, data string) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.TEXT, metadata, data))
+	}
+
+	t.Run("Head", func(t *testing.T) {
+		outMsg, relationType, err := show(t, types.Configuration{"filePath": "conf/app.json"}, types.NewMetadata(), "")
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, `{"version":2}`, outMsg.Data)
+		assert.Equal(t, types.TEXT, outMsg.DataType)
+		assert.Equal(t, "13", outMsg.Metadata.GetValue(KeyBlobSize))
+		assert.Equal(t, "0100644", outMsg.Metadata.GetValue(KeyFileMode))
+		assert.Equal(t, 40, len(outMsg.Metadata.GetValue(KeyBlobHash)))
+	})
+
+	t.Run("TemplatedRef", func(t *testing.T) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("rev", v1.String())
+		metadata.PutValue("file", "conf/app.json")
+		outMsg, _, err := show(t, types.Configuration{"ref": "${metadata.rev}", "filePath": "${metadata.file}", "dataType": "json"}, metadata, "")
+		assert.Nil(t, err)
+		assert.Equal(t, `{"version":1}`, outMsg.Data)
+		assert.Equal(t, types.JSON, outMsg.DataType)
+	})
+
+	t.Run("FilePathFromData", func(t *testing.T) {
+		outMsg, _, err := show(t, types.Configuration{"ref": "main~1"}, types.NewMetadata(), "./conf/app.json")
+		assert.Nil(t, err)
+		assert.Equal(t, `{"version":2}`, outMsg.Data)
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		outMsg, _, err := show(t, types.Configuration{"filePath": "bin/logo.png"}, types.NewMetadata(), "")
+		assert.Nil(t, err)
+		assert.Equal(t, types.BINARY, outMsg.DataType)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x89PNG\x00\x01\x02")), outMsg.Data)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		_, relationType, err := show(t, types.Configuration{"filePath": "bin/logo.png", "maxSize": 4}, types.NewMetadata(), "")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("InvalidJson", func(t *testing.T) {
+		_, relationType, err := show(t, types.Configuration{"filePath": "README.md", "dataType": "JSON"}, types.NewMetadata(), "")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, relationType, err := show(t, types.Configuration{"filePath": "bin/logo.png", "ref": v1.String()}, types.NewMetadata(), "")
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		assert.True(t, strings.Contains(err.Error(), "bin/logo.png"))
+		assert.True(t, strings.Contains(err.Error(), v1.String()))
+
+		_, _, err = show(t, types.Configuration{"filePath": "conf"}, types.NewMetadata(), "")
+		assert.NotNil(t, err)
+		_, _, err = show(t, types.Configuration{"filePath": "conf/app.json", "ref": "missing"}, types.NewMetadata(), "")
+		assert.NotNil(t, err)
+	})
+}
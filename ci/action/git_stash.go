@@ -0,0 +1,417 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitStashNode{})
+}
+
+const (
+	// KeyStashId 贮藏ID，save 时写入，pop 时默认读取
+	KeyStashId = "stashId"
+	// KeyStashed 是否贮藏了修改，工作区没有修改时为false
+	KeyStashed = "stashed"
+)
+
+const (
+	// StashActionSave 贮藏工作区的修改并恢复工作区
+	StashActionSave = "save"
+	// StashActionPop 恢复贮藏的修改并删除贮藏
+	StashActionPop = "pop"
+	// StashActionList 列出所有贮藏
+	StashActionList = "list"
+)
+
+// StashRefPrefix 贮藏引用前缀，每个贮藏是该前缀下的一个引用，指向保存了修改的提交
+const StashRefPrefix = "refs/stashes/"
+
+// ErrStashNotFound 贮藏不存在
+var ErrStashNotFound = errors.New("stash not found")
+
+// GitStashNodeConfiguration 节点配置
+type GitStashNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 save、pop 或 list，默认save
+	Action string
+	// 贮藏ID，pop时使用，为空则使用元数据stashId
+	StashId string
+	// 贮藏的描述信息
+	Message string
+	//签名，作者名称或者邮箱为空时使用仓库配置的 user.name 和 user.email
+	Signature Signature
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// StashInfo 贮藏信息
+type StashInfo struct {
+	// 贮藏ID
+	Id string `json:"id"`
+	// 保存修改的提交hash
+	Hash string `json:"hash"`
+	// 贮藏时HEAD的提交hash
+	Base string `json:"base"`
+	// 描述信息
+	Message string `json:"message"`
+	// 贮藏时间
+	When time.Time `json:"when"`
+}
+
+// GitStashNode 贮藏和恢复工作区的修改，go-git 没有实现 stash，这里把修改和未跟踪的文件保存为 refs/stashes/{stashId} 指向的提交
+// save 贮藏修改并恢复工作区，贮藏ID写入元数据 stashId，以便同一条规则链后面的节点 pop
+// pop 把贮藏的修改应用到工作区并删除贮藏，工作区中的文件与贮藏时和贮藏的内容都不一致时视为冲突，把冲突的文件列表写入 msg.Data 后发送到Failure链
+// list 以JSON数组的形式把所有贮藏写入 msg.Data
+type GitStashNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitStashNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitStashNode) Type() string {
+	return "ci/gitStash"
+}
+
+func (x *GitStashNode) New() types.Node {
+	return &GitStashNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitStashNodeConfiguration{
+			Action:         StashActionSave,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitStashNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = StashActionSave
+	case StashActionSave, StashActionPop, StashActionList:
+	default:
+		return fmt.Errorf("unsupported stash action: %s", x.Config.Action)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.StashId) || str.CheckHasVar(x.Config.Message) ||
+		str.CheckHasVar(x.Config.Signature.AuthorName) || str.CheckHasVar(x.Config.Signature.AuthorEmail) ||
+		str.CheckHasVar(x.Config.Signature.CommitterName) || str.CheckHasVar(x.Config.Signature.CommitterEmail) || str.CheckHasVar(x.Config.Signature.When) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitStashNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	switch x.Config.Action {
+	case StashActionPop:
+		stashId := x.getValue(x.Config.StashId, evn)
+		if stashId == "" {
+			stashId = msg.Metadata.GetValue(x.metaKey(KeyStashId))
+		}
+		err = x.pop(r, stashId)
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			if data, jsonErr := json.Marshal(MergeConflict{Conflicts: conflictErr.Files}); jsonErr == nil {
+				msg.DataType = types.JSON
+				msg.Data = string(data)
+			}
+		}
+	case StashActionList:
+		var stashes []StashInfo
+		if stashes, err = x.list(r); err == nil {
+			var data []byte
+			if data, err = json.Marshal(stashes); err == nil {
+				msg.DataType = types.JSON
+				msg.Data = string(data)
+			}
+		}
+	default:
+		var stashId string
+		if stashId, err = x.save(r, msg, evn); err == nil {
+			msg.Metadata.PutValue(x.metaKey(KeyStashId), stashId)
+			msg.Metadata.PutValue(x.metaKey(KeyStashed), strconv.FormatBool(stashId != ""))
+		}
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitStashNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// save 把修改和未跟踪的文件提交到贮藏引用，然后把工作区恢复到HEAD，工作区没有修改时返回空的贮藏ID
+func (x *GitStashNode) save(r *git.Repository, msg types.RuleMsg, evn map[string]interface{}) (string, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return "", err
+	}
+	if status.IsClean() {
+		return "", nil
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	stashId := msg.Id
+	if stashId == "" {
+		stashId = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	message := x.getValue(x.Config.Message, evn)
+	if message == "" {
+		message = fmt.Sprintf("stash %s on %s", stashId, head.Hash())
+	}
+	author, committer, err := getSignatures(r, x.Config.Signature, evn)
+	if err != nil {
+		return "", err
+	}
+	if err = w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return "", err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+	treeHash, err := writeIndexTree(r.Storer, idx)
+	if err != nil {
+		return "", err
+	}
+	// 直接写入提交对象，不移动当前分支
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash()},
+	}
+	obj := r.Storer.NewEncodedObject()
+	if err = commit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	if err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(StashRefPrefix+stashId), hash)); err != nil {
+		return "", err
+	}
+	if err = w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset}); err != nil {
+		return "", err
+	}
+	if err = w.Clean(&git.CleanOptions{Dir: true}); err != nil {
+		return "", err
+	}
+	return stashId, nil
+}
+
+// writeIndexTree 把索引中的文件写入树对象，返回根目录树的hash
+func writeIndexTree(s storer.EncodedObjectStorer, idx *index.Index) (plumbing.Hash, error) {
+	trees := map[string]*object.Tree{"": {}}
+	var addDir func(dir string) *object.Tree
+	addDir = func(dir string) *object.Tree {
+		if tree, ok := trees[dir]; ok {
+			return tree
+		}
+		tree := &object.Tree{}
+		trees[dir] = tree
+		parent, name := path.Split(dir)
+		parentTree := addDir(strings.TrimSuffix(parent, "/"))
+		parentTree.Entries = append(parentTree.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir})
+		return tree
+	}
+	for _, entry := range idx.Entries {
+		dir, name := path.Split(entry.Name)
+		tree := addDir(strings.TrimSuffix(dir, "/"))
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: entry.Mode, Hash: entry.Hash})
+	}
+	return writeTree(s, trees, "")
+}
+
+// writeTree 先写入子目录的树对象，再按 git 的顺序写入目录的树对象
+func writeTree(s storer.EncodedObjectStorer, trees map[string]*object.Tree, dir string) (plumbing.Hash, error) {
+	tree := trees[dir]
+	for i, entry := range tree.Entries {
+		if entry.Mode != filemode.Dir {
+			continue
+		}
+		hash, err := writeTree(s, trees, path.Join(dir, entry.Name))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries[i].Hash = hash
+	}
+	// 目录按名称加 / 排序
+	sortName := func(entry object.TreeEntry) string {
+		if entry.Mode == filemode.Dir {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortName(tree.Entries[i]) < sortName(tree.Entries[j])
+	})
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// pop 把贮藏的修改应用到工作区并删除贮藏，贮藏ID为空表示 save 时没有修改，不做任何处理
+func (x *GitStashNode) pop(r *git.Repository, stashId string) error {
+	if stashId == "" {
+		return nil
+	}
+	refName := plumbing.ReferenceName(StashRefPrefix + stashId)
+	ref, err := r.Reference(refName, false)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return fmt.Errorf("%w: %s", ErrStashNotFound, stashId)
+	} else if err != nil {
+		return err
+	}
+	stash, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+	parent, err := stash.Parent(0)
+	if err != nil {
+		return err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return err
+	}
+	stashTree, err := stash.Tree()
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err = applyTreeChanges(w, parentTree, stashTree); err != nil {
+		return err
+	}
+	// 应用变更时会暂存文件，取消暂存，保持与贮藏前一样只修改工作区
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	if err = w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.MixedReset}); err != nil {
+		return err
+	}
+	return r.Storer.RemoveReference(refName)
+}
+
+// list 列出所有贮藏，最新的在前
+func (x *GitStashNode) list(r *git.Repository) ([]StashInfo, error) {
+	refs, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+	stashes := make([]StashInfo, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Name().String(), StashRefPrefix) {
+			return nil
+		}
+		stash, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		info := StashInfo{
+			Id:      strings.TrimPrefix(ref.Name().String(), StashRefPrefix),
+			Hash:    ref.Hash().String(),
+			Message: stash.Message,
+			When:    stash.Author.When,
+		}
+		if len(stash.ParentHashes) > 0 {
+			info.Base = stash.ParentHashes[0].String()
+		}
+		stashes = append(stashes, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stashes, func(i, j int) bool {
+		return stashes[i].When.After(stashes[j].When)
+	})
+	return stashes, nil
+}
+
+func (x *GitStashNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitStashNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitStashNode{})
+	var targetNodeType = "ci/gitStash"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitStashNode{}, types.Configuration{
+			"action":         "save",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"action": "drop",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	stash := func(t *testing.T, dir, action string, metadata types.Metadata) (types.RuleMsg, string, error) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      dir,
+			"appendRepoName": false,
+			"action":         action,
+			"signature": types.Configuration{
+				"authorName":  "rulego",
+				"authorEmail": "rulego@rulego.cc",
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+	}
+
+	t.Run("SaveAndPop", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		commitTestFile(t, r, "a.txt", "a", "add a")
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a2"), 0644))
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "dist"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "dist", "app.js"), []byte("app"), 0644))
+
+		outMsg, relationType, err := stash(t, dir, StashActionSave, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyStashed))
+		stashId := outMsg.Metadata.GetValue(KeyStashId)
+		assert.True(t, stashId != "")
+		w, _ := r.Worktree()
+		status, _ := w.Status()
+		assert.True(t, status.IsClean())
+		_, err = os.Stat(filepath.Join(dir, "dist", "app.js"))
+		assert.True(t, os.IsNotExist(err))
+
+		listMsg, _, err := stash(t, dir, StashActionList, types.NewMetadata())
+		assert.Nil(t, err)
+		var stashes []StashInfo
+		assert.Nil(t, json.Unmarshal([]byte(listMsg.Data), &stashes))
+		assert.Equal(t, 1, len(stashes))
+		assert.Equal(t, stashId, stashes[0].Id)
+
+		// 模拟拉取了其他文件的修改
+		commitTestFile(t, r, "b.txt", "b", "add b")
+
+		_, relationType, err = stash(t, dir, StashActionPop, outMsg.Metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+		assert.Equal(t, "a2", string(data))
+		data, _ = os.ReadFile(filepath.Join(dir, "dist", "app.js"))
+		assert.Equal(t, "app", string(data))
+		status, _ = w.Status()
+		assert.Equal(t, "M", string(status.File("a.txt").Worktree))
+		assert.Equal(t, "?", string(status.File("dist/app.js").Worktree))
+
+		listMsg, _, err = stash(t, dir, StashActionList, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, "[]", listMsg.Data)
+
+		_, relationType, err = stash(t, dir, StashActionPop, outMsg.Metadata)
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+
+	t.Run("Clean", func(t *testing.T) {
+		dir := t.TempDir()
+		initTestRepo(t, dir)
+		outMsg, relationType, err := stash(t, dir, StashActionSave, types.NewMetadata())
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyStashed))
+		assert.Equal(t, "", outMsg.Metadata.GetValue(KeyStashId))
+
+		_, relationType, err = stash(t, dir, StashActionPop, outMsg.Metadata)
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+	})
+
+	t.Run("RepoConfigSignature", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		head, _ := r.Head()
+		assert.Nil(t, os.MkdirAll(filepath.Join(dir, "src", "pkg"), os.ModePerm))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "src", "pkg", "main.go"), []byte("package main"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("changed"), 0644))
+		saveNode := func() types.Node {
+			node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+				"directory":      dir,
+				"appendRepoName": false,
+			}, Registry)
+			assert.Nil(t, err)
+			return node
+		}
+
+		// 没有配置签名，仓库也没有配置 user.name 时失败，不修改工作区
+		_, relationType, err := onMsgSync(saveNode(), types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		data, _ := os.ReadFile(filepath.Join(dir, "README.md"))
+		assert.Equal(t, "changed", string(data))
+
+		cfg, _ := r.Config()
+		cfg.User.Name = "rulego"
+		cfg.User.Email = "rulego@rulego.cc"
+		assert.Nil(t, r.SetConfig(cfg))
+		outMsg, relationType, err := onMsgSync(saveNode(), types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		// 当前分支没有移动
+		newHead, _ := r.Head()
+		assert.Equal(t, head.Hash(), newHead.Hash())
+		ref, err := r.Reference(plumbing.ReferenceName(StashRefPrefix+outMsg.Metadata.GetValue(KeyStashId)), false)
+		assert.Nil(t, err)
+		commit, err := r.CommitObject(ref.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, "rulego", commit.Author.Name)
+		assert.Equal(t, "rulego@rulego.cc", commit.Committer.Email)
+		assert.Equal(t, []plumbing.Hash{head.Hash()}, commit.ParentHashes)
+		file, err := commit.File("src/pkg/main.go")
+		assert.Nil(t, err)
+		content, _ := file.Contents()
+		assert.Equal(t, "package main", content)
+		file, err = commit.File("README.md")
+		assert.Nil(t, err)
+		content, _ = file.Contents()
+		assert.Equal(t, "changed", content)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		dir := t.TempDir()
+		r := initTestRepo(t, dir)
+		commitTestFile(t, r, "a.txt", "a", "add a")
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("local"), 0644))
+		outMsg, _, err := stash(t, dir, StashActionSave, types.NewMetadata())
+		assert.Nil(t, err)
+		commitTestFile(t, r, "a.txt", "remote", "modify a")
+
+		outMsg, relationType, err := stash(t, dir, StashActionPop, outMsg.Metadata)
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		var conflict MergeConflict
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &conflict))
+		assert.Equal(t, []string{"a.txt"}, conflict.Conflicts)
+		data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+		assert.Equal(t, "remote", string(data))
+	})
+}
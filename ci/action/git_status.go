@@ -0,0 +1,205 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitStatusNode{})
+}
+
+// KeyIsClean 工作区是否没有任何修改
+const KeyIsClean = "isClean"
+
+// GitStatusNodeConfiguration 节点配置
+type GitStatusNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 只统计该路径前缀下的文件，例如：src/，为空则统计所有文件
+	PathPrefix string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// FileStatus 文件状态，状态码与 git status --porcelain 一致，例如：M 修改、A 新增、D 删除、R 重命名、? 未跟踪、空格 未修改
+type FileStatus struct {
+	// 文件路径
+	Path string `json:"path"`
+	// 暂存区状态
+	Staging string `json:"staging"`
+	// 工作区状态
+	Worktree string `json:"worktree"`
+}
+
+// StatusSummary 文件状态统计
+type StatusSummary struct {
+	Modified  int `json:"modified"`
+	Added     int `json:"added"`
+	Deleted   int `json:"deleted"`
+	Renamed   int `json:"renamed"`
+	Untracked int `json:"untracked"`
+}
+
+// StatusResult 工作区状态
+type StatusResult struct {
+	// 是否没有任何修改
+	IsClean bool `json:"isClean"`
+	// 有修改的文件
+	Files []FileStatus `json:"files"`
+	// 统计
+	Summary StatusSummary `json:"summary"`
+}
+
+// GitStatusNode 获取工作区状态，以JSON的形式写入 msg.Data，是否没有修改写入元数据 isClean
+type GitStatusNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitStatusNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitStatusNode) Type() string {
+	return "ci/gitStatus"
+}
+
+func (x *GitStatusNode) New() types.Node {
+	return &GitStatusNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitStatusNodeConfiguration{
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitStatusNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.PathPrefix) {
+		x.hasVar = true
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *GitStatusNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	status, err := w.Status()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result := x.getStatusResult(status, x.getPathPrefix(evn))
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyIsClean), strconv.FormatBool(result.IsClean))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitStatusNode) Destroy() {
+	x.releaseRepositories()
+}
+
+// getStatusResult 按路径前缀过滤并统计文件状态
+func (x *GitStatusNode) getStatusResult(status git.Status, pathPrefix string) StatusResult {
+	result := StatusResult{Files: make([]FileStatus, 0)}
+	for path, fileStatus := range status {
+		if fileStatus.Staging == git.Unmodified && fileStatus.Worktree == git.Unmodified {
+			continue
+		}
+		if pathPrefix != "" && !strings.HasPrefix(path, pathPrefix) {
+			continue
+		}
+		result.Files = append(result.Files, FileStatus{
+			Path:     path,
+			Staging:  string(fileStatus.Staging),
+			Worktree: string(fileStatus.Worktree),
+		})
+		code := fileStatus.Staging
+		if code == git.Unmodified {
+			code = fileStatus.Worktree
+		}
+		switch code {
+		case git.Untracked:
+			result.Summary.Untracked++
+		case git.Added, git.Copied:
+			result.Summary.Added++
+		case git.Deleted:
+			result.Summary.Deleted++
+		case git.Renamed:
+			result.Summary.Renamed++
+		default:
+			result.Summary.Modified++
+		}
+	}
+	sort.Slice(result.Files, func(i, j int) bool {
+		return result.Files[i].Path < result.Files[j].Path
+	})
+	result.IsClean = len(result.Files) == 0
+	return result
+}
+
+// getPathPrefix 获取路径前缀，统一使用/分隔
+func (x *GitStatusNode) getPathPrefix(evn map[string]interface{}) string {
+	pathPrefix := x.Config.PathPrefix
+	if evn != nil {
+		pathPrefix = str.ExecuteTemplate(pathPrefix, evn)
+	}
+	return strings.TrimPrefix(filepath.ToSlash(pathPrefix), "./")
+}
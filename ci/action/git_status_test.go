@@ -0,0 +1,90 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitStatusNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitStatusNode{})
+	var targetNodeType = "ci/gitStatus"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitStatusNode{}, types.Configuration{
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	commitTestFile(t, r, "src/a.txt", "a", "add a")
+	commitTestFile(t, r, "src/b.txt", "b", "add b")
+	status := func(t *testing.T, pathPrefix string) (types.RuleMsg, StatusResult) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      dir,
+			"appendRepoName": false,
+			"pathPrefix":     pathPrefix,
+		}, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result StatusResult
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return outMsg, result
+	}
+
+	outMsg, result := status(t, "")
+	assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyIsClean))
+	assert.True(t, result.IsClean)
+	assert.Equal(t, 0, len(result.Files))
+
+	w, _ := r.Worktree()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("modified"), 0644))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "src", "a.txt")))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "src", "c.txt"), []byte("c"), 0644))
+	_, err := w.Add("src/c.txt")
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644))
+
+	outMsg, result = status(t, "")
+	assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyIsClean))
+	assert.False(t, result.IsClean)
+	assert.Equal(t, []FileStatus{
+		{Path: "README.md", Staging: " ", Worktree: "M"},
+		{Path: "new.txt", Staging: "?", Worktree: "?"},
+		{Path: "src/a.txt", Staging: " ", Worktree: "D"},
+		{Path: "src/c.txt", Staging: "A", Worktree: " "},
+	}, result.Files)
+	assert.Equal(t, StatusSummary{Modified: 1, Added: 1, Deleted: 1, Untracked: 1}, result.Summary)
+
+	//路径前缀过滤
+	_, result = status(t, "src/")
+	assert.Equal(t, 2, len(result.Files))
+	assert.Equal(t, StatusSummary{Added: 1, Deleted: 1}, result.Summary)
+
+	_, result = status(t, "docs/")
+	assert.True(t, result.IsClean)
+}
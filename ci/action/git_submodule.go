@@ -0,0 +1,417 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io"
+	"path"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitSubmoduleNode{})
+}
+
+const (
+	// SubmoduleActionUpdate 初始化并更新子模块到父仓库记录的提交
+	SubmoduleActionUpdate = "update"
+	// SubmoduleActionSync 把 .gitmodules 中的地址同步到仓库配置和子模块的远程仓库配置
+	SubmoduleActionSync = "sync"
+	// SubmoduleActionStatus 获取子模块状态
+	SubmoduleActionStatus = "status"
+)
+
+// GitSubmoduleNodeConfiguration 节点配置
+type GitSubmoduleNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 update、sync 或 status，默认update
+	Action string
+	// 需要处理的子模块名称或者路径，多个与逗号隔开，为空则处理所有子模块
+	Submodules string
+	// 是否同时处理嵌套的子模块，默认true
+	Recursive bool
+	// 更新时拉取的历史深度，0表示完整历史
+	Depth int
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// SubmoduleInfo 子模块状态
+type SubmoduleInfo struct {
+	// 名称
+	Name string `json:"name"`
+	// 相对父仓库根目录的路径，嵌套子模块包含上级子模块的路径
+	Path string `json:"path"`
+	// 配置的地址
+	Url string `json:"url"`
+	// 父仓库记录的提交hash
+	Expected string `json:"expected"`
+	// 实际检出的提交hash，没有初始化或者没有检出时为空
+	Current string `json:"current"`
+	// 是否已经初始化
+	Initialized bool `json:"initialized"`
+	// 实际检出的提交是否与父仓库记录的一致
+	UpToDate bool `json:"upToDate"`
+	// 子模块工作区是否有未提交的修改
+	Dirty bool `json:"dirty"`
+}
+
+// GitSubmoduleNode 管理子模块，可以在切换分支后把子模块更新到父仓库记录的提交，或者获取子模块状态
+// 所有操作完成后都以JSON数组的形式把子模块状态写入 msg.Data，嵌套的子模块展开在其上级子模块之后
+// 子模块的远程仓库使用节点配置的认证方式，go-git 更新子模块时不支持代理和自定义证书
+type GitSubmoduleNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitSubmoduleNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitSubmoduleNode) Type() string {
+	return "ci/gitSubmodule"
+}
+
+func (x *GitSubmoduleNode) New() types.Node {
+	return &GitSubmoduleNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitSubmoduleNodeConfiguration{
+			Action:         SubmoduleActionUpdate,
+			Recursive:      true,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitSubmoduleNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = SubmoduleActionUpdate
+	case SubmoduleActionUpdate, SubmoduleActionSync, SubmoduleActionStatus:
+	default:
+		return fmt.Errorf("unsupported submodule action: %s", x.Config.Action)
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Submodules) || x.authHasVar() {
+		x.hasVar = true
+	}
+	return x.initBase(ruleConfig)
+}
+
+// OnMsg 处理消息
+func (x *GitSubmoduleNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	names := x.getValue(x.Config.Submodules, evn)
+	submodules, err := x.getSubmodules(w, names)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if x.Config.Action != SubmoduleActionStatus {
+		switch x.Config.Action {
+		case SubmoduleActionUpdate:
+			err = x.update(ctx, msg, submodules, evn)
+		case SubmoduleActionSync:
+			err = x.sync(r, submodules)
+		}
+		if err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+		// 重新读取子模块配置，获取更新后的状态
+		if submodules, err = x.getSubmodules(w, names); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+	}
+	infos, err := x.status(submodules, "")
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	data, err := json.Marshal(infos)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitSubmoduleNode) Destroy() {
+	x.destroyBase()
+}
+
+// getSubmodules 获取需要处理的子模块，names 为空则返回所有子模块
+func (x *GitSubmoduleNode) getSubmodules(w *git.Worktree, names string) (git.Submodules, error) {
+	submodules, err := w.Submodules()
+	if err != nil {
+		return nil, err
+	}
+	filters := splitUrls(names)
+	if len(filters) == 0 {
+		return submodules, nil
+	}
+	var result git.Submodules
+	for _, filter := range filters {
+		var found bool
+		for _, submodule := range submodules {
+			if submodule.Config().Name == filter || submodule.Config().Path == strings.TrimSuffix(filter, "/") {
+				result = append(result, submodule)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("submodule %s not found", filter)
+		}
+	}
+	return result, nil
+}
+
+// update 初始化并更新子模块
+func (x *GitSubmoduleNode) update(ctx types.RuleContext, msg types.RuleMsg, submodules git.Submodules, evn map[string]interface{}) error {
+	options := &git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.NoRecurseSubmodules,
+		Depth:             x.Config.Depth,
+	}
+	if x.Config.Recursive {
+		options.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		return err
+	} else if auth != nil {
+		options.Auth = auth
+	}
+	for _, submodule := range submodules {
+		err := x.execute(ctx, msg, "submodule update", submodule.Config().URL, func(opCtx context.Context) error {
+			return submodule.UpdateContext(opCtx, options)
+		})
+		if err != nil {
+			return fmt.Errorf("update submodule %s: %w", submodule.Config().Path, err)
+		}
+	}
+	return nil
+}
+
+// sync 把 .gitmodules 中的地址同步到仓库配置，已经初始化的子模块同时更新其 origin 远程仓库地址
+func (x *GitSubmoduleNode) sync(r *git.Repository, submodules git.Submodules) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	// 已经初始化的子模块 Config() 返回的是仓库配置中的地址，需要直接读取 .gitmodules
+	modules, err := readGitmodules(w)
+	if err != nil {
+		return err
+	}
+	for _, submodule := range submodules {
+		name := submodule.Config().Name
+		current, ok := cfg.Submodules[name]
+		if !ok {
+			// 没有初始化
+			continue
+		}
+		module, ok := modules.Submodules[name]
+		if !ok {
+			continue
+		}
+		url := module.URL
+		current.URL = url
+		subRepo, err := submodule.Repository()
+		if err != nil {
+			return err
+		}
+		if err = setOriginUrl(subRepo, url); err != nil {
+			return err
+		}
+		if x.Config.Recursive {
+			if subWorktree, err := subRepo.Worktree(); err == nil {
+				if nested, err := subWorktree.Submodules(); err == nil && len(nested) > 0 {
+					if err = x.sync(subRepo, nested); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return r.SetConfig(cfg)
+}
+
+// status 获取子模块状态，prefix 为上级子模块的路径
+func (x *GitSubmoduleNode) status(submodules git.Submodules, prefix string) ([]SubmoduleInfo, error) {
+	infos := make([]SubmoduleInfo, 0, len(submodules))
+	for _, submodule := range submodules {
+		status, err := submodule.Status()
+		if err != nil && !errors.Is(err, git.ErrSubmoduleNotInitialized) {
+			return nil, err
+		}
+		info := SubmoduleInfo{
+			Name: submodule.Config().Name,
+			Path: path.Join(prefix, submodule.Config().Path),
+			Url:  submodule.Config().URL,
+		}
+		if status != nil {
+			info.Expected = status.Expected.String()
+			if !status.Current.IsZero() {
+				info.Current = status.Current.String()
+			}
+			info.UpToDate = status.IsClean()
+		}
+		subRepo, err := submodule.Repository()
+		if errors.Is(err, git.ErrSubmoduleNotInitialized) {
+			infos = append(infos, info)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		info.Initialized = true
+		var nested []SubmoduleInfo
+		if _, err = subRepo.Head(); err == nil {
+			subWorktree, err := subRepo.Worktree()
+			if err != nil {
+				return nil, err
+			}
+			if info.Dirty, err = isDirtyWorktree(subWorktree); err != nil {
+				return nil, err
+			}
+			if x.Config.Recursive {
+				nestedSubmodules, err := subWorktree.Submodules()
+				if err != nil {
+					return nil, err
+				}
+				if nested, err = x.status(nestedSubmodules, info.Path); err != nil {
+					return nil, err
+				}
+			}
+		} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, err
+		}
+		infos = append(infos, info)
+		infos = append(infos, nested...)
+	}
+	return infos, nil
+}
+
+func (x *GitSubmoduleNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// readGitmodules 读取工作区的 .gitmodules 文件
+func readGitmodules(w *git.Worktree) (*config.Modules, error) {
+	f, err := w.Filesystem.Open(".gitmodules")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	modules := config.NewModules()
+	if err = modules.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// setOriginUrl 修改仓库 origin 远程仓库的地址
+func setOriginUrl(r *git.Repository, url string) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	remote, ok := cfg.Remotes[git.DefaultRemoteName]
+	if !ok {
+		return nil
+	}
+	remote.URLs = []string{url}
+	return r.SetConfig(cfg)
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// addTestSubmodule 在 r 中登记路径为 subPath 的子模块，指向 url 仓库的 hash 提交
+func addTestSubmodule(t *testing.T, r *git.Repository, dir, subPath, url string, hash plumbing.Hash) plumbing.Hash {
+	modules := fmt.Sprintf("[submodule \"%s\"]\n\tpath = %s\n\turl = %s\n", subPath, subPath, url)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(modules), 0644))
+	w, err := r.Worktree()
+	assert.Nil(t, err)
+	_, err = w.Add(".gitmodules")
+	assert.Nil(t, err)
+	idx, err := r.Storer.Index()
+	assert.Nil(t, err)
+	entry := idx.Add(subPath)
+	entry.Hash = hash
+	entry.Mode = filemode.Submodule
+	assert.Nil(t, r.Storer.SetIndex(idx))
+	commitHash, err := w.Commit("add submodule "+subPath, &git.CommitOptions{
+		Author: &object.Signature{Name: testSignature.Name, Email: testSignature.Email, When: time.Now()},
+	})
+	assert.Nil(t, err)
+	return commitHash
+}
+
+func TestGitSubmoduleNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitSubmoduleNode{})
+	var targetNodeType = "ci/gitSubmodule"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitSubmoduleNode{}, types.Configuration{
+			"action":         SubmoduleActionUpdate,
+			"recursive":      true,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "foreach"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	submodule := func(t *testing.T, dir string, config types.Configuration) ([]SubmoduleInfo, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		var infos []SubmoduleInfo
+		if err == nil {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &infos))
+		}
+		return infos, relationType, err
+	}
+
+	// nested <- lib <- super
+	nestedDir := t.TempDir()
+	nestedRepo := initTestRepo(t, nestedDir)
+	nestedHash := commitTestFile(t, nestedRepo, "nested.txt", "nested", "add nested")
+	libDir := t.TempDir()
+	libRepo := initTestRepo(t, libDir)
+	commitTestFile(t, libRepo, "lib.txt", "v1", "add lib")
+	addTestSubmodule(t, libRepo, libDir, "deps/nested", nestedDir, nestedHash)
+	libHash := commitTestFile(t, libRepo, "lib.txt", "v2", "update lib")
+	commitTestFile(t, libRepo, "lib.txt", "v3", "not recorded")
+	superDir := t.TempDir()
+	superRepo := initTestRepo(t, superDir)
+	addTestSubmodule(t, superRepo, superDir, "libs/lib", libDir, libHash)
+
+	cloneSuper := func(t *testing.T) string {
+		dir := t.TempDir()
+		_, err := git.PlainClone(dir, false, &git.CloneOptions{URL: superDir})
+		assert.Nil(t, err)
+		return dir
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		dir := cloneSuper(t)
+		infos, relationType, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionStatus})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, 1, len(infos))
+		assert.Equal(t, "libs/lib", infos[0].Path)
+		assert.Equal(t, libDir, infos[0].Url)
+		assert.Equal(t, libHash.String(), infos[0].Expected)
+		assert.False(t, infos[0].Initialized)
+		assert.False(t, infos[0].UpToDate)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		dir := cloneSuper(t)
+		infos, relationType, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionUpdate})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, 2, len(infos))
+		assert.Equal(t, "libs/lib", infos[0].Path)
+		assert.Equal(t, libHash.String(), infos[0].Current)
+		assert.True(t, infos[0].Initialized)
+		assert.True(t, infos[0].UpToDate)
+		assert.False(t, infos[0].Dirty)
+		assert.Equal(t, "libs/lib/deps/nested", infos[1].Path)
+		assert.Equal(t, nestedHash.String(), infos[1].Current)
+		assert.True(t, infos[1].UpToDate)
+
+		data, err := os.ReadFile(filepath.Join(dir, "libs", "lib", "lib.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "v2", string(data))
+		data, err = os.ReadFile(filepath.Join(dir, "libs", "lib", "deps", "nested", "nested.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "nested", string(data))
+
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "libs", "lib", "lib.txt"), []byte("local"), 0644))
+		infos, _, err = submodule(t, dir, types.Configuration{"action": SubmoduleActionStatus, "recursive": false})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(infos))
+		assert.True(t, infos[0].Dirty)
+		assert.True(t, infos[0].UpToDate)
+	})
+
+	t.Run("UpdateNotRecursive", func(t *testing.T) {
+		dir := cloneSuper(t)
+		_, _, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionUpdate, "recursive": false, "submodules": "libs/lib"})
+		assert.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dir, "libs", "lib", "lib.txt"))
+		assert.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dir, "libs", "lib", "deps", "nested", "nested.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		dir := cloneSuper(t)
+		_, _, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionUpdate})
+		assert.Nil(t, err)
+
+		movedDir := t.TempDir()
+		_, err = git.PlainClone(movedDir, false, &git.CloneOptions{URL: libDir})
+		assert.Nil(t, err)
+		modules := fmt.Sprintf("[submodule \"libs/lib\"]\n\tpath = libs/lib\n\turl = %s\n", movedDir)
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(modules), 0644))
+
+		infos, _, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionSync})
+		assert.Nil(t, err)
+		assert.Equal(t, movedDir, infos[0].Url)
+		r, err := git.PlainOpen(dir)
+		assert.Nil(t, err)
+		cfg, err := r.Config()
+		assert.Nil(t, err)
+		assert.Equal(t, movedDir, cfg.Submodules["libs/lib"].URL)
+		libClone, err := git.PlainOpen(filepath.Join(dir, "libs", "lib"))
+		assert.Nil(t, err)
+		remote, err := libClone.Remote(git.DefaultRemoteName)
+		assert.Nil(t, err)
+		assert.Equal(t, movedDir, remote.Config().URLs[0])
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		dir := cloneSuper(t)
+		_, relationType, err := submodule(t, dir, types.Configuration{"action": SubmoduleActionStatus, "submodules": "missing"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
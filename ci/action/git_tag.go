@@ -0,0 +1,468 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitTagNode{})
+}
+
+// KeyLatestTag 最新的标签
+const KeyLatestTag = "latestTag"
+
+const (
+	// TagActionCreate 创建标签
+	TagActionCreate = "create"
+	// TagActionList 列出标签
+	TagActionList = "list"
+	// TagActionDelete 删除标签
+	TagActionDelete = "delete"
+)
+
+const (
+	// TagSortSemver 按语义化版本排序，不是语义化版本的标签排在最后
+	TagSortSemver = "semver"
+	// TagSortDate 按创建时间排序，轻量标签使用提交时间
+	TagSortDate = "date"
+)
+
+// GitTagNodeConfiguration 节点配置
+type GitTagNodeConfiguration struct {
+	// Git 仓库 URL，推送删除时使用，为空则使用 RemoteName 对应的远程仓库
+	Repository string
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 list 或 delete，默认list
+	Action string
+	// 需要删除的标签名称
+	Tag string
+	// 只列出匹配的标签，支持通配符，例如：v1.*，为空则列出所有标签
+	Pattern string
+	// 排序方式，可以是 semver 或 date，默认semver，最新的在前
+	SortBy string
+	// 删除本地标签后是否同时删除远程标签
+	PushDelete bool
+	// 推送删除的远程仓库名称，默认origin
+	RemoteName string
+	// 认证类型，可以是 "ssh", "password", "token" 或 "none"，为空或者 "none" 表示匿名访问，支持${metadata.xx}变量
+	AuthType string
+	// 用户名，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取
+	AuthUser string
+	// 密码或 token，支持${metadata.xx}变量和 env://变量名 或 file://文件路径 从环境变量或者文件读取，错误信息中的密码被替换为 ***
+	AuthPassword string
+	// SSH 秘钥文件路径，支持${metadata.xx}变量和 env://变量名 从环境变量读取文件路径
+	AuthPemFile string
+	// SSH 秘钥内容，作为 AuthPemFile 的替代，同时配置时优先使用该值。支持${metadata.xx}变量和 env://变量名 或 file://文件路径 读取
+	AuthPemContent string
+	// 代理地址，例如：http://proxy:8080 或 socks5://proxy:1080，SSH 仓库通过 HTTP CONNECT 或者 SOCKS5 隧道连接
+	ProxyUrl string
+	// 代理用户名
+	ProxyUsername string
+	// 代理密码
+	ProxyPassword string
+	// 是否跳过HTTPS证书校验，默认校验
+	InsecureSkipVerify bool
+	// 自定义CA证书文件路径(PEM格式)，用于校验自建git服务的证书
+	CABundleFile string
+	// 网络操作超时时间，单位秒，0表示不超时
+	Timeout int
+	// 临时网络错误重试次数，0表示不重试
+	RetryCount int
+	// 第一次重试间隔，单位毫秒，之后每次翻倍，默认1000
+	RetryIntervalMs int
+	// SSH 主机密钥校验方式，可以是 "known_hosts"、"fingerprint" 或 "insecure"(不校验)，为空则使用 go-git 默认的校验方式
+	SshHostKeyVerification string
+	// known_hosts 文件路径，为空则使用默认路径
+	SshKnownHostsFile string
+	// 固定的主机密钥 SHA256 指纹，例如：SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+	SshHostKeyFingerprint string
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// TagInfo 标签信息
+type TagInfo struct {
+	// 标签名称
+	Name string `json:"name"`
+	// 标签指向的提交hash
+	Hash string `json:"hash"`
+	// 是否附注标签
+	Annotated bool `json:"annotated"`
+	// 附注消息，轻量标签为空
+	Message string `json:"message,omitempty"`
+	// 创建者名称，轻量标签为提交者
+	Tagger string `json:"tagger"`
+	// 创建者邮箱，轻量标签为提交者
+	TaggerEmail string `json:"taggerEmail"`
+	// 创建时间，轻量标签为提交时间
+	Time time.Time `json:"time"`
+}
+
+// GitTagNode 列出或者删除标签，附注标签和轻量标签都支持
+// list 以JSON数组的形式把标签写入 msg.Data，最新的标签写入元数据 latestTag，可以用于计算下一个版本号
+// delete 删除本地标签，配置 PushDelete 时同时删除远程标签
+type GitTagNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitTagNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitTagNode) Type() string {
+	return "ci/gitTag"
+}
+
+func (x *GitTagNode) New() types.Node {
+	return &GitTagNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitTagNodeConfiguration{
+			Action:         TagActionList,
+			SortBy:         TagSortSemver,
+			RemoteName:     git.DefaultRemoteName,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitTagNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = TagActionList
+	case TagActionList:
+	case TagActionDelete:
+		if strings.TrimSpace(x.Config.Tag) == "" {
+			return errors.New("tag can not be empty")
+		}
+	default:
+		return fmt.Errorf("unsupported tag action: %s", x.Config.Action)
+	}
+	x.Config.SortBy = strings.ToLower(strings.TrimSpace(x.Config.SortBy))
+	switch x.Config.SortBy {
+	case "":
+		x.Config.SortBy = TagSortSemver
+	case TagSortSemver, TagSortDate:
+	default:
+		return fmt.Errorf("unsupported sortBy: %s", x.Config.SortBy)
+	}
+	if x.Config.RemoteName == "" {
+		x.Config.RemoteName = git.DefaultRemoteName
+	}
+	if str.CheckHasVar(x.Config.Repository) || str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Tag) ||
+		str.CheckHasVar(x.Config.Pattern) || x.authHasVar() {
+		x.hasVar = true
+	}
+	return x.initBase(ruleConfig)
+}
+
+// OnMsg 处理消息
+func (x *GitTagNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	unlock, err := x.lockWorkDir(ctx, msg.Metadata.GetValue(x.metaKey(KeyRepoId)), workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	defer unlock()
+	r, err := x.openLockedRepository(msg, workDir)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if x.Config.Action == TagActionDelete {
+		if err = x.delete(ctx, msg, r, x.getValue(x.Config.Tag, evn), evn); err != nil {
+			x.tellFailure(ctx, msg, err)
+			return
+		}
+		ctx.TellSuccess(msg)
+		return
+	}
+	tags, err := x.list(r, x.getValue(x.Config.Pattern, evn))
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		x.tellFailure(ctx, msg, err)
+		return
+	}
+	if len(tags) > 0 {
+		msg.Metadata.PutValue(x.metaKey(KeyLatestTag), tags[0].Name)
+	} else {
+		msg.Metadata.PutValue(x.metaKey(KeyLatestTag), "")
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitTagNode) Destroy() {
+	x.destroyBase()
+}
+
+// delete 删除本地标签，配置 PushDelete 时推送 :refs/tags/{tag} 删除远程标签
+func (x *GitTagNode) delete(ctx types.RuleContext, msg types.RuleMsg, r *git.Repository, tag string, evn map[string]interface{}) error {
+	if err := r.DeleteTag(tag); err != nil {
+		return fmt.Errorf("%w: %s", err, tag)
+	}
+	if !x.Config.PushDelete {
+		return nil
+	}
+	repository := x.Config.Repository
+	if evn != nil {
+		repository = str.ExecuteTemplate(repository, evn)
+	}
+	remote := repository
+	if remote == "" {
+		remote = x.Config.RemoteName
+	}
+	pushOptions := &git.PushOptions{
+		RemoteName:      x.Config.RemoteName,
+		RemoteURL:       repository,
+		RefSpecs:        []config.RefSpec{config.RefSpec(":" + plumbing.NewTagReferenceName(tag).String())},
+		InsecureSkipTLS: x.Config.InsecureSkipVerify,
+		CABundle:        x.caBundle,
+	}
+	if auth, err := x.getAuthMethod(evn); err != nil {
+		return err
+	} else if auth != nil {
+		pushOptions.Auth = auth
+	}
+	if proxy := x.getProxy(); proxy.URL != "" {
+		pushOptions.ProxyOptions = proxy
+	}
+	err := x.execute(ctx, msg, "push", remote, func(opCtx context.Context) error {
+		return r.PushContext(opCtx, pushOptions)
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// list 列出匹配的标签，按 SortBy 排序，最新的在前
+func (x *GitTagNode) list(r *git.Repository, pattern string) ([]TagInfo, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]TagInfo, 0)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, name); !ok {
+				return nil
+			}
+		}
+		info, err := newTagInfo(r, ref)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if x.Config.SortBy == TagSortDate {
+			if !tags[i].Time.Equal(tags[j].Time) {
+				return tags[i].Time.After(tags[j].Time)
+			}
+			return tags[i].Name > tags[j].Name
+		}
+		if c := compareSemver(tags[i].Name, tags[j].Name); c != 0 {
+			return c > 0
+		}
+		return tags[i].Name > tags[j].Name
+	})
+	return tags, nil
+}
+
+// newTagInfo 获取标签信息，附注标签解析到指向的提交，轻量标签使用提交者作为创建者
+func newTagInfo(r *git.Repository, ref *plumbing.Reference) (TagInfo, error) {
+	info := TagInfo{Name: ref.Name().Short(), Hash: ref.Hash().String()}
+	tag, err := r.TagObject(ref.Hash())
+	if err == nil {
+		info.Annotated = true
+		info.Message = strings.TrimSpace(tag.Message)
+		info.Tagger = tag.Tagger.Name
+		info.TaggerEmail = tag.Tagger.Email
+		info.Time = tag.Tagger.When
+		if commit, err := tag.Commit(); err == nil {
+			info.Hash = commit.Hash.String()
+		} else {
+			info.Hash = tag.Target.String()
+		}
+	} else if errors.Is(err, plumbing.ErrObjectNotFound) {
+		commit, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return info, err
+		}
+		info.Tagger = commit.Committer.Name
+		info.TaggerEmail = commit.Committer.Email
+		info.Time = commit.Committer.When
+	} else {
+		return info, err
+	}
+	return info, nil
+}
+
+func (x *GitTagNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// semver 语义化版本
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseSemver 解析语义化版本，允许v前缀以及省略次版本号和修订号，例如：v1.2.3-rc.1+build、1.2
+func parseSemver(version string) (semver, bool) {
+	var v semver
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.Index(version, "-"); i >= 0 {
+		if version[i+1:] == "" {
+			return v, false
+		}
+		v.prerelease = strings.Split(version[i+1:], ".")
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		*numbers[i] = n
+	}
+	return v, true
+}
+
+// compareSemver 比较两个版本，a大于b返回1，小于返回-1，相等返回0，不是语义化版本的小于语义化版本
+func compareSemver(a, b string) int {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for _, pair := range [][2]int{{va.major, vb.major}, {va.minor, vb.minor}, {va.patch, vb.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] > pair[1] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return comparePrerelease(va.prerelease, vb.prerelease)
+}
+
+// comparePrerelease 按语义化版本规范比较先行版本号，没有先行版本号的更大
+// 逐个比较标识符，数字标识符按数值比较并且小于非数字标识符，前面的标识符都相同时标识符多的更大
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na == nb {
+				continue
+			}
+			if na > nb {
+				return 1
+			}
+			return -1
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		case a[i] > b[i]:
+			return 1
+		default:
+			return -1
+		}
+	}
+	switch {
+	case len(a) > len(b):
+		return 1
+	case len(a) < len(b):
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGitTagNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitTagNode{})
+	var targetNodeType = "ci/gitTag"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitTagNode{}, types.Configuration{
+			"action":         "list",
+			"sortBy":         "semver",
+			"remoteName":     "origin",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		for _, config := range []types.Configuration{
+			{"action": "create"},
+			{"action": "delete"},
+			{"sortBy": "name"},
+		} {
+			_, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+			assert.NotNil(t, err)
+		}
+	})
+
+	// 创建包含附注标签和轻量标签的仓库
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	first := commitTestFile(t, r, "a.txt", "a", "add a")
+	second := commitTestFile(t, r, "b.txt", "b", "add b")
+	third := commitTestFile(t, r, "c.txt", "c", "add c")
+	now := time.Now()
+	annotated := func(name string, hash plumbing.Hash, when time.Time) {
+		_, err := r.CreateTag(name, hash, &git.CreateTagOptions{
+			Tagger:  &object.Signature{Name: "releaser", Email: "releaser@rulego.cc", When: when},
+			Message: "release " + name,
+		})
+		assert.Nil(t, err)
+	}
+	annotated("v1.0.0", first, now.Add(3*time.Hour))
+	annotated("v1.2.0", third, now.Add(2*time.Hour))
+	for name, hash := range map[string]plumbing.Hash{"v1.2.0-rc.1": second, "v1.10.0": third, "nightly": third} {
+		_, err := r.CreateTag(name, hash, nil)
+		assert.Nil(t, err)
+	}
+
+	tag := func(t *testing.T, dir string, config types.Configuration) (types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+	names := func(tags []TagInfo) []string {
+		var result []string
+		for _, item := range tags {
+			result = append(result, item.Name)
+		}
+		return result
+	}
+
+	t.Run("ListSemver", func(t *testing.T) {
+		outMsg, relationType, err := tag(t, dir, types.Configuration{})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "v1.10.0", outMsg.Metadata.GetValue(KeyLatestTag))
+		var tags []TagInfo
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &tags))
+		assert.Equal(t, []string{"v1.10.0", "v1.2.0", "v1.2.0-rc.1", "v1.0.0", "nightly"}, names(tags))
+
+		assert.False(t, tags[0].Annotated)
+		assert.Equal(t, third.String(), tags[0].Hash)
+		assert.Equal(t, "rulego", tags[0].Tagger)
+		assert.True(t, tags[1].Annotated)
+		assert.Equal(t, third.String(), tags[1].Hash)
+		assert.Equal(t, "release v1.2.0", tags[1].Message)
+		assert.Equal(t, "releaser@rulego.cc", tags[1].TaggerEmail)
+	})
+
+	t.Run("ListDate", func(t *testing.T) {
+		outMsg, _, err := tag(t, dir, types.Configuration{"sortBy": "date", "pattern": "v1.*"})
+		assert.Nil(t, err)
+		assert.Equal(t, "v1.0.0", outMsg.Metadata.GetValue(KeyLatestTag))
+		var tags []TagInfo
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &tags))
+		assert.Equal(t, []string{"v1.0.0", "v1.2.0"}, names(tags)[:2])
+		assert.Equal(t, 4, len(tags))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		localDir := t.TempDir()
+		local := initTestRepo(t, localDir)
+		head, _ := local.Head()
+		_, err := local.CreateTag("v0.1.0", head.Hash(), &git.CreateTagOptions{Tagger: &testSignature, Message: "bad"})
+		assert.Nil(t, err)
+		_, err = local.CreateTag("v0.1.1", head.Hash(), nil)
+		assert.Nil(t, err)
+		remoteDir := filepath.Join(t.TempDir(), "remote.git")
+		remote, err := git.PlainInit(remoteDir, true)
+		assert.Nil(t, err)
+		_, err = local.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{remoteDir}})
+		assert.Nil(t, err)
+		assert.Nil(t, local.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/tags/*:refs/tags/*"}}))
+
+		_, relationType, err := tag(t, localDir, types.Configuration{"action": "delete", "tag": "v0.1.0", "pushDelete": true})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		_, err = local.Tag("v0.1.0")
+		assert.Equal(t, git.ErrTagNotFound, err)
+		_, err = remote.Tag("v0.1.0")
+		assert.Equal(t, git.ErrTagNotFound, err)
+
+		// 只删除本地标签
+		_, relationType, err = tag(t, localDir, types.Configuration{"action": "delete", "tag": "v0.1.1"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		_, err = local.Tag("v0.1.1")
+		assert.Equal(t, git.ErrTagNotFound, err)
+		_, err = remote.Tag("v0.1.1")
+		assert.Nil(t, err)
+
+		_, relationType, err = tag(t, localDir, types.Configuration{"action": "delete", "tag": "v9.9.9"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
+
+func TestCompareSemver(t *testing.T) {
+	for _, item := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v2", "v1.99.99", 1},
+		{"v1.0.0", "v1.0.0-rc.1", 1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", -1},
+		{"v1.0.0-beta.2", "v1.0.0-beta.11", -1},
+		{"v1.0.0-rc.1", "v1.0.0-beta", 1},
+		{"v1.0.0+build.1", "v1.0.0+build.2", 0},
+		{"nightly", "v0.0.1", -1},
+		{"nightly", "latest", 0},
+	} {
+		assert.Equal(t, item.expected, compareSemver(item.a, item.b))
+		assert.Equal(t, -item.expected, compareSemver(item.b, item.a))
+	}
+}
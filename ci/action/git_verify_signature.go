@@ -0,0 +1,312 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"os"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitVerifySignatureNode{})
+}
+
+// KeySignatureStatus 签名校验结果
+const KeySignatureStatus = "signatureStatus"
+
+const (
+	// SignatureStatusValid 签名有效
+	SignatureStatusValid = "valid"
+	// SignatureStatusUnsigned 没有签名
+	SignatureStatusUnsigned = "unsigned"
+	// SignatureStatusUnknownKey 签名的公钥不在密钥环中
+	SignatureStatusUnknownKey = "unknownKey"
+	// SignatureStatusBad 签名无效，例如内容被修改、签名或者公钥已过期
+	SignatureStatusBad = "badSignature"
+)
+
+const (
+	// VerifyObjectAuto 引用为附注标签时校验标签签名，否则校验提交签名
+	VerifyObjectAuto = "auto"
+	// VerifyObjectCommit 校验提交签名，标签会解析到其指向的提交
+	VerifyObjectCommit = "commit"
+	// VerifyObjectTag 校验附注标签签名
+	VerifyObjectTag = "tag"
+)
+
+// GitVerifySignatureNodeConfiguration 节点配置
+type GitVerifySignatureNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 校验的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 校验的对象，可以是 auto、commit 或 tag，默认auto
+	Object string
+	// 允许的公钥，ASCII armored 格式的密钥环内容或者文件路径
+	ArmoredKeyring string
+	// 是否要求必须签名，true则没有签名时发送到Failure链，false则发送到Success链，并在结果中标记没有签名
+	RequireSigned bool
+}
+
+// SignatureResult 签名校验结果
+type SignatureResult struct {
+	// 校验的引用
+	Ref string `json:"ref"`
+	// 校验的对象类型，commit 或 tag
+	ObjectType string `json:"objectType"`
+	// 校验的对象hash
+	Hash string `json:"hash"`
+	// 是否有签名
+	Signed bool `json:"signed"`
+	// 校验结果，可以是 valid、unsigned、unknownKey 或 badSignature
+	Status string `json:"status"`
+	// 签名的公钥ID，16位十六进制
+	KeyId string `json:"keyId,omitempty"`
+	// 签名者身份，例如：rulego <rulego@rulego.cc>
+	Signer string `json:"signer,omitempty"`
+	// 签名者邮箱
+	SignerEmail string `json:"signerEmail,omitempty"`
+	// 校验失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+// GitVerifySignatureNode 使用允许的公钥校验提交或者附注标签的PGP签名，结果以JSON的形式写入 msg.Data，校验结果写入元数据 signatureStatus
+// 签名有效发送到Success链；公钥不在密钥环中或者签名无效发送到Failure链；没有签名时根据 RequireSigned 决定
+type GitVerifySignatureNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitVerifySignatureNodeConfiguration
+	// 密钥环内容
+	keyring string
+	hasVar  bool
+}
+
+// Type 组件类型
+func (x *GitVerifySignatureNode) Type() string {
+	return "ci/gitVerifySignature"
+}
+
+func (x *GitVerifySignatureNode) New() types.Node {
+	return &GitVerifySignatureNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitVerifySignatureNodeConfiguration{
+			Ref:            string(plumbing.HEAD),
+			Object:         VerifyObjectAuto,
+			RequireSigned:  true,
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitVerifySignatureNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Object = strings.ToLower(strings.TrimSpace(x.Config.Object))
+	switch x.Config.Object {
+	case "":
+		x.Config.Object = VerifyObjectAuto
+	case VerifyObjectAuto, VerifyObjectCommit, VerifyObjectTag:
+	default:
+		return fmt.Errorf("unsupported object: %s", x.Config.Object)
+	}
+	keyring := strings.TrimSpace(x.Config.ArmoredKeyring)
+	if keyring == "" {
+		return errors.New("armoredKeyring can not be empty")
+	}
+	if !strings.Contains(keyring, "-----BEGIN PGP") {
+		data, err := os.ReadFile(keyring)
+		if err != nil {
+			return err
+		}
+		keyring = string(data)
+	}
+	if _, err = openpgp.ReadArmoredKeyRing(strings.NewReader(keyring)); err != nil {
+		return fmt.Errorf("invalid armored keyring: %w", err)
+	}
+	x.keyring = keyring
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitVerifySignatureNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	result, err := x.verify(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	msg.Metadata.PutValue(x.metaKey(KeySignatureStatus), result.Status)
+	switch result.Status {
+	case SignatureStatusValid:
+		ctx.TellSuccess(msg)
+	case SignatureStatusUnsigned:
+		if x.Config.RequireSigned {
+			ctx.TellFailure(msg, fmt.Errorf("%s %s is not signed", result.ObjectType, result.Hash))
+		} else {
+			ctx.TellSuccess(msg)
+		}
+	case SignatureStatusUnknownKey:
+		ctx.TellFailure(msg, fmt.Errorf("%s %s is signed by unknown key %s", result.ObjectType, result.Hash, result.KeyId))
+	default:
+		ctx.TellFailure(msg, fmt.Errorf("%s %s has bad signature: %s", result.ObjectType, result.Hash, result.Error))
+	}
+}
+
+// Destroy 销毁
+func (x *GitVerifySignatureNode) Destroy() {
+}
+
+// verify 校验引用的签名
+func (x *GitVerifySignatureNode) verify(r *git.Repository, ref string) (SignatureResult, error) {
+	result := SignatureResult{Ref: ref}
+	if x.Config.Object != VerifyObjectCommit {
+		tag, err := findTagObject(r, ref)
+		if err != nil {
+			return result, err
+		}
+		if tag != nil {
+			result.ObjectType = VerifyObjectTag
+			result.Hash = tag.Hash.String()
+			x.check(&result, tag.PGPSignature, tag.Verify)
+			return result, nil
+		}
+		if x.Config.Object == VerifyObjectTag {
+			return result, fmt.Errorf("%s is not an annotated tag", ref)
+		}
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		return result, err
+	}
+	result.ObjectType = VerifyObjectCommit
+	result.Hash = commit.Hash.String()
+	x.check(&result, commit.PGPSignature, commit.Verify)
+	return result, nil
+}
+
+// check 校验签名并填充结果
+func (x *GitVerifySignatureNode) check(result *SignatureResult, signature string, verify func(string) (*openpgp.Entity, error)) {
+	if strings.TrimSpace(signature) == "" {
+		result.Status = SignatureStatusUnsigned
+		return
+	}
+	result.Signed = true
+	result.KeyId = signatureKeyId(signature)
+	entity, err := verify(x.keyring)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		result.Status = SignatureStatusUnknownKey
+		result.Error = err.Error()
+		return
+	} else if err != nil {
+		result.Status = SignatureStatusBad
+		result.Error = err.Error()
+		return
+	}
+	result.Status = SignatureStatusValid
+	if identity := entity.PrimaryIdentity(); identity != nil && identity.UserId != nil {
+		result.Signer = identity.Name
+		result.SignerEmail = identity.UserId.Email
+	}
+}
+
+func (x *GitVerifySignatureNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// findTagObject 查找引用对应的附注标签，不是附注标签返回nil
+func findTagObject(r *git.Repository, ref string) (*object.Tag, error) {
+	name := plumbing.ReferenceName(ref)
+	if !name.IsTag() {
+		name = plumbing.NewTagReferenceName(ref)
+	}
+	tagRef, err := r.Reference(name, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tag, err := r.TagObject(tagRef.Hash())
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		// 轻量标签
+		return nil, nil
+	}
+	return tag, err
+}
+
+// signatureKeyId 解析签名中的公钥ID，解析失败返回空
+func signatureKeyId(signature string) string {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	if sig, ok := p.(*packet.Signature); ok && sig.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	}
+	return ""
+}
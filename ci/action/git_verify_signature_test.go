@@ -0,0 +1,187 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestPGPEntity 生成测试使用的PGP密钥，返回密钥和公钥密钥环
+func newTestPGPEntity(t *testing.T, name, email string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, entity.Serialize(w))
+	assert.Nil(t, w.Close())
+	return entity, buf.String()
+}
+
+func TestGitVerifySignatureNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitVerifySignatureNode{})
+	var targetNodeType = "ci/gitVerifySignature"
+
+	trusted, keyring := newTestPGPEntity(t, "rulego", "rulego@rulego.cc")
+	unknown, _ := newTestPGPEntity(t, "other", "other@rulego.cc")
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitVerifySignatureNode{}, types.Configuration{
+			"ref":            "HEAD",
+			"object":         VerifyObjectAuto,
+			"requireSigned":  true,
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"armoredKeyring": "/not/exists.asc"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"armoredKeyring": "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nxx"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"armoredKeyring": keyring, "object": "tree"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	w, err := r.Worktree()
+	assert.Nil(t, err)
+	commitSigned := func(name string, key *openpgp.Entity) plumbing.Hash {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+		_, err := w.Add(name)
+		assert.Nil(t, err)
+		signature := testSignature
+		signature.When = time.Now()
+		hash, err := w.Commit("add "+name, &git.CommitOptions{Author: &signature, SignKey: key})
+		assert.Nil(t, err)
+		return hash
+	}
+	unsigned, _ := r.Head()
+	unknownSigned := commitSigned("b.txt", unknown)
+	signed := commitSigned("a.txt", trusted)
+	tagger := testSignature
+	tagger.When = time.Now()
+	_, err = r.CreateTag("v1.0.0", signed, &git.CreateTagOptions{Tagger: &tagger, Message: "v1.0.0", SignKey: trusted})
+	assert.Nil(t, err)
+	_, err = r.CreateTag("v0.9.0", unsigned.Hash(), &git.CreateTagOptions{Tagger: &tagger, Message: "v0.9.0"})
+	assert.Nil(t, err)
+	_, err = r.CreateTag("light", signed, nil)
+	assert.Nil(t, err)
+
+	keyringFile := filepath.Join(t.TempDir(), "keyring.asc")
+	assert.Nil(t, os.WriteFile(keyringFile, []byte(keyring), 0644))
+
+	verify := func(t *testing.T, config types.Configuration) (SignatureResult, types.RuleMsg, string, error) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		if _, ok := config["armoredKeyring"]; !ok {
+			config["armoredKeyring"] = keyring
+		}
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.TEXT, types.NewMetadata(), ""))
+		var result SignatureResult
+		if outMsg.DataType == types.JSON {
+			assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		}
+		return result, outMsg, relationType, err
+	}
+
+	t.Run("ValidCommit", func(t *testing.T) {
+		result, outMsg, relationType, err := verify(t, types.Configuration{"armoredKeyring": keyringFile})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, SignatureStatusValid, outMsg.Metadata.GetValue(KeySignatureStatus))
+		assert.Equal(t, VerifyObjectCommit, result.ObjectType)
+		assert.Equal(t, signed.String(), result.Hash)
+		assert.True(t, result.Signed)
+		assert.Equal(t, "rulego <rulego@rulego.cc>", result.Signer)
+		assert.Equal(t, "rulego@rulego.cc", result.SignerEmail)
+		assert.Equal(t, fmt.Sprintf("%016X", trusted.PrimaryKey.KeyId), result.KeyId)
+	})
+
+	t.Run("ValidTag", func(t *testing.T) {
+		result, _, relationType, err := verify(t, types.Configuration{"ref": "v1.0.0"})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, VerifyObjectTag, result.ObjectType)
+		assert.Equal(t, SignatureStatusValid, result.Status)
+
+		// 轻量标签校验其指向的提交
+		result, _, _, err = verify(t, types.Configuration{"ref": "light"})
+		assert.Nil(t, err)
+		assert.Equal(t, VerifyObjectCommit, result.ObjectType)
+		_, _, _, err = verify(t, types.Configuration{"ref": "light", "object": VerifyObjectTag})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		result, _, relationType, err := verify(t, types.Configuration{"ref": "v0.9.0"})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, SignatureStatusUnsigned, result.Status)
+		assert.False(t, result.Signed)
+
+		result, _, relationType, err = verify(t, types.Configuration{"ref": "v0.9.0", "object": VerifyObjectCommit, "requireSigned": false})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, VerifyObjectCommit, result.ObjectType)
+		assert.Equal(t, SignatureStatusUnsigned, result.Status)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		result, _, relationType, err := verify(t, types.Configuration{"ref": unknownSigned.String(), "requireSigned": false})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, SignatureStatusUnknownKey, result.Status)
+		assert.Equal(t, fmt.Sprintf("%016X", unknown.PrimaryKey.KeyId), result.KeyId)
+	})
+
+	t.Run("BadSignature", func(t *testing.T) {
+		commit, err := r.CommitObject(signed)
+		assert.Nil(t, err)
+		commit.Message = "tampered"
+		obj := r.Storer.NewEncodedObject()
+		assert.Nil(t, commit.Encode(obj))
+		hash, err := r.Storer.SetEncodedObject(obj)
+		assert.Nil(t, err)
+
+		result, _, relationType, err := verify(t, types.Configuration{"ref": hash.String()})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, SignatureStatusBad, result.Status)
+		assert.True(t, result.Error != "")
+	})
+}
@@ -0,0 +1,353 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&GitWorktreeAddNode{})
+}
+
+// KeyWorktreeDir 新增工作树的目录
+const KeyWorktreeDir = "worktreeDir"
+
+const (
+	// WorktreeActionAdd 新增工作树
+	WorktreeActionAdd = "add"
+	// WorktreeActionRemove 删除工作树
+	WorktreeActionRemove = "remove"
+)
+
+// worktreeConfigSection 工作树记录主仓库目录的配置，删除时用于确认目标目录是由该节点创建的
+const (
+	worktreeConfigSection    = "rulego"
+	worktreeConfigSubsection = "worktree"
+	worktreeConfigKey        = "main"
+)
+
+// GitWorktreeAddNodeConfiguration 节点配置
+type GitWorktreeAddNodeConfiguration struct {
+	// 主仓库本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 操作，可以是 add 或 remove，默认add
+	Action string
+	// 检出的引用，可以是分支、标签或者提交hash，默认HEAD
+	Ref string
+	// 新建的分支名称，为空则 Ref 为本地分支时检出该分支，否则以分离HEAD的方式检出
+	Branch string
+	// 工作树目录，必须不存在或者为空目录
+	TargetDirectory string
+	// 删除时是否忽略未提交的修改以及不是由该节点创建的工作树
+	Force bool
+	// 等待其他消息释放工作目录锁的超时时间，单位秒，0表示一直等待
+	WaitTimeout int
+}
+
+// GitWorktreeAddNode 从一个主仓库创建额外的检出目录，用于同时构建多个分支而不需要重复克隆
+// go-git 不支持 git worktree 和 alternates，所以使用与 git clone --local 相同的方式：对象目录使用硬链接(不支持时复制)共享，
+// 并复制主仓库的分支、标签、远程仓库配置，工作树是一个独立的仓库，可以被其他节点直接打开
+// 新工作树的目录写入元数据 worktreeDir，检出的提交写入元数据 commitHash
+// remove 操作删除工作树目录，默认只删除由该节点基于同一个主仓库创建且没有未提交修改的工作树
+type GitWorktreeAddNode struct {
+	baseGitNode
+	// 节点配置
+	Config GitWorktreeAddNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *GitWorktreeAddNode) Type() string {
+	return "ci/gitWorktreeAdd"
+}
+
+func (x *GitWorktreeAddNode) New() types.Node {
+	return &GitWorktreeAddNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: GitWorktreeAddNodeConfiguration{
+			Action:         WorktreeActionAdd,
+			Ref:            string(plumbing.HEAD),
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *GitWorktreeAddNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	x.Config.Action = strings.ToLower(strings.TrimSpace(x.Config.Action))
+	switch x.Config.Action {
+	case "":
+		x.Config.Action = WorktreeActionAdd
+	case WorktreeActionAdd, WorktreeActionRemove:
+	default:
+		return fmt.Errorf("unsupported worktree action: %s", x.Config.Action)
+	}
+	if strings.TrimSpace(x.Config.TargetDirectory) == "" {
+		return errors.New("targetDirectory can not be empty")
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.Ref) || str.CheckHasVar(x.Config.Branch) || str.CheckHasVar(x.Config.TargetDirectory) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GitWorktreeAddNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	if msg.Metadata.GetValue(x.metaKey(KeyRepoId)) != "" {
+		ctx.TellFailure(msg, errors.New("worktree is not supported for in-memory repository"))
+		return
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	targetDir, err := filepath.Abs(x.getValue(x.Config.TargetDirectory, evn))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	mainDir, err := filepath.Abs(workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if targetDir == mainDir {
+		ctx.TellFailure(msg, errors.New("targetDirectory can not be the main repository directory"))
+		return
+	}
+	unlock, err := x.lockWorkDir(ctx, "", workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	defer unlock()
+	if x.Config.Action == WorktreeActionRemove {
+		if err = x.remove(mainDir, targetDir); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		msg.Metadata.PutValue(x.metaKey(KeyWorktreeDir), targetDir)
+		ctx.TellSuccess(msg)
+		return
+	}
+	r, err := git.PlainOpen(workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ref := x.getValue(x.Config.Ref, evn)
+	if ref == "" {
+		ref = string(plumbing.HEAD)
+	}
+	commit, err := resolveCommit(r, ref)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	branch := x.getValue(x.Config.Branch, evn)
+	checkout := &git.CheckoutOptions{Hash: commit.Hash}
+	if branch != "" {
+		checkout = &git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Hash: commit.Hash, Create: true}
+	} else if _, err = r.Reference(plumbing.NewBranchReferenceName(ref), false); err == nil {
+		branch = ref
+		checkout = &git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(ref)}
+	}
+	if err = x.add(r, mainDir, targetDir, checkout); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyWorktreeDir), targetDir)
+	msg.Metadata.PutValue(x.metaKey(KeyCommitHash), commit.Hash.String())
+	if branch != "" {
+		msg.Metadata.PutValue(x.metaKey(KeyBranch), branch)
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *GitWorktreeAddNode) Destroy() {
+}
+
+// add 在 targetDir 创建共享主仓库对象的工作树，失败时删除已经创建的内容
+func (x *GitWorktreeAddNode) add(r *git.Repository, mainDir, targetDir string, checkout *git.CheckoutOptions) (err error) {
+	if entries, err := os.ReadDir(targetDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("target directory %s is not empty", targetDir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mainConfig, err := r.Config()
+	if err != nil {
+		return err
+	}
+	mainGitDir := mainDir
+	if !mainConfig.Core.IsBare {
+		mainGitDir = filepath.Join(mainDir, git.GitDirName)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(targetDir)
+		}
+	}()
+	wt, err := git.PlainInit(targetDir, false)
+	if err != nil {
+		return err
+	}
+	if err = linkObjects(filepath.Join(mainGitDir, "objects"), filepath.Join(targetDir, git.GitDirName, "objects")); err != nil {
+		return err
+	}
+	// 重新打开仓库，避免使用初始化时缓存的对象索引
+	if wt, err = git.PlainOpen(targetDir); err != nil {
+		return err
+	}
+	refs, err := r.References()
+	if err != nil {
+		return err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() == plumbing.HEAD {
+			return nil
+		}
+		return wt.Storer.SetReference(ref)
+	})
+	refs.Close()
+	if err != nil {
+		return err
+	}
+	cfg, err := wt.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Remotes = mainConfig.Remotes
+	cfg.Branches = mainConfig.Branches
+	cfg.Raw.Section(worktreeConfigSection).Subsection(worktreeConfigSubsection).SetOption(worktreeConfigKey, mainDir)
+	if err = wt.SetConfig(cfg); err != nil {
+		return err
+	}
+	w, err := wt.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Checkout(checkout)
+}
+
+// remove 删除工作树目录
+func (x *GitWorktreeAddNode) remove(mainDir, targetDir string) error {
+	wt, err := git.PlainOpen(targetDir)
+	if errors.Is(err, git.ErrRepositoryNotExists) && x.Config.Force {
+		return os.RemoveAll(targetDir)
+	} else if err != nil {
+		return err
+	}
+	if !x.Config.Force {
+		cfg, err := wt.Config()
+		if err != nil {
+			return err
+		}
+		if main := cfg.Raw.Section(worktreeConfigSection).Subsection(worktreeConfigSubsection).Option(worktreeConfigKey); main != mainDir {
+			return fmt.Errorf("%s is not a worktree of %s", targetDir, mainDir)
+		}
+		w, err := wt.Worktree()
+		if err != nil {
+			return err
+		}
+		if dirty, err := isDirtyWorktree(w); err != nil {
+			return err
+		} else if dirty {
+			return ErrDirtyWorktree
+		}
+	}
+	return os.RemoveAll(targetDir)
+}
+
+func (x *GitWorktreeAddNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// linkObjects 把 src 对象目录中的文件硬链接到 dst，不支持硬链接(例如跨文件系统)时复制
+func linkObjects(src, dst string) error {
+	return filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if _, err = os.Lstat(target); err == nil {
+			return nil
+		}
+		if err = os.Link(name, target); err == nil {
+			return nil
+		}
+		return copyFile(name, target)
+	})
+}
+
+// copyFile 复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
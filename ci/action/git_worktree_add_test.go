@@ -0,0 +1,161 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitWorktreeAddNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&GitWorktreeAddNode{})
+	var targetNodeType = "ci/gitWorktreeAdd"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GitWorktreeAddNode{}, types.Configuration{
+			"action":         WorktreeActionAdd,
+			"ref":            "HEAD",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"action": "prune", "targetDirectory": "/tmp/wt"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	mainDir := t.TempDir()
+	r := initTestRepo(t, mainDir)
+	v1 := commitTestFile(t, r, "version.txt", "v1", "v1")
+	_, err := r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"https://github.com/rulego/rulego.git"}})
+	assert.Nil(t, err)
+	w, err := r.Worktree()
+	assert.Nil(t, err)
+	assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Create: true}))
+	release := commitTestFile(t, r, "version.txt", "v2", "v2")
+	assert.Nil(t, w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("main")}))
+
+	worktree := func(t *testing.T, config types.Configuration) (types.RuleMsg, string, error) {
+		config["directory"] = mainDir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		return onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	}
+	readFile := func(name string) string {
+		data, _ := os.ReadFile(name)
+		return string(data)
+	}
+
+	t.Run("AddBranch", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "release")
+		outMsg, relationType, err := worktree(t, types.Configuration{"ref": "release", "targetDirectory": target})
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, target, outMsg.Metadata.GetValue(KeyWorktreeDir))
+		assert.Equal(t, release.String(), outMsg.Metadata.GetValue(KeyCommitHash))
+		assert.Equal(t, "release", outMsg.Metadata.GetValue(KeyBranch))
+		assert.Equal(t, "v2", readFile(filepath.Join(target, "version.txt")))
+		// 主仓库不受影响
+		assert.Equal(t, "v1", readFile(filepath.Join(mainDir, "version.txt")))
+
+		wt, err := git.PlainOpen(target)
+		assert.Nil(t, err)
+		head, err := wt.Head()
+		assert.Nil(t, err)
+		assert.Equal(t, plumbing.NewBranchReferenceName("release"), head.Name())
+		remote, err := wt.Remote("origin")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://github.com/rulego/rulego.git", remote.Config().URLs[0])
+		_, err = wt.Reference(plumbing.NewBranchReferenceName("main"), false)
+		assert.Nil(t, err)
+		// 可以在工作树中继续提交
+		commitTestFile(t, wt, "build.txt", "ok", "build")
+
+		_, relationType, err = worktree(t, types.Configuration{"ref": "main", "targetDirectory": target})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+
+		assert.Nil(t, os.WriteFile(filepath.Join(target, "version.txt"), []byte("dirty"), 0644))
+		_, _, err = worktree(t, types.Configuration{"action": WorktreeActionRemove, "targetDirectory": target})
+		assert.Equal(t, ErrDirtyWorktree, err)
+		_, _, err = worktree(t, types.Configuration{"action": WorktreeActionRemove, "targetDirectory": target, "force": true})
+		assert.Nil(t, err)
+		_, err = os.Stat(target)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("AddDetachedAndNewBranch", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "detached")
+		_, _, err := worktree(t, types.Configuration{"ref": v1.String()[:8], "targetDirectory": target})
+		assert.Nil(t, err)
+		wt, err := git.PlainOpen(target)
+		assert.Nil(t, err)
+		head, err := wt.Head()
+		assert.Nil(t, err)
+		assert.Equal(t, plumbing.HEAD, head.Name())
+		assert.Equal(t, v1, head.Hash())
+
+		target = filepath.Join(t.TempDir(), "feature")
+		outMsg, _, err := worktree(t, types.Configuration{"ref": "release", "branch": "feature", "targetDirectory": target})
+		assert.Nil(t, err)
+		assert.Equal(t, "feature", outMsg.Metadata.GetValue(KeyBranch))
+		wt, err = git.PlainOpen(target)
+		assert.Nil(t, err)
+		head, err = wt.Head()
+		assert.Nil(t, err)
+		assert.Equal(t, plumbing.NewBranchReferenceName("feature"), head.Name())
+		assert.Equal(t, release, head.Hash())
+
+		_, _, err = worktree(t, types.Configuration{"action": WorktreeActionRemove, "targetDirectory": target})
+		assert.Nil(t, err)
+		_, err = os.Stat(target)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("RemoveNotWorktree", func(t *testing.T) {
+		other := t.TempDir()
+		initTestRepo(t, other)
+		_, relationType, err := worktree(t, types.Configuration{"action": WorktreeActionRemove, "targetDirectory": other})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		_, err = os.Stat(filepath.Join(other, "README.md"))
+		assert.Nil(t, err)
+
+		_, _, err = worktree(t, types.Configuration{"action": WorktreeActionRemove, "targetDirectory": mainDir})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("InvalidRef", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "missing")
+		_, relationType, err := worktree(t, types.Configuration{"ref": "missing", "targetDirectory": target})
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+		_, err = os.Stat(target)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
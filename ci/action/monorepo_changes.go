@@ -0,0 +1,283 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/base"
+	"github.com/rulego/rulego/utils/str"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&MonorepoChangesNode{})
+}
+
+// KeyChangedPrefixes 有变更的路径前缀，多个与逗号隔开
+const KeyChangedPrefixes = "changedPrefixes"
+
+// MonorepoChangesNodeConfiguration 节点配置
+type MonorepoChangesNodeConfiguration struct {
+	// 本地目录
+	Directory string
+	// 是否把仓库名称拼接到本地目录，默认true，false则直接使用本地目录或者元数据workDir
+	AppendRepoName bool
+	// 拼接仓库名称时是否包含组织/分组路径，例如：rulego/rulego-components-ci，用于避免不同组织同名仓库冲突
+	AppendRepoPath bool
+	// 比较的起点，通常是上一次成功构建的提交，例如：${metadata.lastBuildHash}，为空表示所有路径都有变更
+	FromRef string
+	// 比较的终点，可以是提交hash、分支或者标签，默认HEAD
+	ToRef string
+	// 监听的路径前缀，多个与逗号隔开，例如：services/*,libs/x
+	// 支持 * ? [] 通配符，通配符路径展开为 FromRef 或 ToRef 中实际存在的目录
+	PathPrefixes string
+}
+
+// PathChanges 路径前缀的变更
+type PathChanges struct {
+	// 是否有变更
+	Changed bool `json:"changed"`
+	// 变更的文件
+	Files []string `json:"files"`
+}
+
+// MonorepoChangesNode 比较两个引用，判断单仓多项目中每个路径前缀是否有变更，用于按项目选择性构建
+// 结果以 {"路径前缀":{"changed":true,"files":[]}} 的JSON形式写入 msg.Data，有变更的路径前缀写入元数据 changedPrefixes，可以配合switch节点路由
+type MonorepoChangesNode struct {
+	baseGitNode
+	// 节点配置
+	Config MonorepoChangesNodeConfiguration
+	hasVar bool
+}
+
+// Type 组件类型
+func (x *MonorepoChangesNode) Type() string {
+	return "ci/monorepoChanges"
+}
+
+func (x *MonorepoChangesNode) New() types.Node {
+	return &MonorepoChangesNode{
+		baseGitNode: baseGitNode{Config: baseGitNodeConfiguration{AppendRepoName: true}},
+		Config: MonorepoChangesNodeConfiguration{
+			ToRef:          string(plumbing.HEAD),
+			AppendRepoName: true,
+		},
+	}
+}
+
+// Init 初始化
+func (x *MonorepoChangesNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := x.initConfig(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.PathPrefixes) == "" {
+		return errors.New("pathPrefixes can not be empty")
+	}
+	for _, prefix := range splitPathPrefixes(x.Config.PathPrefixes) {
+		if _, err := path.Match(prefix, ""); err != nil && !str.CheckHasVar(prefix) {
+			return err
+		}
+	}
+	if str.CheckHasVar(x.Config.Directory) || str.CheckHasVar(x.Config.FromRef) || str.CheckHasVar(x.Config.ToRef) || str.CheckHasVar(x.Config.PathPrefixes) {
+		x.hasVar = true
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *MonorepoChangesNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var evn map[string]interface{}
+	if x.hasVar {
+		evn = base.NodeUtils.GetEvnAndMetadata(ctx, msg)
+	}
+	workDir := x.getWorkDir(msg, evn)
+	msg.Metadata.PutValue(x.metaKey(KeyWorkDir), workDir)
+	r, err := x.openRepository(msg, workDir)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	toRef := x.getValue(x.Config.ToRef, evn)
+	if toRef == "" {
+		toRef = string(plumbing.HEAD)
+	}
+	to, err := resolveCommit(r, toRef)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	var fromTree *object.Tree
+	if fromRef := x.getValue(x.Config.FromRef, evn); fromRef != "" {
+		from, err := resolveCommit(r, fromRef)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		if fromTree, err = from.Tree(); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	prefixes, err := expandPathPrefixes(splitPathPrefixes(x.getValue(x.Config.PathPrefixes, evn)), fromTree, toTree)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	files, err := x.changedFiles(fromTree, toTree)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	result := make(map[string]*PathChanges, len(prefixes))
+	var changedPrefixes []string
+	for _, prefix := range prefixes {
+		changes := &PathChanges{Files: make([]string, 0)}
+		for _, file := range files {
+			if prefix == "" || file == prefix || strings.HasPrefix(file, prefix+"/") {
+				changes.Files = append(changes.Files, file)
+			}
+		}
+		// FromRef 为空表示所有路径都有变更
+		changes.Changed = fromTree == nil || len(changes.Files) > 0
+		if changes.Changed {
+			changedPrefixes = append(changedPrefixes, prefix)
+		}
+		result[prefix] = changes
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.metaKey(KeyChanged), strconv.FormatBool(len(changedPrefixes) > 0))
+	msg.Metadata.PutValue(x.metaKey(KeyChangedPrefixes), strings.Join(changedPrefixes, ","))
+	msg.DataType = types.JSON
+	msg.Data = string(data)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *MonorepoChangesNode) Destroy() {
+}
+
+// changedFiles 比较两个树，返回排序后的变更文件路径，重命名同时包含原路径和新路径，from为nil表示所有文件都是新增的
+func (x *MonorepoChangesNode) changedFiles(from, to *object.Tree) ([]string, error) {
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (x *MonorepoChangesNode) getValue(value string, evn map[string]interface{}) string {
+	if evn != nil {
+		value = str.ExecuteTemplate(value, evn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// expandPathPrefixes 去掉路径前缀末尾的斜杠，并把通配符路径展开为任意一个树中实际存在的目录，保持配置的顺序并去重
+func expandPathPrefixes(prefixes []string, trees ...*object.Tree) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	add := func(prefix string) {
+		if !seen[prefix] {
+			seen[prefix] = true
+			result = append(result, prefix)
+		}
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.Trim(prefix, "/")
+		if !strings.ContainsAny(prefix, "*?[") {
+			add(prefix)
+			continue
+		}
+		matches := make(map[string]bool)
+		for _, tree := range trees {
+			if tree == nil {
+				continue
+			}
+			if err := matchTreeDirs(tree, "", strings.Split(prefix, "/"), matches); err != nil {
+				return nil, err
+			}
+		}
+		dirs := make([]string, 0, len(matches))
+		for dir := range matches {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			add(dir)
+		}
+	}
+	return result, nil
+}
+
+// matchTreeDirs 逐级匹配树中的目录
+func matchTreeDirs(tree *object.Tree, parent string, patterns []string, matches map[string]bool) error {
+	for _, entry := range tree.Entries {
+		if entry.Mode != filemode.Dir {
+			continue
+		}
+		ok, err := path.Match(patterns[0], entry.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		dir := path.Join(parent, entry.Name)
+		if len(patterns) == 1 {
+			matches[dir] = true
+			continue
+		}
+		subtree, err := tree.Tree(entry.Name)
+		if err != nil {
+			return err
+		}
+		if err = matchTreeDirs(subtree, dir, patterns[1:], matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2024 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/go-git/go-git/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestMonorepoChangesNode(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&MonorepoChangesNode{})
+	var targetNodeType = "ci/monorepoChanges"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &MonorepoChangesNode{}, types.Configuration{
+			"toRef":          "HEAD",
+			"appendRepoName": true,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"pathPrefixes": "services/[a"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	dir := t.TempDir()
+	r := initTestRepo(t, dir)
+	commitTestFile(t, r, "services/a/main.go", "package main\n", "add a")
+	commitTestFile(t, r, "services/b/main.go", "package main\n", "add b")
+	commitTestFile(t, r, "services/old/main.go", "package main\n", "add old")
+	from := commitTestFile(t, r, "libs/x/util.go", "package x\n", "add x")
+
+	commitTestFile(t, r, "services/a/main.go", "package main\n\nfunc main() {}\n", "modify a")
+	commitTestFile(t, r, "services/c/main.go", "package main\n", "add c")
+	commitTestFile(t, r, "services/ab.md", "ab\n", "add ab")
+	w, _ := r.Worktree()
+	_, err := w.Remove("services/old/main.go")
+	assert.Nil(t, err)
+	signature := testSignature
+	signature.When = time.Now()
+	_, err = w.Commit("remove old", &git.CommitOptions{Author: &signature})
+	assert.Nil(t, err)
+
+	changes := func(t *testing.T, config types.Configuration, metadata types.Metadata) (map[string]PathChanges, types.RuleMsg) {
+		config["directory"] = dir
+		config["appendRepoName"] = false
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		outMsg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, metadata, ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result map[string]PathChanges
+		assert.Nil(t, json.Unmarshal([]byte(outMsg.Data), &result))
+		return result, outMsg
+	}
+
+	t.Run("Prefixes", func(t *testing.T) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("lastBuildHash", from.String())
+		result, outMsg := changes(t, types.Configuration{
+			"fromRef":      "${metadata.lastBuildHash}",
+			"pathPrefixes": "services/a/, services/b, libs/x",
+		}, metadata)
+		assert.Equal(t, 3, len(result))
+		assert.True(t, result["services/a"].Changed)
+		assert.Equal(t, []string{"services/a/main.go"}, result["services/a"].Files)
+		assert.False(t, result["services/b"].Changed)
+		assert.Equal(t, 0, len(result["services/b"].Files))
+		assert.False(t, result["libs/x"].Changed)
+		assert.Equal(t, "services/a", outMsg.Metadata.GetValue(KeyChangedPrefixes))
+		assert.Equal(t, "true", outMsg.Metadata.GetValue(KeyChanged))
+
+		// 通配符展开为两个引用中实际存在的目录，包括已经删除的目录
+		result, outMsg = changes(t, types.Configuration{
+			"fromRef":      from.String(),
+			"pathPrefixes": "services/*,libs/*",
+		}, types.NewMetadata())
+		assert.Equal(t, 5, len(result))
+		assert.True(t, result["services/c"].Changed)
+		assert.True(t, result["services/old"].Changed)
+		assert.Equal(t, []string{"services/old/main.go"}, result["services/old"].Files)
+		_, ok := result["services/ab.md"]
+		assert.False(t, ok)
+		assert.Equal(t, "services/a,services/c,services/old", outMsg.Metadata.GetValue(KeyChangedPrefixes))
+
+		_, outMsg = changes(t, types.Configuration{"fromRef": "HEAD", "pathPrefixes": "services/*"}, types.NewMetadata())
+		assert.Equal(t, "", outMsg.Metadata.GetValue(KeyChangedPrefixes))
+		assert.Equal(t, "false", outMsg.Metadata.GetValue(KeyChanged))
+	})
+
+	t.Run("EmptyFromRef", func(t *testing.T) {
+		result, outMsg := changes(t, types.Configuration{"pathPrefixes": "services/*,libs/x"}, types.NewMetadata())
+		assert.Equal(t, 4, len(result))
+		assert.True(t, result["libs/x"].Changed)
+		assert.Equal(t, []string{"libs/x/util.go"}, result["libs/x"].Files)
+		assert.Equal(t, "services/a,services/b,services/c,libs/x", outMsg.Metadata.GetValue(KeyChangedPrefixes))
+	})
+
+	t.Run("RefNotFound", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"directory":      dir,
+			"appendRepoName": false,
+			"fromRef":        "not-exist",
+			"pathPrefixes":   "services/*",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.NotNil(t, err)
+		assert.Equal(t, types.Failure, relationType)
+	})
+}
@@ -18,14 +18,23 @@ package action
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rulego/rulego"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/utils/maps"
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -53,8 +62,129 @@ const (
 	OptionsNetIOCounters = "net/ioCounters"
 	// OptionsInterfaces 查询网络接口信息
 	OptionsInterfaces = "net/interfaces"
+	// OptionsNetConnections 查询网络连接
+	OptionsNetConnections = "net/connections"
+	// OptionsLoadAvg 查询系统负载
+	OptionsLoadAvg = "load/avg"
+	// OptionsProcessTop 查询 CPU 或者内存占用最高的进程
+	OptionsProcessTop = "process/top"
+	// OptionsProcessByName 按名称或者PID查询指定进程
+	OptionsProcessByName = "process/byName"
 )
 
+const (
+	// ProcessSortByCpu 按 CPU 使用率排序
+	ProcessSortByCpu = "cpu"
+	// ProcessSortByMemory 按常驻内存排序
+	ProcessSortByMemory = "memory"
+)
+
+// psOnDemandOptions 开销较大的指标，只有在 Options 中指定时才查询
+var psOnDemandOptions = map[string]bool{
+	OptionsProcessTop:     true,
+	OptionsProcessByName:  true,
+	OptionsNetConnections: true,
+}
+
+// connKinds ConnKind 支持的值
+var connKinds = map[string]bool{"tcp": true, "udp": true, "all": true}
+
+// KeyPsErrors 结果中采集失败的指标以及错误信息
+const KeyPsErrors = "errors"
+
+// KeyPsPartial 元数据键，有指标采集失败时为 true
+const KeyPsPartial = "psPartial"
+
+// KeyPsDiskSkipped 元数据键，disk/usage 无法读取而被跳过的挂载点数量
+const KeyPsDiskSkipped = "psDiskSkipped"
+
+// psCollectors 各指标的采集函数
+var psCollectors = map[string]func(x *PsNode) (interface{}, error){
+	// 查询主机信息
+	OptionsHostInfo: func(x *PsNode) (interface{}, error) {
+		return host.Info()
+	},
+	// 查询 CPU 信息
+	OptionsCpuInfo: func(x *PsNode) (interface{}, error) {
+		return cpu.Info()
+	},
+	// 查询 CPU 使用率
+	OptionsCpuPercent: func(x *PsNode) (interface{}, error) {
+		percents, err := cpu.Percent(time.Duration(x.Config.CpuSampleMs)*time.Millisecond, x.Config.PerCpu)
+		if err != nil || !x.Config.PerCpu {
+			return percents, err
+		}
+		items := make([]CpuPercent, 0, len(percents))
+		for i, percent := range percents {
+			items = append(items, CpuPercent{Cpu: "cpu" + strconv.Itoa(i), Percent: percent})
+		}
+		return items, nil
+	},
+	// 查询虚拟内存信息
+	OptionsVirtualMemory: func(x *PsNode) (interface{}, error) {
+		return mem.VirtualMemory()
+	},
+	// 查询交换内存信息
+	OptionsSwapMemory: func(x *PsNode) (interface{}, error) {
+		return mem.SwapMemory()
+	},
+	// 查询磁盘使用情况
+	OptionsDiskUsage: func(x *PsNode) (interface{}, error) {
+		usage, err := x.diskUsage()
+		if err != nil {
+			return nil, err
+		}
+		return usage, nil
+	},
+	// 查询磁盘IO计数器信息
+	OptionsDiskIOCounters: func(x *PsNode) (interface{}, error) {
+		diskIOCounters, err := disk.IOCounters()
+		var items []disk.IOCountersStat
+		for _, item := range diskIOCounters {
+			items = append(items, item)
+		}
+		return items, err
+	},
+	// 查询网络IO计数器信息
+	OptionsNetIOCounters: func(x *PsNode) (interface{}, error) {
+		return net.IOCounters(true)
+	},
+	// 查询网络接口信息
+	OptionsInterfaces: func(x *PsNode) (interface{}, error) {
+		return net.Interfaces()
+	},
+	// 查询网络连接
+	OptionsNetConnections: func(x *PsNode) (interface{}, error) {
+		return x.netConnections()
+	},
+	// 查询系统负载
+	OptionsLoadAvg: func(x *PsNode) (interface{}, error) {
+		return loadAvg()
+	},
+	// 查询 CPU 或者内存占用最高的进程
+	OptionsProcessTop: func(x *PsNode) (interface{}, error) {
+		return x.topProcesses()
+	},
+	// 按名称或者PID查询指定进程
+	OptionsProcessByName: func(x *PsNode) (interface{}, error) {
+		return x.findProcesses()
+	},
+}
+
+// joinCollectErrors 按指标名称排序合并采集错误
+func joinCollectErrors(collectErrors map[string]string) error {
+	options := make([]string, 0, len(collectErrors))
+	for option := range collectErrors {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	errs := make([]error, 0, len(options))
+	for _, option := range options {
+		errs = append(errs, fmt.Errorf("%s: %s", option, collectErrors[option]))
+	}
+	return errors.Join(errs...)
+}
+
 // PsNodeConfiguration 组件配置
 type PsNodeConfiguration struct {
 	// 指定要查询的指标列表
@@ -64,12 +194,118 @@ type PsNodeConfiguration struct {
 	//  - cpu/percent: 查询CPU使用率
 	//  - mem/virtualMemory: 查询虚拟内存信息
 	//  - mem/swapMemory: 查询交换内存信息
-	//  - disk/usage: 查询磁盘使用情况
+	//  - disk/usage: 查询磁盘使用情况，无法读取的挂载点被跳过，数量写入元数据 psDiskSkipped
 	//  - disk/ioCounters: 查询磁盘IO计数器信息
 	//  - net/ioCounters: 查询网络IO计数器信息
 	//  - net/interfaces: 查询网络接口信息
+	//  - net/connections: 查询网络连接，只有指定时才查询
+	//  - load/avg: 查询系统1、5、15分钟平均负载
+	//  - process/top: 查询 CPU 或者内存占用最高的进程，只有指定时才查询
+	//  - process/byName: 按名称或者PID查询指定进程，只有指定时才查询
 	// 如果为空，则查询所有指标
 	Options []string
+	// 有指标采集失败时是否发送到 Failure 链，错误信息为所有采集失败的指标和错误
+	// 默认 false，返回部分结果，失败的指标和错误信息放在结果的 errors 字段，元数据 psPartial=true
+	FailOnError bool
+	// CPU 使用率的采样时间，单位毫秒，默认1000
+	// 0表示不阻塞，返回与上一次查询之间的使用率
+	CpuSampleMs int
+	// 是否按核返回 CPU 使用率，默认只返回所有核的总使用率
+	PerCpu bool
+	// process/top 返回的进程数量，默认10
+	ProcessTopN int
+	// process/top 的排序方式，可以是 cpu 或者 memory，默认 memory
+	ProcessSortBy string
+	// process/top 返回的命令行最大长度，超过的部分被截断，0表示不截断，默认256
+	ProcessCmdlineMaxLen int
+	// disk/usage 查询的挂载点，为空则查询所有分区
+	DiskMountpoints []string
+	// disk/usage 查询所有分区时排除的文件系统类型，默认排除 tmpfs、devtmpfs、overlay、squashfs、proc、sysfs
+	DiskExcludeFsTypes []string
+	// disk/usage 查询所有分区时是否只查询物理设备
+	DiskPhysicalOnly bool
+	// net/connections 查询的连接类型，可以是 tcp、udp 或者 all，默认 all
+	ConnKind string
+	// net/connections 只返回这些状态的连接，例如：LISTEN、ESTABLISHED，为空则不过滤
+	ConnStatusFilter []string
+	// net/connections 只返回本地或者远程端口在列表中的连接，为空则不过滤
+	ConnPortFilter []int
+	// process/byName 查询的进程名称，名称完全相同的进程都返回
+	ProcessNames []string
+	// process/byName 查询的进程PID
+	ProcessPids []int
+	// process/byName 是否同时按命令行匹配，命令行包含 ProcessNames 中的值也算匹配
+	ProcessMatchCmdline bool
+	// process/byName 查询的进程有任意一个没有运行时是否发送到 Failure 链
+	RequireRunning bool
+}
+
+// diskUsageResult 磁盘使用情况，结果中只有各挂载点的使用情况，跳过的数量写入元数据
+type diskUsageResult struct {
+	// 各挂载点的使用情况，fstype 为文件系统类型
+	usages []*disk.UsageStat
+	// 无法读取而被跳过的挂载点数量
+	skipped int
+}
+
+// NetConnections 网络连接
+type NetConnections struct {
+	// 过滤后的连接按状态分组的数量
+	Counts map[string]int `json:"counts"`
+	// 过滤后的连接
+	Connections []NetConnection `json:"connections"`
+	// 没有获取到PID的连接数量，不包括 TIME_WAIT、CLOSE 等不属于任何进程的连接
+	UnresolvedPids int `json:"unresolvedPids"`
+}
+
+// ownerlessConnStatuses 不属于任何进程的连接状态，PID 总是0
+var ownerlessConnStatuses = map[string]bool{"TIME_WAIT": true, "CLOSE": true}
+
+// ownedConnStatuses 一定属于某个进程的连接状态，没有权限时无法获取这些连接的PID
+var ownedConnStatuses = map[string]bool{"LISTEN": true, "ESTABLISHED": true}
+
+// NetConnection 网络连接
+type NetConnection struct {
+	Laddr  net.Addr `json:"laddr"`
+	Raddr  net.Addr `json:"raddr"`
+	Status string   `json:"status"`
+	// 所属进程PID，没有权限获取时为0
+	Pid int32 `json:"pid"`
+}
+
+// LoadAvg 系统平均负载
+type LoadAvg struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+	// 运行和阻塞的进程数量等，无法获取时为空
+	Misc *load.MiscStat `json:"misc,omitempty"`
+	// 系统不支持平均负载(Windows)，此时负载值没有意义
+	Unsupported bool `json:"unsupported,omitempty"`
+}
+
+// ProcessInfo 进程信息
+type ProcessInfo struct {
+	Pid      int32  `json:"pid"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	// 进程启动以来的 CPU 使用率，百分比
+	CpuPercent float64 `json:"cpuPercent"`
+	// 常驻内存，单位字节
+	Rss uint64 `json:"rss"`
+	// 虚拟内存，单位字节
+	Vms uint64 `json:"vms"`
+	// 启动时间，毫秒时间戳
+	CreateTime int64  `json:"createTime"`
+	Cmdline    string `json:"cmdline"`
+}
+
+// CpuPercent 单个核的 CPU 使用率
+type CpuPercent struct {
+	// 核名称，例如：cpu0
+	Cpu string `json:"cpu"`
+	// 使用率，百分比
+	Percent float64 `json:"percent"`
 }
 
 // PsNode 查询主机信息，如：主机信息、CPU信息、内存信息、磁盘信息、网络信息等
@@ -87,100 +323,353 @@ func (x *PsNode) Type() string {
 }
 
 func (x *PsNode) New() types.Node {
-	return &PsNode{Config: PsNodeConfiguration{}}
+	return &PsNode{Config: PsNodeConfiguration{
+		CpuSampleMs:          1000,
+		ProcessTopN:          10,
+		ProcessSortBy:        ProcessSortByMemory,
+		ProcessCmdlineMaxLen: 256,
+		DiskExcludeFsTypes:   []string{"tmpfs", "devtmpfs", "overlay", "squashfs", "proc", "sysfs"},
+		ConnKind:             "all",
+	}}
 }
 
 // Init 初始化
 func (x *PsNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
+	if err == nil && x.Config.CpuSampleMs < 0 {
+		err = errors.New("cpuSampleMs can not be negative")
+	}
+	if err == nil && !connKinds[x.Config.ConnKind] {
+		err = errors.New("not connKind=" + x.Config.ConnKind)
+	}
+	if err == nil && x.Config.ProcessSortBy != ProcessSortByCpu && x.Config.ProcessSortBy != ProcessSortByMemory {
+		err = errors.New("not processSortBy=" + x.Config.ProcessSortBy)
+	}
 	x.All = len(x.Config.Options) == 0
 	x.Metrics = make(map[string]bool)
 	for _, item := range x.Config.Options {
 		x.Metrics[item] = true
 	}
+	if err == nil && x.contains(OptionsProcessByName) && len(x.Config.ProcessNames) == 0 && len(x.Config.ProcessPids) == 0 {
+		err = errors.New("processNames and processPids can not both be empty when querying process/byName")
+	}
 	return err
 }
 
 // OnMsg 处理消息
 func (x *PsNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 	result := make(map[string]interface{})
+	collectErrors := make(map[string]string)
+	for option, collect := range psCollectors {
+		if !x.contains(option) {
+			continue
+		}
+		value, err := collect(x)
+		if err != nil {
+			collectErrors[option] = err.Error()
+		}
+		if usage, ok := value.(*diskUsageResult); ok {
+			msg.Metadata.PutValue(KeyPsDiskSkipped, strconv.Itoa(usage.skipped))
+			value = usage.usages
+		}
+		result[option] = value
+	}
+	if len(collectErrors) > 0 {
+		result[KeyPsErrors] = collectErrors
+	}
+	msg.Metadata.PutValue(KeyPsPartial, strconv.FormatBool(len(collectErrors) > 0))
 
-	// 查询主机信息
-	if x.All || x.contains(OptionsHostInfo) {
-		hostInfo, _ := host.Info()
-		result[OptionsHostInfo] = hostInfo
+	// 将 result 转换为 JSON 字符串并放入 msg.Data
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
 	}
-	// 查询 CPU 信息
-	if x.All || x.contains(OptionsCpuInfo) {
-		cpuInfo, _ := cpu.Info()
-		result[OptionsCpuInfo] = cpuInfo
+	msg.Data = string(resultJSON)
+
+	if x.Config.FailOnError && len(collectErrors) > 0 {
+		ctx.TellFailure(msg, joinCollectErrors(collectErrors))
+		return
 	}
-	// 查询 CPU 使用率
-	if x.All || x.contains(OptionsCpuPercent) {
-		percent, _ := cpu.Percent(time.Second, false)
-		result[OptionsCpuPercent] = percent
+	if x.Config.RequireRunning && x.contains(OptionsProcessByName) {
+		// 查询失败时无法确认进程在运行
+		if collectErr, ok := collectErrors[OptionsProcessByName]; ok {
+			ctx.TellFailure(msg, fmt.Errorf("%s: %s", OptionsProcessByName, collectErr))
+			return
+		}
+		targets, _ := result[OptionsProcessByName].([]ProcessTarget)
+		if err := notRunningError(targets); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
 	}
+	ctx.TellSuccess(msg)
+}
 
-	// 查询虚拟内存信息
-	if x.contains(OptionsVirtualMemory) {
-		memInfo, _ := mem.VirtualMemory()
-		result[OptionsVirtualMemory] = memInfo
+// 判断是否要查询指定指标
+func (x *PsNode) contains(target string) bool {
+	if x.All {
+		return !psOnDemandOptions[target]
 	}
-	// 查询交换内存信息
-	if x.contains(OptionsSwapMemory) {
-		swapInfo, _ := mem.SwapMemory()
-		result[OptionsSwapMemory] = swapInfo
+	_, ok := x.Metrics[target]
+	return ok
+}
+
+// topProcesses 先获取所有进程的排序值，只查询排在前面的 ProcessTopN 个进程的详细信息
+// 遍历期间退出的进程或者无权限读取的进程被跳过
+func (x *PsNode) topProcesses() ([]ProcessInfo, error) {
+	processes, err := process.Processes()
+	if err != nil {
+		return nil, err
 	}
-	// 查询磁盘使用情况
-	if x.contains(OptionsDiskUsage) {
-		diskInfo, _ := disk.Partitions(true)
-		var diskUsages []*disk.UsageStat
-		if diskInfo != nil {
-			for _, part := range diskInfo {
-				diskUsage, _ := disk.Usage(part.Mountpoint)
-				if diskUsage != nil {
-					diskUsages = append(diskUsages, diskUsage)
-				}
+	type candidate struct {
+		process *process.Process
+		key     float64
+	}
+	candidates := make([]candidate, 0, len(processes))
+	for _, p := range processes {
+		if x.Config.ProcessSortBy == ProcessSortByCpu {
+			if percent, err := p.CPUPercent(); err == nil {
+				candidates = append(candidates, candidate{process: p, key: percent})
 			}
+		} else if memInfo, err := p.MemoryInfo(); err == nil {
+			candidates = append(candidates, candidate{process: p, key: float64(memInfo.RSS)})
 		}
-		result[OptionsDiskUsage] = diskUsages
 	}
-	// 查询磁盘IO计数器信息
-	if x.contains(OptionsDiskIOCounters) {
-		diskIOCounters, _ := disk.IOCounters()
-		var items []disk.IOCountersStat
-		if diskIOCounters != nil {
-			for _, item := range diskIOCounters {
-				items = append(items, item)
-			}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].key > candidates[j].key
+	})
+	if x.Config.ProcessTopN > 0 && len(candidates) > x.Config.ProcessTopN {
+		candidates = candidates[:x.Config.ProcessTopN]
+	}
+	items := make([]ProcessInfo, 0, len(candidates))
+	for _, item := range candidates {
+		p := item.process
+		info := ProcessInfo{Pid: p.Pid}
+		info.Name, _ = p.Name()
+		info.Username, _ = p.Username()
+		info.CpuPercent, _ = p.CPUPercent()
+		if memInfo, err := p.MemoryInfo(); err == nil {
+			info.Rss, info.Vms = memInfo.RSS, memInfo.VMS
 		}
-		result[OptionsDiskIOCounters] = items
+		info.CreateTime, _ = p.CreateTime()
+		cmdline, _ := p.Cmdline()
+		info.Cmdline = truncateString(cmdline, x.Config.ProcessCmdlineMaxLen)
+		items = append(items, info)
 	}
-	// 查询网络IO计数器信息
-	if x.contains(OptionsNetIOCounters) {
-		netIOCounters, _ := net.IOCounters(true)
-		result[OptionsNetIOCounters] = netIOCounters
+	return items, nil
+}
+
+// ProcessTarget process/byName 查询的进程名称或者PID以及匹配到的进程
+type ProcessTarget struct {
+	// 查询的进程名称
+	Name string `json:"name,omitempty"`
+	// 查询的进程PID
+	Pid int32 `json:"pid,omitempty"`
+	// 是否有匹配的进程在运行，僵尸进程不算运行
+	Running   bool            `json:"running"`
+	Processes []ProcessDetail `json:"processes"`
+}
+
+// ProcessDetail 进程的运行状态
+type ProcessDetail struct {
+	Pid  int32  `json:"pid"`
+	Ppid int32  `json:"ppid"`
+	Name string `json:"name"`
+	// 进程状态，例如：running、sleep、zombie
+	Status []string `json:"status"`
+	// 进程启动以来的 CPU 使用率，百分比
+	CpuPercent float64                 `json:"cpuPercent"`
+	Memory     *process.MemoryInfoStat `json:"memory"`
+	// 打开的文件描述符数量，没有权限读取时为0
+	NumFds     int32 `json:"numFds"`
+	NumThreads int32 `json:"numThreads"`
+	// 运行时间，单位秒
+	UptimeSeconds int64 `json:"uptimeSeconds"`
+}
+
+// diskUsage 查询 DiskMountpoints 或者所有分区的使用情况，无法读取的挂载点被跳过并计数
+func (x *PsNode) diskUsage() (*diskUsageResult, error) {
+	result := &diskUsageResult{usages: []*disk.UsageStat{}}
+	if len(x.Config.DiskMountpoints) > 0 {
+		for _, mountpoint := range x.Config.DiskMountpoints {
+			x.addDiskUsage(result, mountpoint, "")
+		}
+		return result, nil
 	}
-	// 查询网络接口信息
-	if x.contains(OptionsInterfaces) {
-		netInterfaces, _ := net.Interfaces()
-		result[OptionsInterfaces] = netInterfaces
+	partitions, err := disk.Partitions(!x.Config.DiskPhysicalOnly)
+	if err != nil {
+		return nil, err
 	}
+	excludeFsTypes := make(map[string]bool, len(x.Config.DiskExcludeFsTypes))
+	for _, fsType := range x.Config.DiskExcludeFsTypes {
+		excludeFsTypes[fsType] = true
+	}
+	for _, part := range partitions {
+		if !excludeFsTypes[part.Fstype] {
+			x.addDiskUsage(result, part.Mountpoint, part.Fstype)
+		}
+	}
+	return result, nil
+}
 
-	// 将 result 转换为 JSON 字符串并放入 msg.Data
-	resultJSON, _ := json.Marshal(result)
-	msg.Data = string(resultJSON)
+// addDiskUsage 添加挂载点的使用情况，fsType 不为空时作为文件系统类型
+func (x *PsNode) addDiskUsage(result *diskUsageResult, mountpoint, fsType string) {
+	usage, err := disk.Usage(mountpoint)
+	if err != nil || usage == nil {
+		result.skipped++
+		return
+	}
+	if fsType != "" {
+		usage.Fstype = fsType
+	}
+	result.usages = append(result.usages, usage)
+}
 
-	ctx.TellSuccess(msg)
+// netConnections 查询网络连接，按 ConnStatusFilter 和 ConnPortFilter 过滤
+// 没有获取到PID的连接数量写入 UnresolvedPids，只有非特权运行时 LISTEN、ESTABLISHED 连接缺少PID才返回错误说明
+func (x *PsNode) netConnections() (*NetConnections, error) {
+	connections, err := net.Connections(x.Config.ConnKind)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]bool, len(x.Config.ConnStatusFilter))
+	for _, status := range x.Config.ConnStatusFilter {
+		statuses[strings.ToUpper(status)] = true
+	}
+	ports := make(map[uint32]bool, len(x.Config.ConnPortFilter))
+	for _, port := range x.Config.ConnPortFilter {
+		ports[uint32(port)] = true
+	}
+	result := &NetConnections{Counts: make(map[string]int), Connections: []NetConnection{}}
+	var unresolvedOwned int
+	for _, conn := range connections {
+		if len(statuses) > 0 && !statuses[conn.Status] {
+			continue
+		}
+		if len(ports) > 0 && !ports[conn.Laddr.Port] && !ports[conn.Raddr.Port] {
+			continue
+		}
+		result.Counts[conn.Status]++
+		result.Connections = append(result.Connections, NetConnection{Laddr: conn.Laddr, Raddr: conn.Raddr, Status: conn.Status, Pid: conn.Pid})
+		if conn.Pid == 0 && !ownerlessConnStatuses[conn.Status] {
+			result.UnresolvedPids++
+			if ownedConnStatuses[conn.Status] {
+				unresolvedOwned++
+			}
+		}
+	}
+	// Windows 上 Geteuid 返回 -1，同样视为非特权运行
+	if unresolvedOwned > 0 && os.Geteuid() != 0 {
+		return result, fmt.Errorf("pid of %d listening or established connections could not be resolved, which requires privileges", unresolvedOwned)
+	}
+	return result, nil
 }
 
-// 判断是否要查询指定指标
-func (x *PsNode) contains(target string) bool {
-	if x.All {
+// loadAvg 查询系统平均负载，Windows 返回 Unsupported
+func loadAvg() (*LoadAvg, error) {
+	if runtime.GOOS == "windows" {
+		return &LoadAvg{Unsupported: true}, nil
+	}
+	avg, err := load.Avg()
+	if err != nil {
+		return nil, err
+	}
+	result := &LoadAvg{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}
+	if misc, err := load.Misc(); err == nil {
+		result.Misc = misc
+	}
+	return result, nil
+}
+
+// findProcesses 按 ProcessNames 和 ProcessPids 查询进程，没有找到的进程 Running 为 false
+func (x *PsNode) findProcesses() ([]ProcessTarget, error) {
+	targets := make([]ProcessTarget, 0, len(x.Config.ProcessNames)+len(x.Config.ProcessPids))
+	if len(x.Config.ProcessNames) > 0 {
+		processes, err := process.Processes()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range x.Config.ProcessNames {
+			target := ProcessTarget{Name: name, Processes: []ProcessDetail{}}
+			for _, p := range processes {
+				if x.matchProcess(p, name) {
+					target.addProcess(p)
+				}
+			}
+			targets = append(targets, target)
+		}
+	}
+	for _, pid := range x.Config.ProcessPids {
+		target := ProcessTarget{Pid: int32(pid), Processes: []ProcessDetail{}}
+		if p, err := process.NewProcess(int32(pid)); err == nil {
+			target.addProcess(p)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// matchProcess 进程名称等于 name，或者 ProcessMatchCmdline 为 true 并且命令行包含 name
+func (x *PsNode) matchProcess(p *process.Process, name string) bool {
+	if processName, err := p.Name(); err == nil && processName == name {
 		return true
 	}
-	_, ok := x.Metrics[target]
-	return ok
+	if x.Config.ProcessMatchCmdline {
+		if cmdline, err := p.Cmdline(); err == nil && strings.Contains(cmdline, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// addProcess 添加匹配的进程，查询期间退出的进程被跳过
+func (t *ProcessTarget) addProcess(p *process.Process) {
+	status, err := p.Status()
+	if err != nil {
+		return
+	}
+	detail := ProcessDetail{Pid: p.Pid, Status: status}
+	detail.Ppid, _ = p.Ppid()
+	detail.Name, _ = p.Name()
+	detail.CpuPercent, _ = p.CPUPercent()
+	detail.Memory, _ = p.MemoryInfo()
+	detail.NumFds, _ = p.NumFDs()
+	detail.NumThreads, _ = p.NumThreads()
+	if createTime, err := p.CreateTime(); err == nil {
+		detail.UptimeSeconds = int64(time.Since(time.UnixMilli(createTime)).Seconds())
+	}
+	t.Processes = append(t.Processes, detail)
+	if len(status) == 0 || status[0] != process.Zombie {
+		t.Running = true
+	}
+}
+
+// notRunningError 查询的进程中没有运行的进程，都在运行时返回nil
+func notRunningError(targets []ProcessTarget) error {
+	var errs []error
+	for _, target := range targets {
+		if target.Running {
+			continue
+		}
+		if target.Name != "" {
+			errs = append(errs, fmt.Errorf("process %s is not running", target.Name))
+		} else {
+			errs = append(errs, fmt.Errorf("process %d is not running", target.Pid))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// truncateString 把字符串截断为最多 maxLen 个字符，maxLen<=0 表示不截断
+func truncateString(value string, maxLen int) string {
+	if maxLen <= 0 {
+		return value
+	}
+	if runes := []rune(value); len(runes) > maxLen {
+		return string(runes[:maxLen])
+	}
+	return value
 }
 
 // Destroy 销毁
@@ -18,9 +18,18 @@ package action
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	stdnet "net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -98,6 +107,13 @@ func TestPsNode(t *testing.T) {
 					assert.True(t, ok)
 					_, ok = result[OptionsInterfaces]
 					assert.True(t, ok)
+					if runtime.GOOS == "linux" {
+						loadAvg, ok := result[OptionsLoadAvg].(map[string]interface{})
+						assert.True(t, ok)
+						_, ok = loadAvg["load1"]
+						assert.True(t, ok)
+						assert.Nil(t, loadAvg["unsupported"])
+					}
 				},
 			},
 		}
@@ -107,3 +123,293 @@ func TestPsNode(t *testing.T) {
 		time.Sleep(time.Second * 5)
 	})
 }
+
+func TestPsNodeCollectErrors(t *testing.T) {
+	// 模拟受限容器中无法采集主机信息
+	collect := psCollectors[OptionsHostInfo]
+	psCollectors[OptionsHostInfo] = func(x *PsNode) (interface{}, error) {
+		return nil, errors.New("permission denied")
+	}
+	defer func() {
+		psCollectors[OptionsHostInfo] = collect
+	}()
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+
+	// 默认返回部分结果
+	node, err := test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options": []string{OptionsHostInfo, OptionsVirtualMemory},
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "true", msg.Metadata.GetValue(KeyPsPartial))
+	var result struct {
+		VirtualMemory map[string]interface{} `json:"mem/virtualMemory"`
+		Errors        map[string]string      `json:"errors"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+	assert.NotNil(t, result.VirtualMemory)
+	assert.Equal(t, map[string]string{OptionsHostInfo: "permission denied"}, result.Errors)
+
+	// 没有采集失败的指标
+	node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options": []string{OptionsVirtualMemory},
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "false", msg.Metadata.GetValue(KeyPsPartial))
+	assert.False(t, strings.Contains(msg.Data, KeyPsErrors))
+
+	// failOnError 发送到 Failure 链
+	node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":     []string{OptionsHostInfo, OptionsVirtualMemory},
+		"failOnError": true,
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Equal(t, types.Failure, relationType)
+	assert.Equal(t, "host/info: permission denied", err.Error())
+	assert.Equal(t, "true", msg.Metadata.GetValue(KeyPsPartial))
+}
+
+func TestPsNodeCpuPercent(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+	_, err := test.CreateAndInitNode("ci/ps", types.Configuration{"cpuSampleMs": -1}, Registry)
+	assert.NotNil(t, err)
+
+	// 不阻塞并且按核返回
+	node, err := test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":     []string{OptionsCpuPercent},
+		"cpuSampleMs": 0,
+		"perCpu":      true,
+	}, Registry)
+	assert.Nil(t, err)
+	start := time.Now()
+	msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result map[string][]CpuPercent
+	assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+	percents := result[OptionsCpuPercent]
+	counts, err := cpu.Counts(true)
+	assert.Nil(t, err)
+	assert.Equal(t, counts, len(percents))
+	assert.Equal(t, "cpu0", percents[0].Cpu)
+
+	// 默认采样1秒，返回总使用率
+	node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options": []string{OptionsCpuPercent},
+	}, Registry)
+	assert.Nil(t, err)
+	start = time.Now()
+	msg, _, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Nil(t, err)
+	var total map[string][]float64
+	assert.Nil(t, json.Unmarshal([]byte(msg.Data), &total))
+	assert.Equal(t, 1, len(total[OptionsCpuPercent]))
+}
+
+func TestPsNodeProcessTop(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+	_, err := test.CreateAndInitNode("ci/ps", types.Configuration{"processSortBy": "io"}, Registry)
+	assert.NotNil(t, err)
+
+	// 查询所有指标时不包括进程列表
+	node, err := test.CreateAndInitNode("ci/ps", types.Configuration{}, Registry)
+	assert.Nil(t, err)
+	assert.False(t, node.(*PsNode).contains(OptionsProcessTop))
+
+	for _, sortBy := range []string{ProcessSortByMemory, ProcessSortByCpu} {
+		node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+			"options":              []string{OptionsProcessTop},
+			"processTopN":          3,
+			"processSortBy":        sortBy,
+			"processCmdlineMaxLen": 5,
+		}, Registry)
+		assert.Nil(t, err)
+		msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result map[string][]ProcessInfo
+		assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+		items := result[OptionsProcessTop]
+		assert.True(t, len(items) > 0 && len(items) <= 3)
+		for _, item := range items {
+			assert.True(t, item.Pid > 0)
+			assert.True(t, len([]rune(item.Cmdline)) <= 5)
+		}
+	}
+}
+
+func TestPsNodeProcessByName(t *testing.T) {
+	// 使用唯一的命令行参数和进程名称，避免匹配到其他进程
+	duration := fmt.Sprintf("31.%d", time.Now().UnixNano()%1000000)
+	missing := fmt.Sprintf("rulego-not-exist-%d", time.Now().UnixNano())
+	cmd := exec.Command("sleep", duration)
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not found")
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+	_, err := test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options": []string{OptionsProcessByName},
+	}, Registry)
+	assert.NotNil(t, err)
+
+	node, err := test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":             []string{OptionsProcessByName},
+		"processNames":        []string{duration, missing},
+		"processPids":         []int{cmd.Process.Pid},
+		"processMatchCmdline": true,
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result map[string][]ProcessTarget
+	assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+	targets := result[OptionsProcessByName]
+	assert.Equal(t, 3, len(targets))
+	// 按命令行匹配
+	assert.True(t, targets[0].Running)
+	var found bool
+	for _, item := range targets[0].Processes {
+		if item.Pid == int32(cmd.Process.Pid) {
+			found = true
+			assert.Equal(t, "sleep", item.Name)
+			assert.True(t, item.NumThreads > 0)
+		}
+	}
+	assert.True(t, found)
+	// 没有找到的进程也返回
+	assert.Equal(t, missing, targets[1].Name)
+	assert.False(t, targets[1].Running)
+	assert.Equal(t, 0, len(targets[1].Processes))
+	// 按PID匹配
+	assert.True(t, targets[2].Running)
+	assert.Equal(t, int32(os.Getpid()), targets[2].Processes[0].Ppid)
+
+	node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":        []string{OptionsProcessByName},
+		"processNames":   []string{"sleep", missing},
+		"requireRunning": true,
+	}, Registry)
+	assert.Nil(t, err)
+	_, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Equal(t, types.Failure, relationType)
+	assert.Equal(t, "process "+missing+" is not running", err.Error())
+}
+
+func TestPsNodeDiskUsage(t *testing.T) {
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+	diskUsage := func(configuration types.Configuration) ([]*disk.UsageStat, string) {
+		configuration["options"] = []string{OptionsDiskUsage}
+		node, err := test.CreateAndInitNode("ci/ps", configuration, Registry)
+		assert.Nil(t, err)
+		msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		var result map[string][]*disk.UsageStat
+		assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+		return result[OptionsDiskUsage], msg.Metadata.GetValue(KeyPsDiskSkipped)
+	}
+
+	// 指定挂载点，无法读取的挂载点被跳过并计数
+	usages, skipped := diskUsage(types.Configuration{"diskMountpoints": []string{"/", "/rulego-not-exist"}})
+	assert.Equal(t, 1, len(usages))
+	assert.Equal(t, "1", skipped)
+	assert.Equal(t, "/", usages[0].Path)
+	if runtime.GOOS == "linux" {
+		assert.True(t, usages[0].Fstype != "")
+	}
+
+	// 默认排除虚拟文件系统
+	usages, _ = diskUsage(types.Configuration{})
+	for _, item := range usages {
+		for _, fsType := range []string{"tmpfs", "devtmpfs", "overlay", "squashfs", "proc", "sysfs"} {
+			assert.True(t, item.Fstype != fsType)
+		}
+	}
+	diskUsage(types.Configuration{"diskPhysicalOnly": true, "diskExcludeFsTypes": []string{}})
+}
+
+func TestPsNodeNetConnections(t *testing.T) {
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*stdnet.TCPAddr).Port
+
+	Registry := &types.SafeComponentSlice{}
+	Registry.Add(&PsNode{})
+	_, err = test.CreateAndInitNode("ci/ps", types.Configuration{"connKind": "icmp"}, Registry)
+	assert.NotNil(t, err)
+
+	node, err := test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":          []string{OptionsNetConnections},
+		"connKind":         "tcp",
+		"connStatusFilter": []string{"listen"},
+		"connPortFilter":   []int{port},
+	}, Registry)
+	assert.Nil(t, err)
+	msg, relationType, err := onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relationType)
+	var result map[string]NetConnections
+	assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+	connections := result[OptionsNetConnections]
+	assert.Equal(t, 1, len(connections.Connections))
+	assert.Equal(t, map[string]int{"LISTEN": 1}, connections.Counts)
+	assert.Equal(t, uint32(port), connections.Connections[0].Laddr.Port)
+	assert.Equal(t, int32(os.Getpid()), connections.Connections[0].Pid)
+	assert.Equal(t, 0, connections.UnresolvedPids)
+	assert.Equal(t, "false", msg.Metadata.GetValue(KeyPsPartial))
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	// 客户端先关闭的连接进入 TIME_WAIT，不属于任何进程，PID 为0也不算采集失败
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			buf := make([]byte, 1)
+			_, _ = conn.Read(buf)
+			_ = conn.Close()
+		}
+	}()
+	conn, err := stdnet.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Close())
+	node, err = test.CreateAndInitNode("ci/ps", types.Configuration{
+		"options":          []string{OptionsNetConnections},
+		"connKind":         "tcp",
+		"connStatusFilter": []string{"TIME_WAIT"},
+		"connPortFilter":   []int{port},
+		"failOnError":      true,
+	}, Registry)
+	assert.Nil(t, err)
+	var timeWait NetConnections
+	for i := 0; i < 50 && len(timeWait.Connections) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		msg, relationType, err = onMsgSync(node, types.NewMsg(0, "test", types.JSON, types.NewMetadata(), ""))
+		assert.Nil(t, err)
+		assert.Equal(t, types.Success, relationType)
+		assert.Nil(t, json.Unmarshal([]byte(msg.Data), &result))
+		timeWait = result[OptionsNetConnections]
+	}
+	assert.Equal(t, 1, len(timeWait.Connections))
+	assert.Equal(t, int32(0), timeWait.Connections[0].Pid)
+	assert.Equal(t, 0, timeWait.UnresolvedPids)
+	assert.Equal(t, "false", msg.Metadata.GetValue(KeyPsPartial))
+}